@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 	"zentro/internal/config"
+	"zentro/internal/filters"
 	"zentro/internal/global"
 	"zentro/internal/management"
 	"zentro/internal/router"
+	"zentro/internal/signing"
+	"zentro/internal/tlsserver"
 	"zentro/internal/embedf"
 )
 
@@ -23,6 +27,37 @@ func main() {
 	global.InitConfig(gc)
 
 	config.Init(gc.Environment)
+	if err := config.LoadGlobalConfig(gf.SettingsPath); err != nil {
+		log.Fatalf("Could not load global settings: %v", err)
+	}
+
+	if err := signing.Load(gf.SigningKeyPath); err != nil {
+		log.Fatalf("Could not load signing key: %v", err)
+	}
+
+	global.InitConsumers(config.MustLoadConsumers(gf.ConsumersConfigPath))
+	go global.WatchConsumersFile(gf.ConsumersConfigPath)
+
+	store, err := global.NewRateLimitStore(gc.Config.RateLimit)
+	if err != nil {
+		log.Fatalf("Could not create rate limit store: %v", err)
+	}
+	filters.RateLimitStore = store
+	filters.RecordRejection = global.GlobalMetrics.RecordRejection
+	filters.RecordFault = global.GlobalMetrics.RecordFault
+	filters.RecordWafMatch = global.GlobalMetrics.RecordWafMatch
+	filters.HmacSecrets = global.HmacSecrets
+	filters.TouchCredential = global.TouchCredential
+
+	responseCache, err := global.NewCacheStore(gc.Config.Cache)
+	if err != nil {
+		log.Fatalf("Could not open response cache: %v", err)
+	}
+	filters.ResponseCache = responseCache
+
+	if err := filters.InitQuotas(gf.QuotaStatePath, 5*time.Second); err != nil {
+		log.Fatalf("Could not load quota state: %v", err)
+	}
 
 	go global.WatchConfigFile(gf.RoutesConfigPath)
 	r := router.NewRouter(gc.Routes)
@@ -32,10 +67,38 @@ func main() {
 		log.Fatalf("Failed to load embedded UI: %v", err)
 	}
 
+	var handler http.Handler = r
+	if tlsCfg := gc.Config.Tls; tlsCfg != nil && tlsCfg.Enabled {
+		store, err := global.NewTLSStore(tlsCfg)
+		if err != nil {
+			log.Fatalf("Could not load TLS certificates: %v", err)
+		}
+		store.Watch()
+
+		forward := tlsCfg.ForwardClientCert
+		if forward == "" {
+			forward = config.ForwardClientCertDetails
+		}
+		// Both listeners drop client certificate headers sent by clients.
+		handler = tlsserver.ForwardClientCert(forward, r)
+
+		tlsPort := tlsCfg.Port
+		if tlsPort == 0 {
+			tlsPort = 8443
+		}
+		server := &http.Server{Addr: fmt.Sprintf(":%d", tlsPort), Handler: handler, TLSConfig: store.TLSConfig()}
+		go func() {
+			log.Printf("Zendor TLS started at %d", tlsPort)
+			if err := server.ListenAndServeTLS("", ""); err != nil {
+				log.Fatalln(err)
+			}
+		}()
+	}
+
 	go management.ManagementServer(adminAddr, uiFS)
 	log.Printf("Zendor started at %d", gf.Port)
 	log.Printf("Zendor Mangement started at %d", gf.AdminPort)
-	err = http.ListenAndServe(addr, r)
+	err = http.ListenAndServe(addr, handler)
 	if err != nil {
 		log.Fatalln(err)
 	}
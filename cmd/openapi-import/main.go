@@ -0,0 +1,87 @@
+// Command openapi-import generates routes from an OpenAPI 3 document and
+// shows how they differ from the routes file, writing them with -apply.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"zentro/internal/config"
+	"zentro/internal/openapi"
+)
+
+func main() {
+	routeConfig := flag.String("routefile", "config/routes.json", "path to routes config")
+	name := flag.String("name", "", "route name prefix (default: the document title)")
+	upstream := flag.String("upstream", "", "upstream URL replacing the document's servers")
+	validate := flag.Bool("validate", false, "validate requests against the document's schemas")
+	prune := flag.Bool("prune", false, "remove routes no longer in the document")
+	mock := flag.Bool("mock", false, "answer with the document's response examples")
+	apply := flag.Bool("apply", false, "write the changes to the routes file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] openapi.(json|yaml)\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var data []byte
+	var err error
+	if flag.Arg(0) == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(flag.Arg(0))
+	}
+	if err != nil {
+		log.Fatalf("Could not read document: %v", err)
+	}
+	doc, err := openapi.Parse(data)
+	if err != nil {
+		log.Fatalf("Invalid OpenAPI document: %v", err)
+	}
+
+	gatewayConfig, err := config.ReadRoutesFile(*routeConfig)
+	if err != nil {
+		log.Fatalf("Could not read routes file: %v", err)
+	}
+	plan, err := openapi.Import(gatewayConfig.Routes, doc, openapi.Options{
+		Name:     *name,
+		Upstream: *upstream,
+		Validate: *validate,
+		Prune:    *prune,
+		Mock:     *mock,
+	})
+	if err != nil {
+		log.Fatalf("Could not import document: %v", err)
+	}
+
+	for _, c := range plan.Changes {
+		switch c.Action {
+		case "add":
+			fmt.Printf("+ %s  %s %s -> %s\n", c.Name, strings.Join(c.After.Methods, ","), c.After.PathPrefix, strings.Join(c.After.Upstreams, ", "))
+		case "update":
+			fmt.Printf("~ %s  (%s)\n", c.Name, strings.Join(c.Fields, ", "))
+		case "remove":
+			fmt.Printf("- %s\n", c.Name)
+		}
+	}
+	fmt.Printf("%d changed, %d unchanged\n", len(plan.Changes), plan.Unchanged)
+	for _, w := range plan.Warnings {
+		fmt.Printf("warning: %s\n", w)
+	}
+
+	if !*apply || len(plan.Changes) == 0 {
+		return
+	}
+	gatewayConfig.Routes = plan.Routes
+	if err := config.SaveRoutesFile(*routeConfig, gatewayConfig); err != nil {
+		log.Fatalf("Could not write routes file: %v", err)
+	}
+	fmt.Printf("Wrote %s\n", *routeConfig)
+}
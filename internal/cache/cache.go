@@ -0,0 +1,108 @@
+// Package cache stores HTTP responses for the Cache filter and implements the
+// RFC 9111 rules deciding whether a stored response may be reused.
+package cache
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Entry is a stored response.
+type Entry struct {
+	Key string
+	// Scope is the scope of the filter that stored the entry, used to purge
+	// a route's entries.
+	Scope    string
+	Path     string
+	Tags     []string
+	Status   int
+	Header   http.Header
+	BodySize int64
+	// BodyRef identifies the body within the store that holds it.
+	BodyRef string
+	// RequestTime and ResponseTime bracket the upstream exchange and feed
+	// the age calculation.
+	RequestTime  time.Time
+	ResponseTime time.Time
+	// ExpiresAt is when the entry can no longer be served, even stale.
+	// Stores drop it afterwards.
+	ExpiresAt time.Time
+	// Vary marks a placeholder entry: the real entries are stored under
+	// secondary keys built from these request headers.
+	Vary []string
+}
+
+// Size approximates the space an entry and its body take.
+func (e *Entry) Size() int64 {
+	size := e.BodySize + int64(len(e.Key)+len(e.Path))
+	for k, vs := range e.Header {
+		size += int64(len(k))
+		for _, v := range vs {
+			size += int64(len(v))
+		}
+	}
+	for _, t := range e.Tags {
+		size += int64(len(t))
+	}
+	return size
+}
+
+// PurgeFilter selects entries to purge. Empty fields match everything; set
+// fields must all match.
+type PurgeFilter struct {
+	Scope  string
+	Prefix string
+	Tag    string
+}
+
+// Matches reports whether e is selected by f.
+func (f PurgeFilter) Matches(e *Entry) bool {
+	if f.Scope != "" && e.Scope != f.Scope && !strings.HasPrefix(e.Scope, f.Scope+"#") {
+		return false
+	}
+	if f.Prefix != "" && !strings.HasPrefix(e.Path, f.Prefix) {
+		return false
+	}
+	if f.Tag != "" {
+		for _, t := range e.Tags {
+			if t == f.Tag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// VaryKey extends a primary key with the request's values for the headers a
+// response varies on.
+func VaryKey(primary string, vary []string, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(primary)
+	for _, name := range vary {
+		b.WriteString("\n")
+		b.WriteString(strings.ToLower(name))
+		b.WriteString("=")
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// ParseVary returns the header names listed in a response's Vary header.
+// ok is false for "Vary: *", which can never be matched.
+func ParseVary(h http.Header) (names []string, ok bool) {
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				return nil, false
+			}
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	return names, true
+}
@@ -0,0 +1,327 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	"zentro/utils"
+)
+
+// DiskStore keeps bodies in files under dir and the entry metadata in an
+// index that is written back periodically, so the cache survives restarts.
+// Once the bodies pass maxBytes the least recently used entries are removed.
+type DiskStore struct {
+	dir      string
+	maxBytes int64
+
+	mu    sync.Mutex
+	index map[string]*diskEntry
+	size  int64
+	dirty bool
+	stop  chan struct{}
+}
+
+type diskEntry struct {
+	Entry      *Entry    `json:"entry"`
+	LastAccess time.Time `json:"last_access"`
+}
+
+const diskIndexFile = "index.json"
+
+// OpenDiskStore loads the index in dir, dropping entries whose body is gone
+// or that have expired, and body files no entry refers to.
+func OpenDiskStore(dir string, maxBytes int64) (*DiskStore, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	s := &DiskStore{dir: dir, maxBytes: maxBytes, index: make(map[string]*diskEntry)}
+	if err := os.MkdirAll(s.bodyDir(), 0755); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, diskIndexFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.index); err != nil {
+			log.Printf("Cache index %s is corrupt, starting empty: %v", dir, err)
+			s.index = make(map[string]*diskEntry)
+		}
+	}
+
+	now := time.Now()
+	referenced := make(map[string]bool)
+	for key, de := range s.index {
+		if de.Entry == nil {
+			delete(s.index, key)
+			continue
+		}
+		info, err := os.Stat(s.bodyPath(de.Entry.BodyRef))
+		if err != nil || info.Size() != de.Entry.BodySize || expired(de.Entry, now) {
+			delete(s.index, key)
+			continue
+		}
+		referenced[de.Entry.BodyRef] = true
+		s.size += de.Entry.Size()
+	}
+	files, err := os.ReadDir(s.bodyDir())
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if !referenced[f.Name()] {
+			os.Remove(filepath.Join(s.bodyDir(), f.Name()))
+		}
+	}
+	s.evict()
+	return s, nil
+}
+
+func (s *DiskStore) Get(key string) (*Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	de, ok := s.index[key]
+	if !ok {
+		return nil, false
+	}
+	now := time.Now()
+	if expired(de.Entry, now) {
+		s.remove(key)
+		return nil, false
+	}
+	de.LastAccess = now
+	s.dirty = true
+	return de.Entry, true
+}
+
+// Open returns the body file. A body removed after Get is still readable
+// through a file opened before the removal.
+func (s *DiskStore) Open(e *Entry) (io.ReadCloser, error) {
+	f, err := os.Open(s.bodyPath(e.BodyRef))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Put streams the body into a new file; Commit swaps it in.
+func (s *DiskStore) Put(key string, e *Entry) (BodyWriter, error) {
+	ref := utils.GenerateRandomID(24)
+	f, err := os.Create(s.bodyPath(ref))
+	if err != nil {
+		return nil, err
+	}
+	return &diskWriter{store: s, key: key, entry: e, ref: ref, file: f}, nil
+}
+
+func (s *DiskStore) Update(key string, e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	de, ok := s.index[key]
+	if !ok {
+		return ErrNotFound
+	}
+	s.size -= de.Entry.Size()
+	e.Key, e.BodyRef, e.BodySize = key, de.Entry.BodyRef, de.Entry.BodySize
+	de.Entry = e
+	de.LastAccess = time.Now()
+	s.size += e.Size()
+	s.dirty = true
+	return nil
+}
+
+func (s *DiskStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(key)
+}
+
+func (s *DiskStore) Purge(f PurgeFilter) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for key, de := range s.index {
+		if f.Matches(de.Entry) {
+			s.remove(key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Len returns the number of stored entries.
+func (s *DiskStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.index)
+}
+
+// Sweep removes expired entries and returns how many were dropped.
+func (s *DiskStore) Sweep(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for key, de := range s.index {
+		if expired(de.Entry, now) {
+			s.remove(key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Flush writes the index if it changed since the last flush.
+func (s *DiskStore) Flush() error {
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(s.index)
+	s.dirty = false
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(s.dir, diskIndexFile+".tmp")
+	if err = os.WriteFile(tmp, data, 0644); err == nil {
+		err = os.Rename(tmp, filepath.Join(s.dir, diskIndexFile))
+	}
+	if err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// StartJanitor sweeps expired entries and flushes the index every interval
+// until Close is called.
+func (s *DiskStore) StartJanitor(interval time.Duration) {
+	s.mu.Lock()
+	if s.stop != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.stop = make(chan struct{})
+	stop := s.stop
+	s.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				s.Sweep(now)
+				if err := s.Flush(); err != nil {
+					log.Printf("Could not write cache index: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the janitor and writes the index.
+func (s *DiskStore) Close() error {
+	s.mu.Lock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	s.mu.Unlock()
+	return s.Flush()
+}
+
+func (s *DiskStore) bodyDir() string {
+	return filepath.Join(s.dir, "bodies")
+}
+
+func (s *DiskStore) bodyPath(ref string) string {
+	return filepath.Join(s.bodyDir(), filepath.Base(ref))
+}
+
+// remove drops an entry and its body; the caller holds the lock.
+func (s *DiskStore) remove(key string) {
+	de, ok := s.index[key]
+	if !ok {
+		return
+	}
+	delete(s.index, key)
+	s.size -= de.Entry.Size()
+	s.dirty = true
+	os.Remove(s.bodyPath(de.Entry.BodyRef))
+}
+
+// evict removes least recently used entries until the store fits in
+// maxBytes, leaving some headroom so it does not run on every insert.
+func (s *DiskStore) evict() {
+	if s.size <= s.maxBytes {
+		return
+	}
+	keys := make([]string, 0, len(s.index))
+	for key := range s.index {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return s.index[keys[i]].LastAccess.Before(s.index[keys[j]].LastAccess)
+	})
+	target := s.maxBytes * 9 / 10
+	for _, key := range keys {
+		if s.size <= target {
+			break
+		}
+		s.remove(key)
+	}
+}
+
+type diskWriter struct {
+	store *DiskStore
+	key   string
+	entry *Entry
+	ref   string
+	file  *os.File
+	size  int64
+}
+
+func (w *diskWriter) Write(p []byte) (int, error) {
+	if w.size+int64(len(p)) > w.store.maxBytes {
+		return 0, ErrTooLarge
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *diskWriter) Commit() error {
+	if err := w.file.Close(); err != nil {
+		os.Remove(w.file.Name())
+		return err
+	}
+
+	s := w.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(w.key)
+	w.entry.Key, w.entry.BodyRef, w.entry.BodySize = w.key, w.ref, w.size
+	s.index[w.key] = &diskEntry{Entry: w.entry, LastAccess: time.Now()}
+	s.size += w.entry.Size()
+	s.dirty = true
+	s.evict()
+	return nil
+}
+
+func (w *diskWriter) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Directives holds the parsed Cache-Control header. Directives without a
+// value map to "".
+type Directives map[string]string
+
+// ParseCacheControl parses every Cache-Control header in h.
+func ParseCacheControl(h http.Header) Directives {
+	d := Directives{}
+	for _, v := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(part, "=")
+			d[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return d
+}
+
+// Has reports whether the directive is present.
+func (d Directives) Has(name string) bool {
+	_, ok := d[name]
+	return ok
+}
+
+// Seconds returns a delta-seconds directive.
+func (d Directives) Seconds(name string) (time.Duration, bool) {
+	v, ok := d[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// heuristicStatus lists the status codes RFC 9110 makes heuristically
+// cacheable.
+var heuristicStatus = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// Storable reports whether a shared cache may store the response to r.
+func Storable(r *http.Request, status int, h http.Header) bool {
+	if r.Method != http.MethodGet || status == http.StatusNotModified || status == http.StatusPartialContent {
+		return false
+	}
+	req := ParseCacheControl(r.Header)
+	res := ParseCacheControl(h)
+	if req.Has("no-store") || res.Has("no-store") || res.Has("private") {
+		return false
+	}
+	if h.Get("Set-Cookie") != "" {
+		return false
+	}
+	if r.Header.Get("Authorization") != "" && !res.Has("public") && !res.Has("s-maxage") && !res.Has("must-revalidate") {
+		return false
+	}
+	if _, ok := ParseVary(h); !ok {
+		return false
+	}
+	if heuristicStatus[status] {
+		return true
+	}
+	_, explicit := explicitLifetime(res, h)
+	return explicit
+}
+
+// Lifetime returns how long the entry is fresh for. defaultTTL applies when
+// the response gives no explicit freshness information.
+func (e *Entry) Lifetime(defaultTTL time.Duration) time.Duration {
+	res := ParseCacheControl(e.Header)
+	if res.Has("no-cache") {
+		return 0
+	}
+	if lifetime, ok := explicitLifetime(res, e.Header); ok {
+		return lifetime
+	}
+	if defaultTTL > 0 {
+		return defaultTTL
+	}
+	// Heuristic freshness: 10% of the time since last modification.
+	if !heuristicStatus[e.Status] {
+		return 0
+	}
+	date := headerTime(e.Header, "Date", e.ResponseTime)
+	if modified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil && date.After(modified) {
+		return date.Sub(modified) / 10
+	}
+	return 0
+}
+
+func explicitLifetime(res Directives, h http.Header) (time.Duration, bool) {
+	if v, ok := res.Seconds("s-maxage"); ok {
+		return v, true
+	}
+	if v, ok := res.Seconds("max-age"); ok {
+		return v, true
+	}
+	if v := h.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			// Invalid dates, such as "0", mean already expired.
+			return 0, true
+		}
+		date := headerTime(h, "Date", time.Now())
+		if expires.Before(date) {
+			return 0, true
+		}
+		return expires.Sub(date), true
+	}
+	return 0, false
+}
+
+// Age is the entry's current age per RFC 9111 section 4.2.3.
+func (e *Entry) Age(now time.Time) time.Duration {
+	date := headerTime(e.Header, "Date", e.ResponseTime)
+	apparent := e.ResponseTime.Sub(date)
+	if apparent < 0 {
+		apparent = 0
+	}
+	var ageValue time.Duration
+	if n, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && n > 0 {
+		ageValue = time.Duration(n) * time.Second
+	}
+	corrected := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	if apparent > corrected {
+		corrected = apparent
+	}
+	return corrected + now.Sub(e.ResponseTime)
+}
+
+// Freshness describes how an entry may be used to answer a request.
+type Freshness struct {
+	Age   time.Duration
+	Fresh bool
+	// MayServeStale permits serving the stale entry while it is revalidated
+	// in the background (stale-while-revalidate).
+	MayServeStale bool
+	// MayServeOnError permits serving the stale entry when revalidation
+	// fails (stale-if-error).
+	MayServeOnError bool
+}
+
+// Check applies the request's and the entry's cache directives.
+func (e *Entry) Check(r *http.Request, defaultTTL, defaultSWR, defaultSIE time.Duration, now time.Time) Freshness {
+	req := ParseCacheControl(r.Header)
+	res := ParseCacheControl(e.Header)
+	age := e.Age(now)
+	lifetime := e.Lifetime(defaultTTL)
+
+	f := Freshness{Age: age}
+	if req.Has("no-cache") || pragmaNoCache(r) {
+		return f
+	}
+	if maxAge, ok := req.Seconds("max-age"); ok && age > maxAge {
+		return f
+	}
+	minFresh, _ := req.Seconds("min-fresh")
+	f.Fresh = age+minFresh < lifetime
+	if f.Fresh {
+		return f
+	}
+
+	staleness := age - lifetime
+	if res.Has("must-revalidate") || res.Has("proxy-revalidate") || res.Has("no-cache") {
+		return f
+	}
+	if maxStale, ok := req["max-stale"]; ok {
+		if limit, ok := req.Seconds("max-stale"); maxStale == "" || (ok && staleness <= limit) {
+			f.Fresh = true
+			return f
+		}
+	}
+	swr, ok := res.Seconds("stale-while-revalidate")
+	if !ok {
+		swr = defaultSWR
+	}
+	sie, ok := res.Seconds("stale-if-error")
+	if !ok {
+		sie = defaultSIE
+	}
+	if v, ok := req.Seconds("stale-if-error"); ok {
+		sie = v
+	}
+	f.MayServeStale = staleness <= swr
+	f.MayServeOnError = staleness <= sie
+	return f
+}
+
+func pragmaNoCache(r *http.Request) bool {
+	return r.Header.Get("Cache-Control") == "" && strings.Contains(strings.ToLower(r.Header.Get("Pragma")), "no-cache")
+}
+
+func headerTime(h http.Header, name string, fallback time.Time) time.Time {
+	if t, err := http.ParseTime(h.Get(name)); err == nil {
+		return t
+	}
+	return fallback
+}
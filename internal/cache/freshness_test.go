@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func entryWith(cacheControl string, storedAt time.Time) *Entry {
+	h := http.Header{}
+	h.Set("Cache-Control", cacheControl)
+	h.Set("Date", storedAt.UTC().Format(http.TimeFormat))
+	return &Entry{Status: 200, Header: h, RequestTime: storedAt, ResponseTime: storedAt}
+}
+
+func TestCheck_Freshness(t *testing.T) {
+	stored := time.Now().Truncate(time.Second)
+	r := httptest.NewRequest("GET", "/", nil)
+
+	e := entryWith("max-age=60, stale-while-revalidate=30, stale-if-error=300", stored)
+	if !e.Check(r, 0, 0, 0, stored.Add(30*time.Second)).Fresh {
+		t.Errorf("Expected entry to be fresh within max-age")
+	}
+	f := e.Check(r, 0, 0, 0, stored.Add(80*time.Second))
+	if f.Fresh || !f.MayServeStale || !f.MayServeOnError {
+		t.Errorf("Expected stale entry usable for revalidation and errors, got %+v", f)
+	}
+	f = e.Check(r, 0, 0, 0, stored.Add(120*time.Second))
+	if f.MayServeStale || !f.MayServeOnError {
+		t.Errorf("Expected only stale-if-error past the revalidate window, got %+v", f)
+	}
+
+	strict := entryWith("max-age=60, must-revalidate, stale-while-revalidate=30", stored)
+	if f := strict.Check(r, 0, 0, 0, stored.Add(80*time.Second)); f.MayServeStale {
+		t.Errorf("Expected must-revalidate to forbid stale responses")
+	}
+
+	r.Header.Set("Cache-Control", "max-age=10")
+	if e.Check(r, 0, 0, 0, stored.Add(30*time.Second)).Fresh {
+		t.Errorf("Expected request max-age to reject an older entry")
+	}
+}
+
+func TestStorable(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	h := http.Header{}
+	h.Set("Cache-Control", "private, max-age=60")
+	if Storable(r, 200, h) {
+		t.Errorf("Expected private responses not to be stored")
+	}
+
+	h = http.Header{}
+	h.Set("Vary", "*")
+	if Storable(r, 200, h) {
+		t.Errorf("Expected Vary: * responses not to be stored")
+	}
+
+	r.Header.Set("Authorization", "Bearer x")
+	h = http.Header{}
+	h.Set("Cache-Control", "max-age=60")
+	if Storable(r, 200, h) {
+		t.Errorf("Expected authorized responses without public not to be stored")
+	}
+	h.Set("Cache-Control", "public, max-age=60")
+	if !Storable(r, 200, h) {
+		t.Errorf("Expected public authorized responses to be stored")
+	}
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultMaxBytes bounds a store when no size is configured.
+const DefaultMaxBytes = 64 << 20
+
+// MemoryStore keeps entries in process memory, dropping the least recently
+// used ones once the total size passes maxBytes.
+type MemoryStore struct {
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	size     int64
+	maxBytes int64
+}
+
+type memoryEntry struct {
+	entry *Entry
+	body  []byte
+}
+
+func NewMemoryStore(maxBytes int64) *MemoryStore {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	return &MemoryStore{entries: make(map[string]*list.Element), lru: list.New(), maxBytes: maxBytes}
+}
+
+func (s *MemoryStore) Get(key string) (*Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if e := el.Value.(*memoryEntry).entry; expired(e, time.Now()) {
+		s.remove(el)
+		return nil, false
+	}
+	s.lru.MoveToFront(el)
+	return el.Value.(*memoryEntry).entry, true
+}
+
+func (s *MemoryStore) Open(e *Entry) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[e.Key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(el.Value.(*memoryEntry).body)), nil
+}
+
+func (s *MemoryStore) Put(key string, e *Entry) (BodyWriter, error) {
+	return &memoryWriter{store: s, key: key, entry: e}, nil
+}
+
+func (s *MemoryStore) Update(key string, e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return ErrNotFound
+	}
+	body := el.Value.(*memoryEntry).body
+	s.remove(el)
+	s.insert(key, e, body)
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+}
+
+func (s *MemoryStore) Purge(f PurgeFilter) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for el := s.lru.Front(); el != nil; {
+		next := el.Next()
+		if f.Matches(el.Value.(*memoryEntry).entry) {
+			s.remove(el)
+			removed++
+		}
+		el = next
+	}
+	return removed
+}
+
+// Len returns the number of stored entries.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// insert stores an entry; the caller holds the lock. Entries larger than
+// the whole store are ignored.
+func (s *MemoryStore) insert(key string, e *Entry, body []byte) {
+	e.Key = key
+	e.BodySize = int64(len(body))
+	size := e.Size()
+	if size > s.maxBytes {
+		return
+	}
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+	s.entries[key] = s.lru.PushFront(&memoryEntry{entry: e, body: body})
+	s.size += size
+	for s.size > s.maxBytes {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *MemoryStore) remove(el *list.Element) {
+	e := s.lru.Remove(el).(*memoryEntry).entry
+	delete(s.entries, e.Key)
+	s.size -= e.Size()
+}
+
+type memoryWriter struct {
+	store *MemoryStore
+	key   string
+	entry *Entry
+	buf   bytes.Buffer
+}
+
+func (w *memoryWriter) Write(p []byte) (int, error) {
+	if int64(w.buf.Len()+len(p)) > w.store.maxBytes {
+		return 0, ErrTooLarge
+	}
+	return w.buf.Write(p)
+}
+
+func (w *memoryWriter) Commit() error {
+	w.store.mu.Lock()
+	defer w.store.mu.Unlock()
+	w.store.insert(w.key, w.entry, w.buf.Bytes())
+	return nil
+}
+
+func (w *memoryWriter) Abort() {
+	w.buf.Reset()
+}
@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"time"
+	"zentro/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisOptions configures the connection to a Redis-protocol server.
+type RedisOptions struct {
+	Addr      string
+	Username  string
+	Password  string
+	DB        int
+	KeyPrefix string
+	Timeout   time.Duration
+}
+
+// RedisStore shares cached responses between gateway replicas. Metadata and
+// bodies are separate keys; bodies are appended and read in chunks so large
+// responses are never held in memory whole. Keys expire with their entries;
+// size is bounded by the server's maxmemory policy.
+type RedisStore struct {
+	client  *redis.Client
+	prefix  string
+	timeout time.Duration
+}
+
+const (
+	redisChunkSize = 256 << 10
+	// Bodies of replaced entries linger this long for readers still
+	// streaming them.
+	redisBodyGrace = time.Minute
+)
+
+func NewRedisStore(opts RedisOptions) *RedisStore {
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	if opts.KeyPrefix == "" {
+		opts.KeyPrefix = "zentro:cache:"
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:         opts.Addr,
+		Username:     opts.Username,
+		Password:     opts.Password,
+		DB:           opts.DB,
+		DialTimeout:  opts.Timeout,
+		ReadTimeout:  opts.Timeout,
+		WriteTimeout: opts.Timeout,
+	})
+	return &RedisStore{client: client, prefix: opts.KeyPrefix, timeout: opts.Timeout}
+}
+
+func (s *RedisStore) ctx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.timeout)
+}
+
+func (s *RedisStore) metaKey(key string) string { return s.prefix + "m:" + key }
+func (s *RedisStore) bodyKey(ref string) string { return s.prefix + "b:" + ref }
+
+func (s *RedisStore) Get(key string) (*Entry, bool) {
+	ctx, cancel := s.ctx()
+	defer cancel()
+	data, err := s.client.Get(ctx, s.metaKey(key)).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Cache lookup failed: %v", err)
+		}
+		return nil, false
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil || expired(&e, time.Now()) {
+		return nil, false
+	}
+	return &e, true
+}
+
+func (s *RedisStore) Open(e *Entry) (io.ReadCloser, error) {
+	return &redisReader{store: s, key: s.bodyKey(e.BodyRef), size: e.BodySize}, nil
+}
+
+func (s *RedisStore) Put(key string, e *Entry) (BodyWriter, error) {
+	return &redisWriter{store: s, key: key, entry: e, ref: utils.GenerateRandomID(24)}, nil
+}
+
+func (s *RedisStore) Update(key string, e *Entry) error {
+	old, ok := s.Get(key)
+	if !ok {
+		return ErrNotFound
+	}
+	e.Key, e.BodyRef, e.BodySize = key, old.BodyRef, old.BodySize
+	return s.writeMeta(key, e, "")
+}
+
+// writeMeta stores e and aligns its body's expiry with it. A replaced body
+// is kept briefly for readers still streaming it.
+func (s *RedisStore) writeMeta(key string, e *Entry, replaced string) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := s.ctx()
+	defer cancel()
+	_, err = s.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, s.metaKey(key), data, 0)
+		if !e.ExpiresAt.IsZero() {
+			p.PExpireAt(ctx, s.metaKey(key), e.ExpiresAt)
+			p.PExpireAt(ctx, s.bodyKey(e.BodyRef), e.ExpiresAt)
+		} else {
+			p.Persist(ctx, s.bodyKey(e.BodyRef))
+		}
+		if replaced != "" && replaced != e.BodyRef {
+			p.PExpire(ctx, s.bodyKey(replaced), redisBodyGrace)
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) Delete(key string) {
+	e, ok := s.Get(key)
+	ctx, cancel := s.ctx()
+	defer cancel()
+	s.client.Del(ctx, s.metaKey(key))
+	if ok {
+		s.client.PExpire(ctx, s.bodyKey(e.BodyRef), redisBodyGrace)
+	}
+}
+
+// Purge scans every entry; it is meant for occasional administrative use.
+func (s *RedisStore) Purge(f PurgeFilter) int {
+	ctx := context.Background()
+	removed := 0
+	iter := s.client.Scan(ctx, 0, s.metaKey("*"), 500).Iterator()
+	for iter.Next(ctx) {
+		data, err := s.client.Get(ctx, iter.Val()).Bytes()
+		if err != nil {
+			continue
+		}
+		var e Entry
+		if json.Unmarshal(data, &e) != nil || !f.Matches(&e) {
+			continue
+		}
+		s.client.Del(ctx, iter.Val())
+		s.client.PExpire(ctx, s.bodyKey(e.BodyRef), redisBodyGrace)
+		removed++
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("Cache purge stopped early: %v", err)
+	}
+	return removed
+}
+
+// Ping checks that the server is reachable.
+func (s *RedisStore) Ping() error {
+	ctx, cancel := s.ctx()
+	defer cancel()
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// redisWriter appends chunks to a fresh body key that only becomes
+// reachable when the metadata pointing at it is written on Commit.
+type redisWriter struct {
+	store *RedisStore
+	key   string
+	entry *Entry
+	ref   string
+	size  int64
+	buf   []byte
+}
+
+func (w *redisWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= redisChunkSize {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *redisWriter) flush() error {
+	ctx, cancel := w.store.ctx()
+	defer cancel()
+	bodyKey := w.store.bodyKey(w.ref)
+	_, err := w.store.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Append(ctx, bodyKey, string(w.buf))
+		// Abandoned uploads clean themselves up.
+		p.PExpire(ctx, bodyKey, redisBodyGrace)
+		return nil
+	})
+	w.size += int64(len(w.buf))
+	w.buf = w.buf[:0]
+	return err
+}
+
+func (w *redisWriter) Commit() error {
+	if err := w.flush(); err != nil {
+		w.Abort()
+		return err
+	}
+	var replaced string
+	if old, ok := w.store.Get(w.key); ok {
+		replaced = old.BodyRef
+	}
+	w.entry.Key, w.entry.BodyRef, w.entry.BodySize = w.key, w.ref, w.size
+	return w.store.writeMeta(w.key, w.entry, replaced)
+}
+
+func (w *redisWriter) Abort() {
+	ctx, cancel := w.store.ctx()
+	defer cancel()
+	w.store.client.Del(ctx, w.store.bodyKey(w.ref))
+}
+
+// redisReader streams a body with GETRANGE.
+type redisReader struct {
+	store  *RedisStore
+	key    string
+	size   int64
+	offset int64
+	buf    []byte
+}
+
+func (r *redisReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		if r.offset >= r.size {
+			return 0, io.EOF
+		}
+		ctx, cancel := r.store.ctx()
+		chunk, err := r.store.client.GetRange(ctx, r.key, r.offset, r.offset+redisChunkSize-1).Bytes()
+		cancel()
+		if err != nil {
+			return 0, err
+		}
+		if len(chunk) == 0 {
+			return 0, ErrNotFound
+		}
+		r.offset += int64(len(chunk))
+		r.buf = chunk
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *redisReader) Close() error {
+	return nil
+}
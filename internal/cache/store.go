@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when an entry's body is no longer stored.
+	ErrNotFound = errors.New("cache entry not found")
+	// ErrTooLarge is returned when a body cannot fit in the store.
+	ErrTooLarge = errors.New("cache entry too large")
+)
+
+// CacheStore keeps cached responses. Bodies are streamed in and out so
+// backends need not hold them in memory.
+type CacheStore interface {
+	// Get returns the metadata stored under key, skipping expired entries.
+	Get(key string) (*Entry, bool)
+	// Open returns the body of an entry returned by Get.
+	Open(e *Entry) (io.ReadCloser, error)
+	// Put starts storing e under key. The entry becomes visible once the
+	// body has been written and committed.
+	Put(key string, e *Entry) (BodyWriter, error)
+	// Update replaces the metadata stored under key, keeping its body.
+	Update(key string, e *Entry) error
+	Delete(key string)
+	// Purge drops every entry matched by f and returns how many were removed.
+	Purge(f PurgeFilter) int
+}
+
+// BodyWriter receives an entry's body. Exactly one of Commit or Abort must
+// be called.
+type BodyWriter interface {
+	io.Writer
+	Commit() error
+	Abort()
+}
+
+// Set stores an entry with the given body in one step.
+func Set(s CacheStore, key string, e *Entry, body []byte) error {
+	w, err := s.Put(key, e)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		w.Abort()
+		return err
+	}
+	return w.Commit()
+}
+
+// expired reports whether e has passed its ExpiresAt.
+func expired(e *Entry, now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && now.After(e.ExpiresAt)
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func readBody(t *testing.T, s CacheStore, e *Entry) string {
+	t.Helper()
+	body, err := s.Open(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestStores_RoundTrip(t *testing.T) {
+	mr := miniredis.RunT(t)
+	disk, err := OpenDiskStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := map[string]CacheStore{
+		"memory": NewMemoryStore(0),
+		"disk":   disk,
+		"redis":  NewRedisStore(RedisOptions{Addr: mr.Addr()}),
+	}
+	large := strings.Repeat("x", 3*redisChunkSize+17)
+
+	for name, s := range stores {
+		w, err := s.Put("k", &Entry{Status: 200, Path: "/a", Tags: []string{"t1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < len(large); i += 1000 {
+			end := i + 1000
+			if end > len(large) {
+				end = len(large)
+			}
+			w.Write([]byte(large[i:end]))
+		}
+		if err := w.Commit(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		e, ok := s.Get("k")
+		if !ok || e.BodySize != int64(len(large)) {
+			t.Fatalf("%s: expected stored entry, got %+v", name, e)
+		}
+		if got := readBody(t, s, e); got != large {
+			t.Errorf("%s: body mismatch (%d bytes)", name, len(got))
+		}
+
+		if err := s.Update("k", &Entry{Status: 200, Path: "/a", Tags: []string{"t2"}}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		e, _ = s.Get("k")
+		if readBody(t, s, e) != large || e.Tags[0] != "t2" {
+			t.Errorf("%s: expected update to keep the body", name)
+		}
+
+		if n := s.Purge(PurgeFilter{Tag: "t2"}); n != 1 {
+			t.Errorf("%s: expected one purged entry, got %d", name, n)
+		}
+		if _, ok := s.Get("k"); ok {
+			t.Errorf("%s: expected entry to be purged", name)
+		}
+
+		Set(s, "old", &Entry{Status: 200, ExpiresAt: time.Now().Add(-time.Second)}, []byte("x"))
+		if _, ok := s.Get("old"); ok {
+			t.Errorf("%s: expected expired entry to be skipped", name)
+		}
+	}
+}
+
+func TestDiskStore_SurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenDiskStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Set(s, "k", &Entry{Status: 200}, []byte("persisted"))
+	w, _ := s.Put("unfinished", &Entry{Status: 200})
+	w.Write([]byte("partial"))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = OpenDiskStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok := s.Get("k")
+	if !ok || readBody(t, s, e) != "persisted" {
+		t.Errorf("Expected entry to survive a restart")
+	}
+	if s.Len() != 1 {
+		t.Errorf("Expected only committed entries after restart, got %d", s.Len())
+	}
+}
+
+func TestDiskStore_EvictsBySize(t *testing.T) {
+	s, err := OpenDiskStore(t.TempDir(), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(strings.Repeat("x", 300))
+	for _, key := range []string{"a", "b", "c", "d"} {
+		Set(s, key, &Entry{Status: 200}, body)
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := s.Get("a"); ok {
+		t.Errorf("Expected the least recently used entry to be evicted")
+	}
+	if _, ok := s.Get("d"); !ok {
+		t.Errorf("Expected the newest entry to be kept")
+	}
+}
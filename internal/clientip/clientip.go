@@ -0,0 +1,164 @@
+// Package clientip works out which address a request came from when the
+// gateway sits behind proxies or load balancers.
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Ranges is a list of IPv4 and IPv6 networks.
+type Ranges []netip.Prefix
+
+// ParseRanges parses CIDRs and bare addresses, which stand for a single
+// host.
+func ParseRanges(entries []string) (Ranges, error) {
+	ranges := make(Ranges, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q", entry)
+			}
+			ranges = append(ranges, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address %q", entry)
+		}
+		addr = addr.Unmap()
+		ranges = append(ranges, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return ranges, nil
+}
+
+// MustParseRanges parses entries that were validated when they were
+// loaded, skipping any that are invalid.
+func MustParseRanges(entries []string) Ranges {
+	var ranges Ranges
+	for _, entry := range entries {
+		if parsed, err := ParseRanges([]string{entry}); err == nil {
+			ranges = append(ranges, parsed...)
+		}
+	}
+	return ranges
+}
+
+// Contains reports whether addr is in one of the ranges.
+func (r Ranges) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range r {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the client address of r. Forwarding headers are only
+// believed when the connecting peer is a trusted proxy; the chain they
+// describe is then walked from the gateway outwards, and the first address
+// that is not a trusted proxy is the client.
+func Resolve(r *http.Request, trusted Ranges) netip.Addr {
+	peer := parseHost(r.RemoteAddr)
+	if !peer.IsValid() || !trusted.Contains(peer) {
+		return peer
+	}
+
+	chain := forwardedFor(r.Header)
+	if chain == nil {
+		chain = headerList(r.Header, "X-Forwarded-For")
+	}
+	if chain == nil {
+		if real := parseHost(strings.TrimSpace(r.Header.Get("X-Real-IP"))); real.IsValid() {
+			return real
+		}
+		return peer
+	}
+
+	client := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		hop := parseHost(chain[i])
+		if !hop.IsValid() {
+			// Unknown or obfuscated hops cannot be followed further.
+			break
+		}
+		client = hop
+		if !trusted.Contains(hop) {
+			break
+		}
+	}
+	return client
+}
+
+// forwardedFor returns the for= parameters of the Forwarded headers
+// (RFC 7239), or nil when there are none.
+func forwardedFor(h http.Header) []string {
+	var chain []string
+	for _, element := range headerList(h, "Forwarded") {
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(key, "for") {
+				chain = append(chain, strings.Trim(value, `"`))
+			}
+		}
+	}
+	return chain
+}
+
+// headerList splits every line of a comma-separated header.
+func headerList(h http.Header, name string) []string {
+	var list []string
+	for _, line := range h.Values(name) {
+		for _, item := range strings.Split(line, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// parseHost parses an address with or without a port. IPv6 addresses may
+// be bracketed.
+func parseHost(s string) netip.Addr {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap().WithZone("")
+}
+
+type contextKey struct{}
+
+// WithAddr returns a copy of ctx carrying the resolved client address.
+func WithAddr(ctx context.Context, addr netip.Addr) context.Context {
+	return context.WithValue(ctx, contextKey{}, addr)
+}
+
+// FromRequest returns the client address resolved for r, falling back to
+// the connecting peer.
+func FromRequest(r *http.Request) netip.Addr {
+	if addr, ok := r.Context().Value(contextKey{}).(netip.Addr); ok {
+		return addr
+	}
+	return parseHost(r.RemoteAddr)
+}
+
+// String returns the client address of r as text, or the raw peer address
+// when it cannot be parsed.
+func String(r *http.Request) string {
+	if addr := FromRequest(r); addr.IsValid() {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,55 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	trusted, err := ParseRanges([]string{"10.0.0.0/8", "2001:db8::/32"})
+	if err != nil {
+		t.Fatalf("Expected ranges to parse: %v", err)
+	}
+
+	cases := map[string]struct {
+		peer    string
+		headers map[string]string
+		want    string
+	}{
+		"untrusted peer":     {"203.0.113.9:1234", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "203.0.113.9"},
+		"forwarded for":      {"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.7, 10.0.0.2"}, "198.51.100.7"},
+		"all trusted":        {"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.1.1.1, 10.0.0.2"}, "10.1.1.1"},
+		"forwarded header":   {"[2001:db8::1]:443", map[string]string{"Forwarded": `for=192.0.2.60;proto=https, for="[2001:db8::5]:4711"`}, "192.0.2.60"},
+		"unknown hop":        {"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, unknown, 10.0.0.2"}, "10.0.0.2"},
+		"real ip":            {"10.0.0.1:1234", map[string]string{"X-Real-IP": "198.51.100.7"}, "198.51.100.7"},
+		"mapped ipv4 peer":   {"[::ffff:203.0.113.9]:80", nil, "203.0.113.9"},
+		"forwarded over xff": {"10.0.0.1:1234", map[string]string{"Forwarded": "for=192.0.2.1", "X-Forwarded-For": "192.0.2.2"}, "192.0.2.1"},
+	}
+	for name, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = c.peer
+		for k, v := range c.headers {
+			r.Header.Set(k, v)
+		}
+		if got := Resolve(r, trusted).String(); got != c.want {
+			t.Errorf("%s: expected %s, got %s", name, c.want, got)
+		}
+	}
+}
+
+func TestParseRanges(t *testing.T) {
+	ranges, err := ParseRanges([]string{"192.168.1.7", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("Expected ranges to parse: %v", err)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	for addr, want := range map[string]bool{"192.168.1.7:1": true, "192.168.1.8:1": false, "[fd12::1]:1": true} {
+		r.RemoteAddr = addr
+		if ranges.Contains(FromRequest(r)) != want {
+			t.Errorf("Expected %s in ranges to be %v", addr, want)
+		}
+	}
+	if _, err := ParseRanges([]string{"10.0.0.0/33"}); err == nil {
+		t.Errorf("Expected an invalid CIDR to be rejected")
+	}
+}
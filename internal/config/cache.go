@@ -0,0 +1,20 @@
+package config
+
+// Response cache backends.
+const (
+	CacheStoreMemory = "memory"
+	CacheStoreDisk   = "disk"
+	CacheStoreRedis  = "redis"
+)
+
+// CacheStore selects where Cache filters keep responses. Changes take effect
+// on restart.
+type CacheStore struct {
+	// Store is "memory" (default), "disk" or "redis".
+	Store string `json:"store,omitempty"`
+	// MaxBytes bounds the memory and disk stores; it defaults to 64 MiB.
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+	// Dir holds the disk store's bodies and index.
+	Dir   string     `json:"dir,omitempty"`
+	Redis RedisStore `json:"redis,omitempty"`
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"zentro/internal/filters"
 	"zentro/utils"
 )
 
@@ -11,12 +12,55 @@ import (
 type Consumer struct {
 	Id       string `json:"id"`
 	Username string `json:"username"`
-	ApiKey   string `json:"apiKey"`
+	// ApiKey is only read for backwards compatibility: on load it is turned
+	// into a hashed credential. Responses use it to return a new key once.
+	ApiKey      string       `json:"apiKey,omitempty"`
+	Groups      []string                `json:"groups,omitempty"`
+	Credentials []Credential            `json:"credentials,omitempty"`
+	RateLimit   *filters.ConsumerLimits `json:"rate_limit,omitempty"`
+}
+
+// ConsumerGroup is a named set of consumers that route ACLs can refer to.
+type ConsumerGroup struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
 }
 
 // ConsumerConfig holds the list of all consumers.
 type ConsumerConfig struct {
-	Consumers []Consumer `json:"consumers"`
+	Consumers []Consumer      `json:"consumers"`
+	Groups    []ConsumerGroup `json:"groups,omitempty"`
+}
+
+// InGroup reports whether the consumer is a member of group.
+func (c Consumer) InGroup(group string) bool {
+	for _, g := range c.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// HasGroup reports whether a group with the given name is defined.
+func (cfg *ConsumerConfig) HasGroup(name string) bool {
+	for _, g := range cfg.Groups {
+		if g.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Members returns the consumers that belong to group.
+func (cfg *ConsumerConfig) Members(group string) []Consumer {
+	members := []Consumer{}
+	for _, c := range cfg.Consumers {
+		if c.InGroup(group) {
+			members = append(members, c)
+		}
+	}
+	return members
 }
 
 // LoadConsumers reads and parses the consumers.json file.
@@ -35,8 +79,12 @@ func LoadConsumers(path string) (*ConsumerConfig, error) {
 		if cfg.Consumers[i].Id == "" {
 			cfg.Consumers[i].Id = utils.GenerateRandomID(16)
 		}
-		if cfg.Consumers[i].ApiKey == "" {
-			cfg.Consumers[i].ApiKey = "zen_" + utils.GenerateRandomID(32)
+		cfg.Consumers[i].migrateApiKey()
+		// Groups referenced only by membership are defined implicitly.
+		for _, g := range cfg.Consumers[i].Groups {
+			if !cfg.HasGroup(g) {
+				cfg.Groups = append(cfg.Groups, ConsumerGroup{Name: g})
+			}
 		}
 	}
 
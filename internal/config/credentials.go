@@ -0,0 +1,158 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"time"
+	"zentro/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	CredentialApiKey = "api-key"
+	CredentialJwt    = "jwt"
+	CredentialBasic  = "basic"
+	CredentialHmac   = "hmac"
+	CredentialMtls   = "mtls"
+
+	CredentialActive  = "active"
+	CredentialRevoked = "revoked"
+
+	// CredentialPrefixLength is how many leading characters of a secret are
+	// kept in clear text so a presented key can be found without a full scan.
+	CredentialPrefixLength = 12
+)
+
+// Credential is one way a consumer can authenticate. Secrets are never
+// stored; only their SHA-256 hash and a short lookup prefix are kept. HMAC
+// keys are the exception, as verifying a signature needs the key itself.
+type Credential struct {
+	Id         string     `json:"id"`
+	Type       string     `json:"type"`
+	Prefix     string     `json:"prefix,omitempty"`
+	Hash       string     `json:"hash,omitempty"`
+	KeyId      string     `json:"key_id,omitempty"`
+	PublicKey  string     `json:"public_key,omitempty"`
+	Secret     string     `json:"secret,omitempty"`
+	Subject    string     `json:"subject,omitempty"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RotatedTo  string     `json:"rotated_to,omitempty"`
+}
+
+// HashSecret returns the hex encoded SHA-256 digest of a credential secret.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// SecretPrefix returns the lookup prefix for a secret.
+func SecretPrefix(secret string) string {
+	if len(secret) <= CredentialPrefixLength {
+		return secret
+	}
+	return secret[:CredentialPrefixLength]
+}
+
+// NewSecretCredential generates a random secret for an api-key or basic
+// credential. The plaintext secret is returned once and never persisted.
+func NewSecretCredential(credType string, expiresAt *time.Time) (Credential, string) {
+	secret := "zen_" + utils.GenerateRandomID(32)
+	return Credential{
+		Id:        utils.GenerateRandomID(16),
+		Type:      credType,
+		Prefix:    SecretPrefix(secret),
+		Hash:      HashSecret(secret),
+		Status:    CredentialActive,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}, secret
+}
+
+// Matches reports whether secret hashes to the stored credential hash.
+func (c Credential) Matches(secret string) bool {
+	if c.Hash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.Hash), []byte(HashSecret(secret))) == 1
+}
+
+// IsUsable reports whether the credential is active and not yet expired.
+func (c Credential) IsUsable(now time.Time) bool {
+	if c.Status != CredentialActive {
+		return false
+	}
+	return c.ExpiresAt == nil || now.Before(*c.ExpiresAt)
+}
+
+// JwtKeyId returns the "kid" header of a JWT without verifying it.
+func JwtKeyId(token string) string {
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return ""
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+// VerifyJwt checks the token signature against the credential's PEM encoded
+// public key and validates its registered claims.
+func (c Credential) VerifyJwt(token string) bool {
+	if c.Type != CredentialJwt || c.PublicKey == "" {
+		return false
+	}
+	pem := []byte(c.PublicKey)
+	_, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return jwt.ParseRSAPublicKeyFromPEM(pem)
+		case *jwt.SigningMethodECDSA:
+			return jwt.ParseECPublicKeyFromPEM(pem)
+		case *jwt.SigningMethodEd25519:
+			return jwt.ParseEdPublicKeyFromPEM(pem)
+		default:
+			return nil, jwt.ErrTokenUnverifiable
+		}
+	})
+	return err == nil
+}
+
+// FindCredential returns a pointer to the consumer's credential with the given id.
+func (c *Consumer) FindCredential(id string) *Credential {
+	for i := range c.Credentials {
+		if c.Credentials[i].Id == id {
+			return &c.Credentials[i]
+		}
+	}
+	return nil
+}
+
+// migrateApiKey turns the legacy plaintext apiKey field into a hashed
+// credential so the key is dropped the next time the file is written. Until
+// then the file is migrated on every load, so the id is derived from the
+// hash to stay the same across loads.
+func (c *Consumer) migrateApiKey() {
+	if c.ApiKey == "" {
+		return
+	}
+	hash := HashSecret(c.ApiKey)
+	for _, cred := range c.Credentials {
+		if cred.Hash == hash {
+			c.ApiKey = ""
+			return
+		}
+	}
+	c.Credentials = append(c.Credentials, Credential{
+		Id:        hash[:16],
+		Type:      CredentialApiKey,
+		Prefix:    SecretPrefix(c.ApiKey),
+		Hash:      hash,
+		Status:    CredentialActive,
+		CreatedAt: time.Now().UTC(),
+	})
+	c.ApiKey = ""
+}
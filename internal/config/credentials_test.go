@@ -0,0 +1,63 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewSecretCredential_StoresOnlyHash(t *testing.T) {
+	cred, secret := NewSecretCredential(CredentialApiKey, nil)
+	if cred.Hash == secret || cred.Hash == "" {
+		t.Error("Expected credential to store a hash of the secret")
+	}
+	if cred.Prefix != secret[:CredentialPrefixLength] {
+		t.Errorf("Expected prefix %q, got %q", secret[:CredentialPrefixLength], cred.Prefix)
+	}
+	if !cred.Matches(secret) {
+		t.Error("Expected credential to match its own secret")
+	}
+	if cred.Matches(secret + "x") {
+		t.Error("Expected credential not to match a different secret")
+	}
+}
+
+func TestCredential_IsUsable(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	if !(Credential{Status: CredentialActive}).IsUsable(now) {
+		t.Error("Expected active credential without expiry to be usable")
+	}
+	if !(Credential{Status: CredentialActive, ExpiresAt: &future}).IsUsable(now) {
+		t.Error("Expected credential in its grace period to be usable")
+	}
+	if (Credential{Status: CredentialActive, ExpiresAt: &past}).IsUsable(now) {
+		t.Error("Expected expired credential to be unusable")
+	}
+	if (Credential{Status: CredentialRevoked}).IsUsable(now) {
+		t.Error("Expected revoked credential to be unusable")
+	}
+}
+
+func TestConsumer_MigrateApiKey(t *testing.T) {
+	c := Consumer{Username: "legacy", ApiKey: "zen_legacysecretkey"}
+	c.migrateApiKey()
+	c.migrateApiKey()
+
+	if c.ApiKey != "" {
+		t.Error("Expected plaintext api key to be cleared")
+	}
+	if len(c.Credentials) != 1 {
+		t.Fatalf("Expected exactly one migrated credential, got %d", len(c.Credentials))
+	}
+	if !c.Credentials[0].Matches("zen_legacysecretkey") {
+		t.Error("Expected migrated credential to match the legacy key")
+	}
+
+	again := Consumer{Username: "legacy", ApiKey: "zen_legacysecretkey"}
+	again.migrateApiKey()
+	if again.Credentials[0].Id != c.Credentials[0].Id {
+		t.Errorf("Expected the same credential id on every load, got %q and %q", c.Credentials[0].Id, again.Credentials[0].Id)
+	}
+}
@@ -5,6 +5,9 @@ import "flag"
 type GatewayFlagOptions struct {
     RoutesConfigPath string
     ConsumersConfigPath string
+    QuotaStatePath string
+    SettingsPath string
+    SigningKeyPath string
     Port       int
 	AdminPort int
 }
@@ -16,12 +19,18 @@ var Gf *GatewayFlagOptions
 func ParseGatewayFlags() *GatewayFlagOptions{
 	var routeConfig=flag.String("routefile","config/routes.json","path to routes config")
 	var consumersConfig=flag.String("consumersfile","config/consumers.json","path to consumers config")
+	var quotaState=flag.String("quotafile","config/quotas.json","path to persisted quota counters")
+	var settings=flag.String("settingsfile","config/settings.json","path to persisted global settings")
+	var signingKey=flag.String("signingkeyfile","config/signing_key.pem","path to the key internal JWTs are signed with")
 	var port=flag.Int("port",8787,"port to run the server on")
 	var adminPort=flag.Int("adminport",8788,"port to run the admin server on")
 	flag.Parse()
 	Gf = &GatewayFlagOptions{
 		RoutesConfigPath: *routeConfig,
 		ConsumersConfigPath: *consumersConfig,
+		QuotaStatePath: *quotaState,
+		SettingsPath: *settings,
+		SigningKeyPath: *signingKey,
 		Port:*port,
 		AdminPort: *adminPort,
 	}
@@ -1,19 +1,96 @@
 package config
 
-import "sync"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"zentro/internal/clientip"
+)
 
 type GlobalConfig struct {
 	Name                string `json:"name"`
 	Environment         string `json:"environment"`
 	Port                int    `json:"port"`
 	AdminEmail          string `json:"admin_email"`
-	GlobalRateLimiting int    `json:"global_rate_limiting"`
-	Cors                bool   `json:"cors"`
+	// GlobalRateLimiting caps requests per second across the gateway; 0 disables it.
+	GlobalRateLimiting int        `json:"global_rate_limiting"`
+	Cors               bool       `json:"cors"`
+	CorsPolicy         CorsPolicy `json:"cors_policy"`
+	// TrustedProxies lists the CIDRs of proxies whose forwarding headers
+	// are believed when working out the client IP.
+	TrustedProxies   []string        `json:"trusted_proxies"`
+	IpFilter         IpPolicy        `json:"ip_filter"`
+	ForwardedHeaders ForwardedPolicy `json:"forwarded_headers"`
+	// SecurityHeaders holds SecurityHeaders filter settings applied to every
+	// response; empty turns them off.
+	SecurityHeaders map[string]interface{} `json:"security_headers,omitempty"`
+	RequestLimits   RequestLimits          `json:"request_limits"`
+}
+
+// CorsPolicy is the default CORS policy applied when Cors is enabled.
+type CorsPolicy struct {
+	AllowOrigins     []string `json:"allow_origins"`
+	AllowMethods     []string `json:"allow_methods"`
+	AllowHeaders     []string `json:"allow_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           int      `json:"max_age"`
+}
+
+// IpPolicy allows or denies client IPs by CIDR. Deny wins; when Allow is
+// set, only matching clients get through.
+type IpPolicy struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// ForwardedPolicy controls the forwarding headers sent to upstreams.
+type ForwardedPolicy struct {
+	// Mode decides what happens to forwarding headers from the client:
+	// "append" keeps them, "overwrite" drops them, and "strip_untrusted"
+	// keeps them only from trusted proxies. The gateway's own hop is
+	// then added.
+	Mode string `json:"mode"`
+	// Forwarded sends the RFC 7239 Forwarded header.
+	Forwarded bool `json:"forwarded"`
+	// XForwarded sends X-Forwarded-For, -Proto, -Host, -Port and -Prefix.
+	XForwarded bool `json:"x_forwarded"`
+}
+
+// RequestLimits bounds the size of every request; 0 turns a limit off.
+type RequestLimits struct {
+	MaxHeaderCount int `json:"max_header_count"`
+	// MaxHeaderBytes caps the names and values of all headers together.
+	MaxHeaderBytes int   `json:"max_header_bytes"`
+	MaxURLLength   int   `json:"max_url_length"`
+	MaxQueryParams int   `json:"max_query_params"`
+	MaxBodyBytes   int64 `json:"max_body_bytes"`
+	// MaxJSONDepth caps the nesting of JSON request bodies.
+	MaxJSONDepth int `json:"max_json_depth"`
+}
+
+// ValidateNetworks checks the CIDRs of the trusted proxies and IP policy.
+func (c *GlobalConfig) ValidateNetworks() error {
+	for name, list := range map[string][]string{
+		"trusted_proxies": c.TrustedProxies,
+		"ip_filter.allow": c.IpFilter.Allow,
+		"ip_filter.deny":  c.IpFilter.Deny,
+	} {
+		if _, err := clientip.ParseRanges(list); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
 }
 
 var (
 	globalConfig *GlobalConfig
-	configLock   = &sync.RWMutex{}
+	// trustedProxies, ipAllow and ipDeny hold the CIDRs of globalConfig
+	// parsed, so requests don't parse them again.
+	trustedProxies  clientip.Ranges
+	ipAllow, ipDeny clientip.Ranges
+	configLock      = &sync.RWMutex{}
 	GatewayName  = ` ███████████                      █████
 ░█░░░░░░███                      ░░███
 ░     ███░    ██████  ████████   ███████    ██████  ████████
@@ -25,12 +102,70 @@ var (
 )
 
 func Init(environment string) {
-	globalConfig = &GlobalConfig{
+	UpdateGlobalConfig(&GlobalConfig{
 		Name:                "Zentor gateway",
 		Environment:         environment,
 		GlobalRateLimiting: 10000,
 		Cors:                true,
+		CorsPolicy: CorsPolicy{
+			AllowOrigins: []string{"*"},
+			AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			MaxAge:       600,
+		},
+		ForwardedHeaders: ForwardedPolicy{Mode: "strip_untrusted", XForwarded: true},
+		RequestLimits: RequestLimits{
+			MaxHeaderCount: 100,
+			MaxHeaderBytes: 64 * 1024,
+			MaxURLLength:   8192,
+			MaxQueryParams: 256,
+			MaxJSONDepth:   64,
+		},
+	})
+}
+
+// LoadGlobalConfig overlays the settings saved at path on the defaults set
+// by Init. A missing file is not an error.
+func LoadGlobalConfig(path string) error {
+	file, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	cfg := GetGlobalConfig().Clone()
+	if err := json.Unmarshal(file, cfg); err != nil {
+		return err
+	}
+	if err := cfg.ValidateNetworks(); err != nil {
+		return err
+	}
+	UpdateGlobalConfig(cfg)
+	return nil
+}
+
+// Clone returns a deep copy of c. Updates are decoded into a clone so that
+// the live settings, which requests read without locking, never change in
+// place.
+func (c *GlobalConfig) Clone() *GlobalConfig {
+	data, _ := json.Marshal(c)
+	var clone GlobalConfig
+	json.Unmarshal(data, &clone)
+	return &clone
+}
+
+// SaveGlobalConfig writes cfg to path and makes it the live configuration.
+func SaveGlobalConfig(path string, cfg *GlobalConfig) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
 	}
+	UpdateGlobalConfig(cfg)
+	return nil
 }
 
 func GetGlobalConfig() *GlobalConfig {
@@ -39,8 +174,29 @@ func GetGlobalConfig() *GlobalConfig {
 	return globalConfig
 }
 
+// TrustedProxyRanges returns the trusted proxies of the live configuration,
+// parsed when it was last updated.
+func TrustedProxyRanges() clientip.Ranges {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	return trustedProxies
+}
+
+// IpFilterRanges returns the gateway-wide allow and deny lists of the live
+// configuration, parsed when it was last updated.
+func IpFilterRanges() (allow, deny clientip.Ranges) {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	return ipAllow, ipDeny
+}
+
 func UpdateGlobalConfig(newConfig *GlobalConfig) {
+	trusted := clientip.MustParseRanges(newConfig.TrustedProxies)
+	allow := clientip.MustParseRanges(newConfig.IpFilter.Allow)
+	deny := clientip.MustParseRanges(newConfig.IpFilter.Deny)
 	configLock.Lock()
 	defer configLock.Unlock()
 	globalConfig = newConfig
+	trustedProxies = trusted
+	ipAllow, ipDeny = allow, deny
 };
\ No newline at end of file
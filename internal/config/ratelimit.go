@@ -0,0 +1,34 @@
+package config
+
+// Rate limit store backends.
+const (
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+)
+
+// RateLimitStore selects where RateLimit filters keep their state. Changes
+// take effect on restart.
+type RateLimitStore struct {
+	// Store is "memory" (default, per process) or "redis" (shared).
+	Store string     `json:"store,omitempty"`
+	Redis RedisStore `json:"redis,omitempty"`
+	// FailureMode decides what happens while Redis is unreachable: "open"
+	// (default) allows requests, "closed" rejects them with 503 and "local"
+	// enforces the limits per process.
+	FailureMode string `json:"failure_mode,omitempty"`
+	// BatchSize is how many permits a gateway reserves per Redis round trip.
+	BatchSize int `json:"batch_size,omitempty"`
+	// MaxKeys bounds the in-memory store.
+	MaxKeys int `json:"max_keys,omitempty"`
+}
+
+type RedisStore struct {
+	Addr      string `json:"addr,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Password  string `json:"password,omitempty"`
+	DB        int    `json:"db,omitempty"`
+	KeyPrefix string `json:"key_prefix,omitempty"`
+	// TimeoutMs bounds every round trip. It defaults to 100 for rate limits
+	// and 1000 for the response cache.
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+}
@@ -16,6 +16,26 @@ type Auth struct {
 	Header  string `json:"header,omitempty"`
 }
 
+// Acl restricts a route to authenticated consumers. Deny groups take
+// precedence over the allow lists.
+type Acl struct {
+	AllowGroups    []string `json:"allow_groups,omitempty"`
+	DenyGroups     []string `json:"deny_groups,omitempty"`
+	AllowConsumers []string `json:"allow_consumers,omitempty"`
+}
+
+// GlobalOverrides adjusts the gateway-wide middleware for one route.
+type GlobalOverrides struct {
+	// RateLimit replaces the global requests-per-second ceiling for this
+	// route; 0 exempts it.
+	RateLimit *int `json:"rate_limit,omitempty"`
+	// Cors set to false turns the default CORS policy off for this route.
+	Cors *bool `json:"cors,omitempty"`
+	// SecurityHeaders set to false turns the default security headers off
+	// for this route.
+	SecurityHeaders *bool `json:"security_headers,omitempty"`
+}
+
 type Route struct {
 	ID          string                  `json:"id,omitempty"`
 	Name        string                  `json:"name,omitempty"`
@@ -27,6 +47,8 @@ type Route struct {
 	Upstreams   []string                `json:"upstreams"`
 	Enabled     *bool                   `json:"enabled,omitempty"`
 	Auth        Auth                    `json:"auth,omitempty"`
+	Acl         *Acl                    `json:"acl,omitempty"`
+	Global      *GlobalOverrides        `json:"global,omitempty"`
 	Filters     []filters.GenericFilter `json:"filters,omitempty"`
 	Lb          *lb.LoadBalancer        `json:"lb,omitempty"`
 }
@@ -37,7 +59,10 @@ type Health struct {
 }
 
 type Config struct {
-	Health Health `json:"health,omitempty"`
+	Health    Health          `json:"health,omitempty"`
+	RateLimit *RateLimitStore `json:"rate_limit,omitempty"`
+	Cache     *CacheStore     `json:"cache,omitempty"`
+	Tls       *TlsListener    `json:"tls,omitempty"`
 }
 
 type ConfigUser struct {
@@ -59,6 +84,11 @@ func (r Route) IsEnabled() bool {
 	return *r.Enabled
 }
 
+// IsSet reports whether the route restricts access to specific consumers.
+func (a *Acl) IsSet() bool {
+	return a != nil && (len(a.AllowGroups) > 0 || len(a.DenyGroups) > 0 || len(a.AllowConsumers) > 0)
+}
+
 func LoadRoutes(path string) (*GatewayConfig, error) {
 	file, err := os.ReadFile(path)
 	if err != nil {
@@ -96,9 +126,40 @@ func LoadRoutes(path string) (*GatewayConfig, error) {
 
 	}
 
+	var routeFilters []filters.GenericFilter
+	for _, route := range cfg.Routes {
+		routeFilters = append(routeFilters, route.Filters...)
+	}
+	if err := filters.CompileRouteFilters(routeFilters); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
+// ReadRoutesFile reads the routes file as written, without the defaults
+// LoadRoutes fills in, so that it can be edited and saved back.
+func ReadRoutesFile(path string) (*GatewayConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg GatewayConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// SaveRoutesFile writes cfg to the routes file; the watcher reloads it.
+func SaveRoutesFile(path string, cfg *GatewayConfig) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
 func MustLoadRoutes(path string) (*GatewayConfig, error) {
 	cfg, err := LoadRoutes(path)
 	if err != nil {
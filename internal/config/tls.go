@@ -0,0 +1,42 @@
+package config
+
+// Client certificate modes of the TLS listener.
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// What the gateway tells upstreams about a verified client certificate.
+const (
+	ForwardClientCertNone    = "none"
+	ForwardClientCertDetails = "details"
+	ForwardClientCertPem     = "pem"
+)
+
+// TlsListener configures the HTTPS listener served next to the plain HTTP
+// one. Changes take effect on restart; the certificate and CA files
+// themselves are reloaded when they change on disk.
+type TlsListener struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Port defaults to 8443.
+	Port int `json:"port,omitempty"`
+	// Certificates are picked by the SNI name the client asks for; the
+	// first one is served when no other matches.
+	Certificates []TlsCertificate `json:"certificates,omitempty"`
+	// MinVersion is "1.2" (default) or "1.3".
+	MinVersion string `json:"min_version,omitempty"`
+	// ClientAuth is "none" (default), "optional" or "require". Presented
+	// certificates must chain to one of ClientCAFiles.
+	ClientAuth    string   `json:"client_auth,omitempty"`
+	ClientCAFiles []string `json:"client_ca_files,omitempty"`
+	// ForwardClientCert is "none", "details" (default) or "pem".
+	ForwardClientCert string `json:"forward_client_cert,omitempty"`
+}
+
+type TlsCertificate struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// ServerNames replace the names taken from the certificate's SANs.
+	ServerNames []string `json:"server_names,omitempty"`
+}
@@ -0,0 +1,60 @@
+package filters
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAclFilter_Allows(t *testing.T) {
+	f := AclFilter{Settings: AclFilterSettings{
+		AllowGroups:    []string{"partners"},
+		DenyGroups:     []string{"suspended"},
+		AllowConsumers: []string{"ops-bot"},
+	}}
+
+	cases := []struct {
+		name     string
+		consumer *Consumer
+		want     bool
+	}{
+		{"allowed group", &Consumer{ID: "1", Groups: []string{"partners"}}, true},
+		{"allowed consumer", &Consumer{ID: "2", Username: "ops-bot"}, true},
+		{"no matching entry", &Consumer{ID: "3", Groups: []string{"internal"}}, false},
+		{"deny wins", &Consumer{ID: "4", Groups: []string{"partners", "suspended"}}, false},
+	}
+
+	for _, c := range cases {
+		if got := f.Allows(c.consumer); got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestAclFilter_Apply(t *testing.T) {
+	f := AclFilter{Settings: AclFilterSettings{AllowGroups: []string{"partners"}}}
+	handler := f.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/partner", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without consumer, got %d", rec.Code)
+	}
+
+	req = req.WithContext(WithConsumer(req.Context(), &Consumer{ID: "1", Groups: []string{"internal"}}))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for consumer outside allow list, got %d", rec.Code)
+	}
+
+	req = req.WithContext(WithConsumer(req.Context(), &Consumer{ID: "2", Groups: []string{"partners"}}))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for partner consumer, got %d", rec.Code)
+	}
+}
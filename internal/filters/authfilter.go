@@ -1,13 +1,20 @@
 package filters
 
 import (
+	"encoding/base64"
 	"net/http"
 	"strings"
+	"zentro/internal/tlsserver"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type AuthFilter struct {
 	Name string
-	Settings AuthFilterSettings 
+	Settings AuthFilterSettings
+	// Lookup identifies the consumer behind the presented credential. When it
+	// is nil the filter only checks that a credential of the right shape exists.
+	Lookup ConsumerLookup
 }
 
 type AuthFilterSettings struct{
@@ -18,6 +25,10 @@ type AuthFilterSettings struct{
 
 func (a AuthFilter) Apply(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.Settings.Type == "mtls" {
+			a.applyMtls(next, w, r)
+			return
+		}
 		var header=r.Header.Get(a.Settings.Header)
 		if (a.Settings.Type != "bearer" && a.Settings.Type != "basic" && a.Settings.Type != "api-key") || header == "" {
 			http.Error(w, "Unauthorized: Failed", http.StatusUnauthorized)
@@ -28,10 +39,81 @@ func (a AuthFilter) Apply(next http.Handler) http.Handler {
 			http.Error(w, "Unauthorized: Failed", http.StatusUnauthorized)
 			return
 		}
+
+		// Unknown, revoked and expired credentials are turned away.
+		if a.Lookup != nil {
+			credential := credentialFromHeader(a.Settings.Type, header)
+			consumer, ok := a.Lookup(a.Settings.Type, credential)
+			if !ok {
+				http.Error(w, "Unauthorized: Failed", http.StatusUnauthorized)
+				return
+			}
+			ctx := WithConsumer(r.Context(), consumer)
+			if claims := verifiedClaims(a.Settings.Type, credential); claims != nil {
+				ctx = WithTokenClaims(ctx, claims)
+			}
+			r = r.WithContext(ctx)
+		}
         next.ServeHTTP(w, r)
     })
 }
 
+// applyMtls accepts requests with a verified client certificate and
+// identifies the consumer by the first of its names that is registered.
+// Certificates with no usable credential are turned away.
+func (a AuthFilter) applyMtls(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	cert, ok := tlsserver.ClientCertificate(r)
+	if !ok {
+		http.Error(w, "Unauthorized: Failed", http.StatusUnauthorized)
+		return
+	}
+	if a.Lookup != nil {
+		var consumer *Consumer
+		for _, id := range tlsserver.Identities(cert) {
+			if found, ok := a.Lookup("mtls", id); ok {
+				consumer = found
+				break
+			}
+		}
+		if consumer == nil {
+			http.Error(w, "Unauthorized: Failed", http.StatusUnauthorized)
+			return
+		}
+		r = r.WithContext(WithConsumer(r.Context(), consumer))
+	}
+	next.ServeHTTP(w, r)
+}
+
+// credentialFromHeader strips the scheme from the raw header value. Basic
+// credentials are returned decoded as "username:password".
+func credentialFromHeader(authType, header string) string {
+	switch authType {
+	case "bearer":
+		return strings.TrimSpace(strings.TrimPrefix(header, "Bearer"))
+	case "basic":
+		encoded := strings.TrimSpace(strings.TrimPrefix(header, "Basic"))
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return ""
+		}
+		return string(decoded)
+	default:
+		return header
+	}
+}
+
+// verifiedClaims decodes the claims of a bearer JWT the lookup has already
+// verified.
+func verifiedClaims(authType, credential string) map[string]interface{} {
+	if authType != "bearer" || strings.Count(credential, ".") != 2 {
+		return nil
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(credential, claims); err != nil {
+		return nil
+	}
+	return claims
+}
 
 func (a *AuthFilter) Convert(filter GenericFilter){
     settings := AuthFilterSettings{}
@@ -42,7 +124,7 @@ func (a *AuthFilter) Convert(filter GenericFilter){
 			settings.Header="Authorization"
 		}
     } else {
-        settings.Header = "Authorization" 
+        settings.Header = "Authorization"
     }
 
     if typeVal, ok := filter.Settings["type"].(string); ok {
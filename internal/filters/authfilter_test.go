@@ -0,0 +1,51 @@
+package filters
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthFilter_RejectsUnmatchedCredentials(t *testing.T) {
+	lookup := func(authType, credential string) (*Consumer, bool) {
+		if credential == "good" || credential == "billing" {
+			return &Consumer{ID: "c1"}, true
+		}
+		return nil, false
+	}
+	serve := func(authType string, req *http.Request) (int, *Consumer) {
+		filter := AuthFilter{Lookup: lookup}
+		filter.Convert(GenericFilter{Name: "auth", Settings: map[string]interface{}{"type": authType, "header": "X-Api-Key"}})
+		var consumer *Consumer
+		handler := filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			consumer, _ = ConsumerFromContext(r.Context())
+		}))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code, consumer
+	}
+
+	for key, want := range map[string]int{"good": http.StatusOK, "revoked": http.StatusUnauthorized} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Api-Key", key)
+		if code, _ := serve("api-key", req); code != want {
+			t.Errorf("Expected %d for key %q, got %d", want, key, code)
+		}
+	}
+
+	for name, want := range map[string]int{"billing": http.StatusOK, "unregistered": http.StatusUnauthorized} {
+		req := httptest.NewRequest("GET", "/", nil)
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: name}}
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		code, consumer := serve("mtls", req)
+		if code != want {
+			t.Errorf("Expected %d for certificate %q, got %d", want, name, code)
+		}
+		if code == http.StatusOK && (consumer == nil || consumer.ID != "c1") {
+			t.Errorf("Expected the certificate to identify c1, got %+v", consumer)
+		}
+	}
+}
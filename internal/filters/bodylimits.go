@@ -0,0 +1,105 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// JSONDepthError is returned when reading a JSON body nested deeper than
+// allowed.
+type JSONDepthError struct {
+	Limit int
+}
+
+func (e *JSONDepthError) Error() string {
+	return fmt.Sprintf("JSON body nested deeper than %d levels", e.Limit)
+}
+
+// LimitBody makes reading more than limit bytes of the body fail with an
+// *http.MaxBytesError, however the body is framed.
+func LimitBody(w http.ResponseWriter, r *http.Request, limit int64) {
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+}
+
+// LimitJSONDepth makes reading a JSON body nested deeper than limit fail
+// with a *JSONDepthError. The body is checked as it streams. Compressed JSON
+// bodies are decoded first, up to maxBytes once decoded, and passed on
+// decoded; an error means the encoding is not supported. Non-JSON bodies are
+// left alone.
+func LimitJSONDepth(r *http.Request, limit int, maxBytes int64) error {
+	if r.Body == nil || r.Body == http.NoBody ||
+		!contentTypeAllowed(r.Header.Get("Content-Type"), jsonContentTypes) {
+		return nil
+	}
+	if err := decompressRequest(r, maxBytes); err != nil {
+		return err
+	}
+	r.Body = &jsonDepthReader{ReadCloser: r.Body, limit: limit}
+	return nil
+}
+
+type jsonDepthReader struct {
+	io.ReadCloser
+	limit    int
+	depth    int
+	inString bool
+	escaped  bool
+	err      error
+}
+
+func (j *jsonDepthReader) Read(p []byte) (int, error) {
+	if j.err != nil {
+		return 0, j.err
+	}
+	n, err := j.ReadCloser.Read(p)
+	for _, c := range p[:n] {
+		switch {
+		case j.inString:
+			if j.escaped {
+				j.escaped = false
+			} else if c == '\\' {
+				j.escaped = true
+			} else if c == '"' {
+				j.inString = false
+			}
+		case c == '"':
+			j.inString = true
+		case c == '{' || c == '[':
+			j.depth++
+			if j.depth > j.limit {
+				j.err = &JSONDepthError{Limit: j.limit}
+				return 0, j.err
+			}
+		case c == '}' || c == ']':
+			j.depth--
+		}
+	}
+	return n, err
+}
+
+// BodyErrorStatus maps an error from reading a request body to the status
+// for the limit it broke: 413 for size and 400 for JSON depth. ok is false
+// for other errors.
+func BodyErrorStatus(err error) (status int, ok bool) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge, true
+	}
+	var tooDeep *JSONDepthError
+	if errors.As(err, &tooDeep) {
+		return http.StatusBadRequest, true
+	}
+	return 0, false
+}
+
+// readErrorStatus is the status for a request body that could not be read.
+func readErrorStatus(err error) int {
+	if status, ok := BodyErrorStatus(err); ok {
+		return status
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,300 @@
+package filters
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BodyTransformSettings configures the ModifyRequestBody and
+// ModifyResponseBody filters. Bodies are only rewritten when they fit in
+// MaxBuffer; larger or streamed bodies pass through untouched.
+type BodyTransformSettings struct {
+	From string
+	To   string
+	// Regex treats From as a regular expression; To may refer to its
+	// groups as $1 or ${name}.
+	Regex        bool
+	MaxBuffer    int64
+	ContentTypes []string
+
+	pattern *regexp.Regexp
+}
+
+const defaultBodyTransformBuffer = 1 << 20
+
+var defaultTransformableTypes = []string{
+	"text/*",
+	"application/json",
+	"application/*+json",
+	"application/xml",
+	"application/*+xml",
+	"application/javascript",
+	"application/x-www-form-urlencoded",
+}
+
+func convertBodyTransform(filter GenericFilter, filterName string) BodyTransformSettings {
+	settings := BodyTransformSettings{MaxBuffer: defaultBodyTransformBuffer}
+	if from, ok := filter.Settings["from"].(string); ok {
+		settings.From = from
+	} else {
+		log.Printf("from must be a string for %s", filterName)
+	}
+	if to, ok := filter.Settings["to"].(string); ok {
+		settings.To = to
+	} else {
+		log.Printf("to must be a string for %s", filterName)
+	}
+	settings.Regex, _ = filter.Settings["regex"].(bool)
+	if settings.Regex && settings.From != "" {
+		pattern, err := compiledRegexp(settings.From)
+		if err != nil {
+			log.Printf("Invalid regex %q for %s, bodies will pass through: %v", settings.From, filterName, err)
+		}
+		settings.pattern = pattern
+	}
+	if size, ok := intSetting(filter.Settings, "max_buffer"); ok && size > 0 {
+		settings.MaxBuffer = int64(size)
+	}
+	settings.ContentTypes = stringList(filter.Settings["content_types"])
+	return settings
+}
+
+var (
+	regexpMu sync.Mutex
+	// regexps holds the compiled From patterns, keyed by their source, so
+	// the filters converted for every request don't compile them again.
+	regexps = map[string]compiledPattern{}
+)
+
+type compiledPattern struct {
+	pattern *regexp.Regexp
+	err     error
+}
+
+// compiledRegexp returns the pattern for expr from cache, compiling it there
+// if it is missing. Invalid patterns are cached with their error.
+func compiledRegexp(expr string) (*regexp.Regexp, error) {
+	regexpMu.Lock()
+	defer regexpMu.Unlock()
+	compiled, ok := regexps[expr]
+	if !ok {
+		compiled.pattern, compiled.err = regexp.Compile(expr)
+		regexps[expr] = compiled
+	}
+	return compiled.pattern, compiled.err
+}
+
+// enabled reports whether there is anything to replace.
+func (s BodyTransformSettings) enabled() bool {
+	if s.Regex {
+		return s.pattern != nil
+	}
+	return s.From != ""
+}
+
+// accepts checks the guards that can be decided from the headers alone.
+func (s BodyTransformSettings) accepts(h http.Header, contentLength int64) bool {
+	types := s.ContentTypes
+	if len(types) == 0 {
+		types = defaultTransformableTypes
+	}
+	return s.enabled() && bodyEligible(h, contentLength, types, s.MaxBuffer)
+}
+
+// bodyEligible reports whether a body with these headers can be buffered
+// and decoded for rewriting.
+func bodyEligible(h http.Header, contentLength int64, types []string, maxBuffer int64) bool {
+	if !contentTypeAllowed(h.Get("Content-Type"), types) {
+		return false
+	}
+	if encoding := strings.ToLower(h.Get("Content-Encoding")); encoding != "" && encoding != "identity" && !decodable(encoding) {
+		return false
+	}
+	return contentLength <= maxBuffer
+}
+
+func (s BodyTransformSettings) replace(body []byte) []byte {
+	if s.Regex {
+		return s.pattern.ReplaceAll(body, []byte(s.To))
+	}
+	return bytes.ReplaceAll(body, []byte(s.From), []byte(s.To))
+}
+
+// transform rewrites a complete body as received with the given
+// Content-Encoding. The result is always identity-encoded. It reports false
+// when the body cannot be decoded or decodes to more than MaxBuffer bytes.
+func (s BodyTransformSettings) transform(raw []byte, encoding string) ([]byte, bool) {
+	body, ok := decodeBody(raw, encoding, s.MaxBuffer)
+	if !ok {
+		return nil, false
+	}
+	return s.replace(body), true
+}
+
+// decodeBody undoes a Content-Encoding, refusing bodies that decode to more
+// than limit bytes.
+func decodeBody(raw []byte, encoding string, limit int64) ([]byte, bool) {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if encoding == "" || encoding == "identity" {
+		return raw, true
+	}
+	decoder, err := newDecoder(encoding, bytes.NewReader(raw))
+	if err != nil {
+		return nil, false
+	}
+	defer decoder.Close()
+	decoded, err := io.ReadAll(io.LimitReader(decoder, limit+1))
+	if err != nil || int64(len(decoded)) > limit {
+		return nil, false
+	}
+	return decoded, true
+}
+
+// bufferRequestBody reads a request body of up to limit bytes. When the body
+// is larger, it reports false and leaves r.Body readable from the start.
+func bufferRequestBody(r *http.Request, limit int64) ([]byte, bool, error) {
+	raw, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(raw)) > limit {
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(raw), r.Body), r.Body}
+		return nil, false, nil
+	}
+	r.Body.Close()
+	return raw, true, nil
+}
+
+// readCloser pairs a reader with the Closer of the body it was built from.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// setRequestBody replaces the request body with a rewritten one.
+func setRequestBody(r *http.Request, body []byte) {
+	setBodyHeaders(r.Header, len(body))
+	r.TransferEncoding = nil
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+}
+
+// setBodyHeaders describes a rewritten, fully buffered body.
+func setBodyHeaders(h http.Header, size int) {
+	h.Del("Content-Encoding")
+	h.Del("Transfer-Encoding")
+	h.Set("Content-Length", strconv.Itoa(size))
+}
+
+// rewriteWriter holds a response back until it is complete, then writes
+// what rewrite makes of it. It falls back to streaming the response
+// unchanged once it outgrows maxBuffer.
+type rewriteWriter struct {
+	http.ResponseWriter
+	maxBuffer int64
+	head      bool
+	accepts   func(h http.Header, contentLength int64) bool
+	// rewrite returns the status and identity-encoded body to send, or
+	// false to send the response as received.
+	rewrite func(h http.Header, status int, raw []byte) (int, []byte, bool)
+
+	statusCode  int
+	body        bytes.Buffer
+	buffering   bool
+	passthrough bool
+}
+
+func (w *rewriteWriter) WriteHeader(statusCode int) {
+	if w.statusCode != 0 {
+		return
+	}
+	w.statusCode = statusCode
+	contentLength := int64(-1)
+	if v, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil {
+		contentLength = v
+	}
+	bodyless := statusCode < http.StatusOK || statusCode == http.StatusNoContent || statusCode == http.StatusNotModified
+	if w.head || bodyless || w.Header().Get("Content-Range") != "" || !w.accepts(w.Header(), contentLength) {
+		w.passThrough()
+		return
+	}
+	w.buffering = true
+}
+
+func (w *rewriteWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	w.body.Write(b)
+	if int64(w.body.Len()) > w.maxBuffer {
+		if err := w.passThrough(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// passThrough sends the headers and anything buffered unchanged, and
+// streams the rest of the response.
+func (w *rewriteWriter) passThrough() error {
+	w.buffering, w.passthrough = false, true
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	if w.body.Len() == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.body.Bytes())
+	w.body.Reset()
+	return err
+}
+
+// Flush only reaches the client once the response passes through. The proxy
+// flushes every write of a response without Content-Length, and those are
+// still rewritten when they fit in MaxBuffer; event streams are excluded by
+// the content type guard instead.
+func (w *rewriteWriter) Flush() {
+	if !w.passthrough {
+		return
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// finish writes the rewritten response once the handler has returned.
+func (w *rewriteWriter) finish() {
+	if !w.buffering {
+		return
+	}
+	status, body, ok := w.rewrite(w.Header(), w.statusCode, w.body.Bytes())
+	if !ok {
+		w.passThrough()
+		return
+	}
+	setBodyHeaders(w.Header(), len(body))
+	weakenETag(w.Header())
+	w.ResponseWriter.WriteHeader(status)
+	w.ResponseWriter.Write(body)
+}
+
+func (w *rewriteWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("hijacking not supported")
+}
+
+func (w *rewriteWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,125 @@
+package filters
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestModifyRequestBody_RegexAndCompressedBody(t *testing.T) {
+	filter := &ModifyRequestBodyFilter{}
+	filter.Convert(GenericFilter{Name: "ModifyRequestBody", Settings: map[string]interface{}{
+		"from":  `"id":\s*(\d+)`,
+		"to":    `"id":"$1"`,
+		"regex": true,
+	}})
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(`{"id": 42}`))
+	zw.Close()
+
+	var received string
+	var contentLength int64
+	handler := filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received, contentLength = string(data), r.ContentLength
+		if r.Header.Get("Content-Encoding") != "" {
+			t.Errorf("Expected rewritten body to be sent uncompressed")
+		}
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/", &compressed)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if received != `{"id":"42"}` {
+		t.Errorf("Expected regex replacement, got %q", received)
+	}
+	if contentLength != int64(len(received)) {
+		t.Errorf("Expected Content-Length %d, got %d", len(received), contentLength)
+	}
+}
+
+func TestBodyTransform_CompilesEachPatternOnce(t *testing.T) {
+	convert := func() *regexp.Regexp {
+		return convertBodyTransform(GenericFilter{Settings: map[string]interface{}{
+			"from": `id-(\d+)`, "to": "$1", "regex": true,
+		}}, "ModifyRequestBody").pattern
+	}
+	if first, second := convert(), convert(); first == nil || first != second {
+		t.Errorf("Expected every conversion to share one compiled pattern")
+	}
+}
+
+func TestModifyRequestBody_PassesThroughLargeAndOtherBodies(t *testing.T) {
+	filter := &ModifyRequestBodyFilter{}
+	filter.Convert(GenericFilter{Name: "ModifyRequestBody", Settings: map[string]interface{}{
+		"from":       "a",
+		"to":         "b",
+		"max_buffer": 8.0,
+	}})
+	cases := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain", strings.Repeat("a", 20)},
+		{"application/octet-stream", "aaa"},
+	}
+	for _, c := range cases {
+		var received string
+		handler := filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			received = string(data)
+		}))
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(c.body))
+		req.ContentLength = -1
+		req.Header.Set("Content-Type", c.contentType)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if received != c.body {
+			t.Errorf("Expected %s body of %d bytes to pass through, got %q", c.contentType, len(c.body), received)
+		}
+	}
+}
+
+func TestModifyResponseBody_RewritesBufferedResponses(t *testing.T) {
+	filter := &ModifyResponseBodyFilter{}
+	filter.Convert(GenericFilter{Name: "ModifyResponseBody", Settings: map[string]interface{}{
+		"from":       "internal.local",
+		"to":         "api.example.com",
+		"max_buffer": 64.0,
+	}})
+	handler := filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := r.URL.Query().Get("body")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, body)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?body=http://internal.local/x", nil))
+	if rec.Code != http.StatusCreated || rec.Body.String() != "http://api.example.com/x" {
+		t.Errorf("Expected rewritten 201 response, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Content-Length") != "24" {
+		t.Errorf("Expected Content-Length 24, got %q", rec.Header().Get("Content-Length"))
+	}
+	if etag := rec.Header().Get("ETag"); etag != `W/"v1"` {
+		t.Errorf("Expected the upstream ETag to be weakened, got %q", etag)
+	}
+
+	large := strings.Repeat("internal.local ", 10)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?body="+strings.ReplaceAll(large, " ", "+"), nil))
+	if rec.Body.String() != large {
+		t.Errorf("Expected response over max_buffer to pass through unchanged")
+	}
+}
@@ -0,0 +1,207 @@
+package filters
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Bulkheads holds the concurrency limiters of every Concurrency filter,
+// keyed by filter scope and partition.
+var Bulkheads = newBulkheadRegistry()
+
+// maxBulkheads bounds the registry; idle limiters are dropped past it.
+const maxBulkheads = 10000
+
+// Reasons a bulkhead turns a request away.
+const (
+	RejectQueueFull    = "queue_full"
+	RejectQueueTimeout = "queue_timeout"
+)
+
+type bulkheadRegistry struct {
+	mu    sync.Mutex
+	items map[string]*Bulkhead
+}
+
+func newBulkheadRegistry() *bulkheadRegistry {
+	return &bulkheadRegistry{items: make(map[string]*Bulkhead)}
+}
+
+// Get returns the limiter for key, creating it with the given initial limit.
+func (r *bulkheadRegistry) Get(key string, limit int) *Bulkhead {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if b, ok := r.items[key]; ok {
+		return b
+	}
+	if len(r.items) >= maxBulkheads {
+		for k, b := range r.items {
+			if b.idle() {
+				delete(r.items, k)
+			}
+		}
+	}
+	b := newBulkhead(limit)
+	r.items[key] = b
+	return b
+}
+
+// Bulkhead caps in-flight requests and queues a bounded number of waiters.
+// In adaptive mode the limit follows observed latency: it grows by one per
+// limit's worth of fast responses and shrinks by a fixed factor on slow or
+// failed ones (AIMD).
+type Bulkhead struct {
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	waiters  list.List
+
+	// Latency baseline for adaptive mode without a fixed target.
+	minLatency time.Duration
+	baselineAt time.Time
+}
+
+type waiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// BulkheadPolicy is the adaptive tuning applied when a request completes.
+type BulkheadPolicy struct {
+	Adaptive bool
+	MinLimit int
+	MaxLimit int
+	// TargetLatency marks a response as slow. When zero, responses slower
+	// than twice the lowest recent latency are slow.
+	TargetLatency time.Duration
+}
+
+const (
+	adaptiveBackoff = 0.9
+	// baselineWindow is how long the lowest observed latency is trusted
+	// before it is measured afresh.
+	baselineWindow = 30 * time.Second
+)
+
+func newBulkhead(limit int) *Bulkhead {
+	b := &Bulkhead{limit: float64(limit)}
+	b.waiters.Init()
+	return b
+}
+
+// Acquire takes a slot, waiting in the queue for at most timeout when the
+// bulkhead is full. It returns an empty reason on success.
+func (b *Bulkhead) Acquire(ctx context.Context, maxQueue int, timeout time.Duration) string {
+	b.mu.Lock()
+	if b.inflight < b.currentLimit() {
+		b.inflight++
+		b.mu.Unlock()
+		return ""
+	}
+	if b.waiters.Len() >= maxQueue {
+		b.mu.Unlock()
+		return RejectQueueFull
+	}
+	w := &waiter{ready: make(chan struct{})}
+	el := b.waiters.PushBack(w)
+	b.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-w.ready:
+		return ""
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if w.granted {
+		// The slot was handed over while we were timing out.
+		return ""
+	}
+	b.waiters.Remove(el)
+	return RejectQueueTimeout
+}
+
+// Release frees a slot and, in adaptive mode, adjusts the limit from how the
+// request went.
+func (b *Bulkhead) Release(latency time.Duration, failed bool, policy BulkheadPolicy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if policy.Adaptive {
+		b.adapt(latency, failed, policy, time.Now())
+	}
+	b.inflight--
+	b.grant()
+}
+
+// grant hands free slots to queued requests, oldest first. b.mu must be held.
+func (b *Bulkhead) grant() {
+	for b.inflight < b.currentLimit() && b.waiters.Len() > 0 {
+		w := b.waiters.Remove(b.waiters.Front()).(*waiter)
+		w.granted = true
+		b.inflight++
+		close(w.ready)
+	}
+}
+
+func (b *Bulkhead) adapt(latency time.Duration, failed bool, policy BulkheadPolicy, now time.Time) {
+	if b.minLatency == 0 || latency < b.minLatency || now.Sub(b.baselineAt) > baselineWindow {
+		b.minLatency = latency
+		b.baselineAt = now
+	}
+	target := policy.TargetLatency
+	if target <= 0 {
+		target = 2 * b.minLatency
+	}
+
+	if failed || latency > target {
+		b.limit *= adaptiveBackoff
+	} else if b.inflight >= b.currentLimit() {
+		// Only grow while the limit is actually the bottleneck.
+		b.limit += 1 / b.limit
+	}
+
+	if b.limit < float64(policy.MinLimit) {
+		b.limit = float64(policy.MinLimit)
+	}
+	if policy.MaxLimit > 0 && b.limit > float64(policy.MaxLimit) {
+		b.limit = float64(policy.MaxLimit)
+	}
+}
+
+// SetLimit replaces the limit of a non-adaptive bulkhead, so edits to the
+// filter settings take effect on the next request. Queued requests get any
+// slots a higher limit frees up.
+func (b *Bulkhead) SetLimit(limit int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = float64(limit)
+	b.grant()
+}
+
+// Limit returns the current limit.
+func (b *Bulkhead) Limit() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentLimit()
+}
+
+func (b *Bulkhead) currentLimit() int {
+	if b.limit < 1 {
+		return 1
+	}
+	return int(b.limit)
+}
+
+func (b *Bulkhead) idle() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inflight == 0 && b.waiters.Len() == 0
+}
@@ -0,0 +1,516 @@
+package filters
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"zentro/internal/cache"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ResponseCache holds the responses stored by every Cache filter. The
+// gateway replaces it with the store configured under "cache".
+var ResponseCache cache.CacheStore = cache.NewMemoryStore(cache.DefaultMaxBytes)
+
+// cacheFlights coalesces concurrent upstream fetches of the same key.
+var cacheFlights = &flightGroup{calls: make(map[string]chan struct{})}
+
+// revalidateKeep is how long past their freshness entries with validators
+// are kept, since a cheap 304 can make them fresh again.
+const revalidateKeep = time.Hour
+
+// CacheFilter serves GET and HEAD requests from ResponseCache following the
+// RFC 9111 rules for shared caches.
+type CacheFilter struct {
+	Name     string
+	Settings CacheSettings
+	Scope    string
+}
+
+type CacheSettings struct {
+	// TTL applies to responses without explicit freshness information.
+	TTL                  time.Duration
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+	MaxEntrySize         int64
+	// QueryParams limits the query parameters that are part of the key.
+	// All parameters are used when it is empty.
+	QueryParams []string
+	TagHeader   string
+	Coalesce    bool
+}
+
+func (f *CacheFilter) SetScope(scope string) {
+	f.Scope = scope
+}
+
+func (f *CacheFilter) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			f.invalidate(w, r, next)
+			return
+		}
+		if cache.ParseCacheControl(r.Header).Has("no-store") {
+			w.Header().Set("X-Cache", "BYPASS")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		primary := f.key(r)
+		if f.serveCached(w, r, next, primary) {
+			return
+		}
+		if cache.ParseCacheControl(r.Header).Has("only-if-cached") {
+			http.Error(w, "Not cached", http.StatusGatewayTimeout)
+			return
+		}
+
+		if f.Settings.Coalesce {
+			done, leader := cacheFlights.join(primary)
+			if !leader {
+				select {
+				case <-done:
+				case <-r.Context().Done():
+					return
+				}
+				if f.serveCached(w, r, next, primary) {
+					return
+				}
+			} else {
+				defer cacheFlights.finish(primary)
+			}
+		}
+
+		rec := f.newRecorder(w, r, primary, nil)
+		next.ServeHTTP(rec, r)
+		rec.finish()
+	})
+}
+
+// serveCached answers from a usable stored entry, revalidating it first when
+// needed. It reports whether the request was handled.
+func (f *CacheFilter) serveCached(w http.ResponseWriter, r *http.Request, next http.Handler, primary string) bool {
+	entry, key := f.lookup(r, primary)
+	if entry == nil {
+		return false
+	}
+
+	fr := entry.Check(r, f.Settings.TTL, f.Settings.StaleWhileRevalidate, f.Settings.StaleIfError, time.Now())
+	switch {
+	case fr.Fresh:
+		return writeCached(w, r, entry, fr.Age, "HIT")
+	case fr.MayServeStale:
+		if !writeCached(w, r, entry, fr.Age, "STALE") {
+			return false
+		}
+		go f.revalidateInBackground(next, r, primary, key, entry)
+		return true
+	default:
+		f.revalidate(w, r, next, primary, key, entry, fr)
+		return true
+	}
+}
+
+func (f *CacheFilter) lookup(r *http.Request, primary string) (*cache.Entry, string) {
+	entry, ok := ResponseCache.Get(primary)
+	if !ok {
+		return nil, ""
+	}
+	if len(entry.Vary) == 0 {
+		return entry, primary
+	}
+	key := cache.VaryKey(primary, entry.Vary, r)
+	if entry, ok = ResponseCache.Get(key); ok {
+		return entry, key
+	}
+	return nil, ""
+}
+
+// revalidate asks the upstream whether a stale entry is still valid before
+// answering the client. Other responses stream through as a miss.
+func (f *CacheFilter) revalidate(w http.ResponseWriter, r *http.Request, next http.Handler, primary, key string, entry *cache.Entry, fr cache.Freshness) {
+	rec := f.newRecorder(w, r, primary, func(code int) bool {
+		return (code == http.StatusNotModified && !clientConditional(r)) ||
+			(code >= http.StatusInternalServerError && fr.MayServeOnError)
+	})
+	next.ServeHTTP(rec, conditionalRequest(r, entry))
+	rec.finish()
+	if !rec.intercepted {
+		return
+	}
+
+	if rec.code == http.StatusNotModified {
+		updated := f.refresh(entry, rec.header, rec.requestTime)
+		if err := ResponseCache.Update(key, updated); err == nil && writeCached(w, r, updated, updated.Age(time.Now()), "REVALIDATED") {
+			return
+		}
+	} else if writeCached(w, r, entry, fr.Age, "STALE") {
+		return
+	}
+	http.Error(w, "Cached response unavailable", http.StatusBadGateway)
+}
+
+func (f *CacheFilter) revalidateInBackground(next http.Handler, r *http.Request, primary, key string, entry *cache.Entry) {
+	if _, leader := cacheFlights.join("revalidate|" + key); !leader {
+		return
+	}
+	defer cacheFlights.finish("revalidate|" + key)
+
+	bg := r.Clone(context.Background())
+	bg.Method = http.MethodGet
+	rec := f.newRecorder(nil, bg, primary, func(code int) bool {
+		return code == http.StatusNotModified || code >= http.StatusInternalServerError
+	})
+	next.ServeHTTP(rec, conditionalRequest(bg, entry))
+	rec.finish()
+
+	switch {
+	case rec.code == http.StatusNotModified:
+		ResponseCache.Update(key, f.refresh(entry, rec.header, rec.requestTime))
+	case rec.intercepted:
+		log.Printf("Cache revalidation of %s failed with %d", r.URL.Path, rec.code)
+	}
+}
+
+// conditionalRequest adds validators taken from entry unless the client sent
+// its own.
+func conditionalRequest(r *http.Request, entry *cache.Entry) *http.Request {
+	if clientConditional(r) {
+		return r
+	}
+	cr := r.Clone(r.Context())
+	if etag := entry.Header.Get("ETag"); etag != "" {
+		cr.Header.Set("If-None-Match", etag)
+	}
+	if modified := entry.Header.Get("Last-Modified"); modified != "" {
+		cr.Header.Set("If-Modified-Since", modified)
+	}
+	return cr
+}
+
+// refresh applies the headers of a 304 to a stored entry.
+func (f *CacheFilter) refresh(entry *cache.Entry, header http.Header, requestTime time.Time) *cache.Entry {
+	updated := *entry
+	updated.Header = entry.Header.Clone()
+	for k, vs := range header {
+		if k == "Content-Length" || k == "X-Cache" {
+			continue
+		}
+		updated.Header[k] = vs
+	}
+	updated.RequestTime = requestTime
+	updated.ResponseTime = time.Now()
+	updated.ExpiresAt = f.expiresAt(&updated)
+	return &updated
+}
+
+// begin opens a store writer for a response about to stream through, or
+// returns nil when it must not be stored.
+func (f *CacheFilter) begin(r *http.Request, primary string, status int, header http.Header, requestTime time.Time) cache.BodyWriter {
+	if !cache.Storable(r, status, header) {
+		return nil
+	}
+	// Keys do not tell consumers apart, so what one consumer is sent is only
+	// shared when the upstream marks it public.
+	if _, identified := ConsumerFromContext(r.Context()); identified && !cache.ParseCacheControl(header).Has("public") {
+		return nil
+	}
+	if size, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil && f.Settings.MaxEntrySize > 0 && size > f.Settings.MaxEntrySize {
+		return nil
+	}
+
+	entry := &cache.Entry{
+		Scope:        f.Scope,
+		Path:         r.URL.Path,
+		Tags:         f.tags(header),
+		Status:       status,
+		Header:       header.Clone(),
+		RequestTime:  requestTime,
+		ResponseTime: time.Now(),
+	}
+	entry.Header.Del("X-Cache")
+	if entry.Lifetime(f.Settings.TTL) <= 0 && entry.Header.Get("ETag") == "" && entry.Header.Get("Last-Modified") == "" {
+		// Never fresh and impossible to revalidate: not worth keeping.
+		return nil
+	}
+	entry.ExpiresAt = f.expiresAt(entry)
+
+	key := primary
+	if vary, _ := cache.ParseVary(header); len(vary) > 0 {
+		placeholder := &cache.Entry{Scope: f.Scope, Path: r.URL.Path, Tags: entry.Tags, Vary: vary, ExpiresAt: entry.ExpiresAt}
+		if err := cache.Set(ResponseCache, primary, placeholder, nil); err != nil {
+			log.Printf("Could not store cache entry: %v", err)
+			return nil
+		}
+		key = cache.VaryKey(primary, vary, r)
+	}
+
+	w, err := ResponseCache.Put(key, entry)
+	if err != nil {
+		log.Printf("Could not store cache entry: %v", err)
+		return nil
+	}
+	return w
+}
+
+// expiresAt is when an entry stops being useful even stale.
+func (f *CacheFilter) expiresAt(entry *cache.Entry) time.Time {
+	res := cache.ParseCacheControl(entry.Header)
+	keep := f.Settings.StaleWhileRevalidate
+	if swr, ok := res.Seconds("stale-while-revalidate"); ok {
+		keep = swr
+	}
+	sie, ok := res.Seconds("stale-if-error")
+	if !ok {
+		sie = f.Settings.StaleIfError
+	}
+	if sie > keep {
+		keep = sie
+	}
+	if (entry.Header.Get("ETag") != "" || entry.Header.Get("Last-Modified") != "") && keep < revalidateKeep {
+		keep = revalidateKeep
+	}
+	return entry.ResponseTime.Add(entry.Lifetime(f.Settings.TTL) + keep)
+}
+
+// invalidate drops the stored response for a URL after a successful unsafe
+// request to it.
+func (f *CacheFilter) invalidate(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	next.ServeHTTP(ww, r)
+	if ww.Status() < http.StatusBadRequest {
+		get := r.Clone(r.Context())
+		get.Method = http.MethodGet
+		ResponseCache.Delete(f.key(get))
+	}
+}
+
+func (f *CacheFilter) key(r *http.Request) string {
+	query := r.URL.Query()
+	if len(f.Settings.QueryParams) > 0 {
+		selected := url.Values{}
+		for _, name := range f.Settings.QueryParams {
+			if vs, ok := query[name]; ok {
+				selected[name] = vs
+			}
+		}
+		query = selected
+	}
+	return f.Scope + "|GET " + r.Host + r.URL.Path + "?" + query.Encode()
+}
+
+func (f *CacheFilter) tags(header http.Header) []string {
+	if f.Settings.TagHeader == "" {
+		return nil
+	}
+	var tags []string
+	for _, v := range header.Values(f.Settings.TagHeader) {
+		tags = append(tags, strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })...)
+	}
+	return tags
+}
+
+// notModified evaluates the client's own validators against the entry.
+func notModified(r *http.Request, entry *cache.Entry) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := strings.TrimPrefix(entry.Header.Get("ETag"), "W/")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(entry.Header.Get("Last-Modified"))
+	return err == nil && !modified.After(since)
+}
+
+func clientConditional(r *http.Request) bool {
+	return r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != ""
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, vs := range src {
+		dst[k] = append([]string(nil), vs...)
+	}
+}
+
+// writeCached answers from a stored entry. It reports false, leaving w
+// untouched, when the entry's body is gone.
+func writeCached(w http.ResponseWriter, r *http.Request, entry *cache.Entry, age time.Duration, status string) bool {
+	var body io.ReadCloser
+	conditional := notModified(r, entry)
+	if !conditional && r.Method != http.MethodHead {
+		var err error
+		if body, err = ResponseCache.Open(entry); err != nil {
+			ResponseCache.Delete(entry.Key)
+			return false
+		}
+		defer body.Close()
+	}
+
+	copyHeader(w.Header(), entry.Header)
+	w.Header().Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	w.Header().Set("X-Cache", status)
+	if conditional {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	w.WriteHeader(entry.Status)
+	if body != nil {
+		io.Copy(w, body)
+	}
+	return true
+}
+
+func (f *CacheFilter) Convert(filter GenericFilter) {
+	f.Name = filter.Name
+	settings := CacheSettings{MaxEntrySize: 1 << 20, TagHeader: "Cache-Tag", Coalesce: true}
+
+	if ttl, ok := intSetting(filter.Settings, "ttl"); ok && ttl > 0 {
+		settings.TTL = time.Duration(ttl) * time.Second
+	}
+	if swr, ok := intSetting(filter.Settings, "stale_while_revalidate"); ok && swr > 0 {
+		settings.StaleWhileRevalidate = time.Duration(swr) * time.Second
+	}
+	if sie, ok := intSetting(filter.Settings, "stale_if_error"); ok && sie > 0 {
+		settings.StaleIfError = time.Duration(sie) * time.Second
+	}
+	if size, ok := intSetting(filter.Settings, "max_entry_size"); ok && size > 0 {
+		settings.MaxEntrySize = int64(size)
+	}
+	settings.QueryParams = stringList(filter.Settings["query_params"])
+	sort.Strings(settings.QueryParams)
+	if header, ok := filter.Settings["tag_header"].(string); ok {
+		settings.TagHeader = header
+	}
+	if coalesce, ok := filter.Settings["coalesce"].(bool); ok {
+		settings.Coalesce = coalesce
+	}
+
+	f.Settings = settings
+}
+
+// cacheRecorder sits between the upstream and the client. Responses pass
+// through to w (if any) while being streamed into the store; responses the
+// intercept func claims are held back for the filter to handle.
+type cacheRecorder struct {
+	f           *CacheFilter
+	w           http.ResponseWriter
+	r           *http.Request
+	primary     string
+	intercept   func(code int) bool
+	header      http.Header
+	code        int
+	intercepted bool
+	body        cache.BodyWriter
+	written     int64
+	requestTime time.Time
+}
+
+func (f *CacheFilter) newRecorder(w http.ResponseWriter, r *http.Request, primary string, intercept func(int) bool) *cacheRecorder {
+	return &cacheRecorder{f: f, w: w, r: r, primary: primary, intercept: intercept, header: http.Header{}, requestTime: time.Now()}
+}
+
+func (c *cacheRecorder) Header() http.Header {
+	return c.header
+}
+
+func (c *cacheRecorder) WriteHeader(code int) {
+	if c.code != 0 {
+		return
+	}
+	c.code = code
+	if c.intercept != nil && c.intercept(code) {
+		c.intercepted = true
+		return
+	}
+	c.body = c.f.begin(c.r, c.primary, code, c.header, c.requestTime)
+	if c.w != nil {
+		copyHeader(c.w.Header(), c.header)
+		c.w.Header().Set("X-Cache", "MISS")
+		c.w.WriteHeader(code)
+	}
+}
+
+func (c *cacheRecorder) Write(p []byte) (int, error) {
+	c.WriteHeader(http.StatusOK)
+	if c.intercepted {
+		return len(p), nil
+	}
+	if c.body != nil {
+		c.written += int64(len(p))
+		tooLarge := c.f.Settings.MaxEntrySize > 0 && c.written > c.f.Settings.MaxEntrySize
+		if _, err := c.body.Write(p); err != nil || tooLarge {
+			c.body.Abort()
+			c.body = nil
+		}
+	}
+	if c.w != nil {
+		return c.w.Write(p)
+	}
+	return len(p), nil
+}
+
+func (c *cacheRecorder) Flush() {
+	if flusher, ok := c.w.(http.Flusher); ok && !c.intercepted {
+		flusher.Flush()
+	}
+}
+
+// finish commits the stored copy once the upstream response is complete.
+func (c *cacheRecorder) finish() {
+	if c.code == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.body != nil {
+		if err := c.body.Commit(); err != nil {
+			log.Printf("Could not store cache entry: %v", err)
+		}
+		c.body = nil
+	}
+}
+
+// flightGroup lets one request fetch a key while others wait for it.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]chan struct{}
+}
+
+// join returns the channel closed when the key's fetch finishes and whether
+// the caller is the one expected to fetch it.
+func (g *flightGroup) join(key string) (<-chan struct{}, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if done, ok := g.calls[key]; ok {
+		return done, false
+	}
+	done := make(chan struct{})
+	g.calls[key] = done
+	return done, true
+}
+
+func (g *flightGroup) finish(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if done, ok := g.calls[key]; ok {
+		close(done)
+		delete(g.calls, key)
+	}
+}
@@ -0,0 +1,122 @@
+package filters
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"zentro/internal/cache"
+)
+
+func newCacheFilter(scope string, settings map[string]interface{}) *CacheFilter {
+	f := &CacheFilter{}
+	f.Convert(GenericFilter{Name: "Cache", Settings: settings})
+	f.SetScope(scope)
+	return f
+}
+
+func TestCacheFilter_HitAndPurge(t *testing.T) {
+	var calls atomic.Int32
+	handler := newCacheFilter("cache-hit", nil).Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Cache-Tag", "users")
+		w.Write([]byte("hello"))
+	}))
+
+	for i, want := range []string{"MISS", "HIT"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/users?id=1", nil))
+		if got := w.Header().Get("X-Cache"); got != want {
+			t.Errorf("Request %d: expected X-Cache %s, got %s", i, want, got)
+		}
+		if w.Body.String() != "hello" {
+			t.Errorf("Request %d: unexpected body %q", i, w.Body.String())
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected one upstream call, got %d", calls.Load())
+	}
+
+	if n := ResponseCache.Purge(cache.PurgeFilter{Tag: "users"}); n != 1 {
+		t.Errorf("Expected one purged entry, got %d", n)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/users?id=1", nil))
+	if got := w.Header().Get("X-Cache"); got != "MISS" {
+		t.Errorf("Expected MISS after purge, got %s", got)
+	}
+}
+
+func TestCacheFilter_CoalescesMisses(t *testing.T) {
+	var calls atomic.Int32
+	handler := newCacheFilter("cache-coalesce", nil).Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("slow"))
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Errorf("Expected concurrent misses to share one upstream call, got %d", calls.Load())
+	}
+}
+
+func TestCacheFilter_StaleIfError(t *testing.T) {
+	var fail atomic.Bool
+	handler := newCacheFilter("cache-sie", nil).Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=0, stale-if-error=60")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("ok"))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/flaky", nil))
+	fail.Store(true)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/flaky", nil))
+	if w.Code != http.StatusOK || w.Header().Get("X-Cache") != "STALE" {
+		t.Errorf("Expected stale response on upstream error, got %d %s", w.Code, w.Header().Get("X-Cache"))
+	}
+}
+
+func TestCacheFilter_KeepsConsumerResponsesApart(t *testing.T) {
+	cacheControl := "max-age=60"
+	handler := newCacheFilter("cache-consumers", nil).Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		consumer, _ := ConsumerFromContext(r.Context())
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Write([]byte("orders of " + consumer.ID))
+	}))
+	get := func(consumerID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/orders", nil)
+		req.Header.Set("X-Api-Key", "key-of-"+consumerID)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req.WithContext(WithConsumer(req.Context(), &Consumer{ID: consumerID})))
+		return w
+	}
+
+	get("alice")
+	if w := get("bob"); w.Body.String() != "orders of bob" || w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("Expected bob not to be served alice's response, got %s %q", w.Header().Get("X-Cache"), w.Body.String())
+	}
+
+	cacheControl = "public, max-age=60"
+	get("alice")
+	if w := get("bob"); w.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected a public response to be shared, got %s", w.Header().Get("X-Cache"))
+	}
+}
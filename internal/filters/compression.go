@@ -0,0 +1,404 @@
+package filters
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionFilter compresses responses for clients that accept it and can
+// decompress request bodies for the filters behind it.
+type CompressionFilter struct {
+	Name     string
+	Settings CompressionSettings
+}
+
+type CompressionSettings struct {
+	// Algorithms in order of preference among those the client accepts
+	// equally: any of "br", "zstd" and "gzip".
+	Algorithms   []string
+	MinSize      int
+	ContentTypes []string
+	// DecompressRequest decodes compressed request bodies, up to
+	// MaxRequestSize bytes once decoded.
+	DecompressRequest bool
+	MaxRequestSize    int64
+}
+
+var defaultCompressibleTypes = []string{
+	"text/*",
+	"application/json",
+	"application/*+json",
+	"application/javascript",
+	"application/xml",
+	"application/*+xml",
+	"image/svg+xml",
+}
+
+func (f CompressionFilter) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f.Settings.DecompressRequest {
+			if err := decompressRequest(r, f.Settings.MaxRequestSize); err != nil {
+				log.Printf("Could not decompress request body: %v", err)
+				http.Error(w, "Unsupported Content-Encoding", http.StatusUnsupportedMediaType)
+				return
+			}
+		}
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), f.Settings.Algorithms)
+		if r.Method == http.MethodHead {
+			encoding = ""
+		}
+		cw := &compressWriter{ResponseWriter: w, settings: f.Settings, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// decompressRequest replaces a compressed request body with its decoded form.
+func decompressRequest(r *http.Request, limit int64) error {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	decoded, err := newDecoder(encoding, r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = newLimitedBody(decoded, limit, r.Body)
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return nil
+}
+
+// newDecoder wraps body with a decoder for a Content-Encoding.
+func newDecoder(encoding string, body io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		return zlib.NewReader(body)
+	case "br":
+		return io.NopCloser(brotli.NewReader(body)), nil
+	case "zstd":
+		d, err := zstd.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// decodable reports whether newDecoder supports a Content-Encoding.
+func decodable(encoding string) bool {
+	switch encoding {
+	case "gzip", "x-gzip", "deflate", "br", "zstd":
+		return true
+	default:
+		return false
+	}
+}
+
+// limitedBody fails reads past a decoded size limit, guarding against
+// decompression bombs.
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+	unlimited bool
+	original  io.Closer
+}
+
+// newLimitedBody limits decoded to limit bytes; a limit of zero or less
+// disables the check.
+func newLimitedBody(decoded io.ReadCloser, limit int64, original io.Closer) *limitedBody {
+	return &limitedBody{ReadCloser: decoded, limit: limit, remaining: limit, unlimited: limit <= 0, original: original}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.unlimited {
+		return b.ReadCloser.Read(p)
+	}
+	if b.remaining < 0 {
+		return 0, &http.MaxBytesError{Limit: b.limit}
+	}
+	// Reading one byte past the limit tells a body of exactly the limit
+	// from a longer one.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if b.remaining -= int64(n); b.remaining < 0 {
+		return n - 1, &http.MaxBytesError{Limit: b.limit}
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	b.ReadCloser.Close()
+	return b.original.Close()
+}
+
+// negotiateEncoding picks the supported encoding with the highest q-value,
+// breaking ties by the configured preference.
+func negotiateEncoding(accept string, preferred []string) string {
+	if accept == "" {
+		return ""
+	}
+	q := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = parsed
+			}
+		}
+		q[strings.ToLower(strings.TrimSpace(name))] = weight
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range preferred {
+		weight, ok := q[enc]
+		if !ok {
+			weight, ok = q["*"]
+		}
+		if ok && weight > bestQ {
+			best, bestQ = enc, weight
+		}
+	}
+	return best
+}
+
+// contentTypeAllowed matches a Content-Type against patterns such as
+// "application/json", "text/*" or "application/*+json".
+func contentTypeAllowed(contentType string, patterns []string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if mediaType == "" {
+		return false
+	}
+	kind, sub, _ := strings.Cut(mediaType, "/")
+	for _, p := range patterns {
+		pKind, pSub, _ := strings.Cut(strings.ToLower(p), "/")
+		if pKind != kind && pKind != "*" {
+			continue
+		}
+		if pSub == "*" || pSub == sub {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(pSub, "*"); ok && strings.HasSuffix(sub, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter buffers the start of a response until it knows whether it
+// is worth compressing, then streams it through an encoder or unchanged.
+type compressWriter struct {
+	http.ResponseWriter
+	settings CompressionSettings
+	encoding string
+
+	code         int
+	buf          bytes.Buffer
+	compressible bool
+	decided      bool
+	encoder      io.WriteCloser
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if c.code != 0 || c.decided {
+		return
+	}
+	c.code = code
+	// Informational and bodyless responses go out as they are.
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		c.decide(false)
+		return
+	}
+	if c.compressible = c.eligible(); !c.compressible {
+		c.decide(false)
+		return
+	}
+	if size, err := strconv.Atoi(c.Header().Get("Content-Length")); err == nil {
+		c.decide(size >= c.settings.MinSize)
+	}
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if c.code == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	if !c.decided {
+		c.buf.Write(p)
+		if c.buf.Len() >= c.settings.MinSize {
+			if err := c.decide(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	if c.encoder != nil {
+		return c.encoder.Write(p)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+// eligible reports whether the response may be compressed at all. It also
+// advertises that the response varies by Accept-Encoding.
+func (c *compressWriter) eligible() bool {
+	h := c.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" || c.code == http.StatusPartialContent {
+		return false
+	}
+	if strings.Contains(strings.ToLower(h.Get("Cache-Control")), "no-transform") {
+		return false
+	}
+	types := c.settings.ContentTypes
+	if len(types) == 0 {
+		types = defaultCompressibleTypes
+	}
+	if !contentTypeAllowed(h.Get("Content-Type"), types) {
+		return false
+	}
+	h.Add("Vary", "Accept-Encoding")
+	return true
+}
+
+// decide sends the headers, switching to the encoder when compress is set
+// and the client accepts an encoding, then releases the buffered bytes.
+func (c *compressWriter) decide(compress bool) error {
+	c.decided = true
+	if c.code == 0 {
+		c.code = http.StatusOK
+	}
+	if compress && c.encoding != "" {
+		h := c.Header()
+		h.Set("Content-Encoding", c.encoding)
+		h.Del("Content-Length")
+		weakenETag(h)
+		c.encoder = newEncoder(c.encoding, c.ResponseWriter)
+	}
+	c.ResponseWriter.WriteHeader(c.code)
+
+	if c.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if c.encoder != nil {
+		_, err = c.encoder.Write(c.buf.Bytes())
+	} else {
+		_, err = c.ResponseWriter.Write(c.buf.Bytes())
+	}
+	c.buf.Reset()
+	return err
+}
+
+func newEncoder(encoding string, w io.Writer) io.WriteCloser {
+	switch encoding {
+	case "br":
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	case "zstd":
+		enc, _ := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return enc
+	default:
+		return gzip.NewWriter(w)
+	}
+}
+
+// Flush sends what has been written so far, compressing it if the response
+// qualified; streaming responses should not wait for MinSize.
+func (c *compressWriter) Flush() {
+	if c.code == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	if !c.decided {
+		c.decide(c.compressible)
+	}
+	if flusher, ok := c.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close finishes the response: short bodies go out uncompressed.
+func (c *compressWriter) Close() error {
+	if c.code == 0 {
+		// The handler wrote nothing; let the server send its default reply.
+		return nil
+	}
+	if !c.decided {
+		c.decide(false)
+	}
+	if c.encoder != nil {
+		return c.encoder.Close()
+	}
+	return nil
+}
+
+func (c *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := c.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("hijacking not supported")
+}
+
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+func (f *CompressionFilter) Convert(filter GenericFilter) {
+	f.Name = filter.Name
+	settings := CompressionSettings{
+		Algorithms:     []string{"br", "zstd", "gzip"},
+		MinSize:        1024,
+		MaxRequestSize: 10 << 20,
+	}
+
+	if algorithms := stringList(filter.Settings["algorithms"]); len(algorithms) > 0 {
+		settings.Algorithms = nil
+		for _, a := range algorithms {
+			switch a {
+			case "br", "zstd", "gzip":
+				settings.Algorithms = append(settings.Algorithms, a)
+			default:
+				log.Printf("Unsupported compression algorithm %q for Compression filter, ignoring.", a)
+			}
+		}
+	}
+	if size, ok := intSetting(filter.Settings, "min_size"); ok && size >= 0 {
+		settings.MinSize = size
+	}
+	settings.ContentTypes = stringList(filter.Settings["content_types"])
+	settings.DecompressRequest, _ = filter.Settings["decompress_request"].(bool)
+	if size, ok := intSetting(filter.Settings, "max_request_size"); ok {
+		settings.MaxRequestSize = int64(size)
+	}
+
+	f.Settings = settings
+}
+
+// weakenETag marks a strong ETag weak, for a body that is no longer byte for
+// byte what the upstream tagged.
+func weakenETag(h http.Header) {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+}
@@ -0,0 +1,141 @@
+package filters
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	preferred := []string{"br", "zstd", "gzip"}
+	cases := map[string]string{
+		"":                       "",
+		"gzip":                   "gzip",
+		"gzip, br":               "br",
+		"gzip;q=1, br;q=0.5":     "gzip",
+		"br;q=0, gzip":           "gzip",
+		"*":                      "br",
+		"identity":               "",
+		"zstd;q=0.8, gzip;q=0.8": "zstd",
+	}
+	for accept, want := range cases {
+		if got := negotiateEncoding(accept, preferred); got != want {
+			t.Errorf("Expected %q for Accept-Encoding %q, got %q", want, accept, got)
+		}
+	}
+}
+
+func compressionHandler(contentType string, body string) http.Handler {
+	filter := &CompressionFilter{}
+	filter.Convert(GenericFilter{Name: "Compression", Settings: map[string]interface{}{"min_size": 100.0}})
+	return filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, body)
+	}))
+}
+
+func TestCompressionFilter_CompressesEligibleResponses(t *testing.T) {
+	body := strings.Repeat(`{"hello":"world"}`, 20)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	compressionHandler("application/json", body).ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip encoding, got %q", rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", rec.Header().Get("Vary"))
+	}
+	if rec.Header().Get("ETag") != `W/"v1"` {
+		t.Errorf("Expected weak ETag, got %q", rec.Header().Get("ETag"))
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Expected gzip body: %v", err)
+	}
+	decoded, _ := io.ReadAll(zr)
+	if string(decoded) != body {
+		t.Errorf("Expected body to round-trip, got %q", decoded)
+	}
+}
+
+func TestCompressionFilter_SkipsSmallAndIneligibleResponses(t *testing.T) {
+	cases := []struct {
+		contentType string
+		body        string
+	}{
+		{"application/json", `{"small":true}`},
+		{"image/png", strings.Repeat("x", 500)},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip, br")
+		rec := httptest.NewRecorder()
+		compressionHandler(c.contentType, c.body).ServeHTTP(rec, req)
+
+		if rec.Header().Get("Content-Encoding") != "" {
+			t.Errorf("Expected %s response of %d bytes to be left alone", c.contentType, len(c.body))
+		}
+		if rec.Body.String() != c.body {
+			t.Errorf("Expected body to be unchanged for %s", c.contentType)
+		}
+	}
+}
+
+func TestCompressionFilter_DecompressesRequests(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(`{"name":"zentro"}`))
+	zw.Close()
+
+	filter := &CompressionFilter{}
+	filter.Convert(GenericFilter{Name: "Compression", Settings: map[string]interface{}{"decompress_request": true}})
+	var received string
+	handler := filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received = string(data)
+		if r.Header.Get("Content-Encoding") != "" {
+			t.Errorf("Expected Content-Encoding to be removed")
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", &compressed)
+	req.Header.Set("Content-Encoding", "gzip")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if received != `{"name":"zentro"}` {
+		t.Errorf("Expected decompressed body, got %q", received)
+	}
+}
+
+func TestLimitedBody_StopsAtDecodedLimit(t *testing.T) {
+	read := func(size, limit int64) (int, error) {
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		zw.Write(bytes.Repeat([]byte("a"), int(size)))
+		zw.Close()
+		decoded, _ := newDecoder("gzip", io.NopCloser(&compressed))
+		data, err := io.ReadAll(newLimitedBody(decoded, limit, io.NopCloser(nil)))
+		return len(data), err
+	}
+
+	if n, err := read(1024, 1024); err != nil || n != 1024 {
+		t.Errorf("Expected a body of exactly the limit to be read, got %d bytes, %v", n, err)
+	}
+	if n, err := read(1025, 1024); err == nil || n > 1024 {
+		t.Errorf("Expected one byte past the limit to fail, got %d bytes, %v", n, err)
+	}
+	if _, err := read(50<<20, 10<<20); err == nil {
+		t.Errorf("Expected a decompression bomb to fail")
+	} else if status, _ := BodyErrorStatus(err); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for an oversized body, got %d", status)
+	}
+	if n, err := read(4096, 0); err != nil || n != 4096 {
+		t.Errorf("Expected no limit to read everything, got %d bytes, %v", n, err)
+	}
+}
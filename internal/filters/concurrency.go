@@ -0,0 +1,138 @@
+package filters
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+	"zentro/internal/clientip"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// RecordRejection is called whenever a filter turns a request away. The
+// gateway points it at its metrics collector.
+var RecordRejection = func(filter, reason string) {}
+
+type upstreamContextKey struct{}
+
+// WithUpstream returns a copy of ctx carrying the upstream chosen for the request.
+func WithUpstream(ctx context.Context, upstream string) context.Context {
+	return context.WithValue(ctx, upstreamContextKey{}, upstream)
+}
+
+// UpstreamFromContext returns the upstream chosen for the request, if known.
+func UpstreamFromContext(ctx context.Context) string {
+	upstream, _ := ctx.Value(upstreamContextKey{}).(string)
+	return upstream
+}
+
+type routeNameContextKey struct{}
+
+// WithRouteName returns a copy of ctx carrying the name of the matched route.
+func WithRouteName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, routeNameContextKey{}, name)
+}
+
+// RouteNameFromContext returns the name of the matched route, if known.
+func RouteNameFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(routeNameContextKey{}).(string)
+	return name
+}
+
+// ConcurrencyFilter caps in-flight requests (a bulkhead), queueing a bounded
+// number of extra requests for a limited time.
+type ConcurrencyFilter struct {
+	Name     string
+	Settings ConcurrencySettings
+	Scope    string
+}
+
+type ConcurrencySettings struct {
+	MaxConcurrent int
+	MaxQueue      int
+	QueueTimeout  time.Duration
+	// KeyBy partitions the limit: "route" (default), "upstream" or "consumer".
+	KeyBy  string
+	Policy BulkheadPolicy
+}
+
+func (f *ConcurrencyFilter) SetScope(scope string) {
+	f.Scope = scope
+}
+
+func (f *ConcurrencyFilter) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := Bulkheads.Get(f.Scope+"|"+f.partition(r), f.Settings.MaxConcurrent)
+		if !f.Settings.Policy.Adaptive {
+			b.SetLimit(f.Settings.MaxConcurrent)
+		}
+
+		if reason := b.Acquire(r.Context(), f.Settings.MaxQueue, f.Settings.QueueTimeout); reason != "" {
+			RecordRejection("Concurrency", reason)
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Too many concurrent requests", http.StatusServiceUnavailable)
+			return
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		defer func() {
+			b.Release(time.Since(start), ww.Status() >= http.StatusInternalServerError, f.Settings.Policy)
+		}()
+		next.ServeHTTP(ww, r)
+	})
+}
+
+func (f *ConcurrencyFilter) partition(r *http.Request) string {
+	switch f.Settings.KeyBy {
+	case "upstream":
+		return "upstream:" + UpstreamFromContext(r.Context())
+	case "consumer":
+		if c, ok := ConsumerFromContext(r.Context()); ok {
+			return "consumer:" + c.ID
+		}
+		return "ip:" + clientip.String(r)
+	default:
+		return "route"
+	}
+}
+
+func (f *ConcurrencyFilter) Convert(filter GenericFilter) {
+	f.Name = filter.Name
+	settings := ConcurrencySettings{MaxConcurrent: 100, QueueTimeout: time.Second, KeyBy: "route"}
+
+	if max, ok := intSetting(filter.Settings, "max_concurrent"); ok && max > 0 {
+		settings.MaxConcurrent = max
+	}
+	if queue, ok := intSetting(filter.Settings, "max_queue"); ok && queue >= 0 {
+		settings.MaxQueue = queue
+	}
+	if timeout, ok := intSetting(filter.Settings, "queue_timeout_ms"); ok && timeout >= 0 {
+		settings.QueueTimeout = time.Duration(timeout) * time.Millisecond
+	}
+	if keyBy, ok := filter.Settings["key_by"].(string); ok {
+		switch keyBy {
+		case "route", "upstream", "consumer":
+			settings.KeyBy = keyBy
+		default:
+			log.Println("key_by must be route, upstream or consumer for Concurrency, defaulting to route.")
+		}
+	}
+
+	settings.Policy.Adaptive, _ = filter.Settings["adaptive"].(bool)
+	settings.Policy.MinLimit = 1
+	if min, ok := intSetting(filter.Settings, "min_limit"); ok && min > 0 {
+		settings.Policy.MinLimit = min
+	}
+	settings.Policy.MaxLimit = 10 * settings.MaxConcurrent
+	if max, ok := intSetting(filter.Settings, "max_limit"); ok && max > 0 {
+		settings.Policy.MaxLimit = max
+	}
+	if target, ok := intSetting(filter.Settings, "target_latency_ms"); ok && target > 0 {
+		settings.Policy.TargetLatency = time.Duration(target) * time.Millisecond
+	}
+
+	f.Settings = settings
+}
+
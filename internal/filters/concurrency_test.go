@@ -0,0 +1,97 @@
+package filters
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBulkhead_QueueAndReject(t *testing.T) {
+	b := newBulkhead(1)
+	ctx := context.Background()
+
+	if reason := b.Acquire(ctx, 1, time.Second); reason != "" {
+		t.Fatalf("Expected first request to get a slot, got %s", reason)
+	}
+
+	queued := make(chan string)
+	go func() { queued <- b.Acquire(ctx, 1, time.Second) }()
+	time.Sleep(20 * time.Millisecond)
+
+	if reason := b.Acquire(ctx, 1, time.Second); reason != RejectQueueFull {
+		t.Errorf("Expected %s with a full queue, got %q", RejectQueueFull, reason)
+	}
+
+	b.Release(time.Millisecond, false, BulkheadPolicy{})
+	if reason := <-queued; reason != "" {
+		t.Errorf("Expected queued request to get the released slot, got %s", reason)
+	}
+
+	if reason := b.Acquire(ctx, 1, 10*time.Millisecond); reason != RejectQueueTimeout {
+		t.Errorf("Expected %s, got %q", RejectQueueTimeout, reason)
+	}
+}
+
+func TestBulkhead_RaisedLimitWakesQueue(t *testing.T) {
+	b := newBulkhead(1)
+	ctx := context.Background()
+	b.Acquire(ctx, 2, time.Second)
+
+	queued := make(chan string, 2)
+	for i := 0; i < 2; i++ {
+		go func() { queued <- b.Acquire(ctx, 2, time.Second) }()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	b.SetLimit(3)
+	for i := 0; i < 2; i++ {
+		select {
+		case reason := <-queued:
+			if reason != "" {
+				t.Errorf("Expected queued request to get a new slot, got %s", reason)
+			}
+		case <-time.After(200 * time.Millisecond):
+			t.Fatalf("Expected raising the limit to wake queued requests")
+		}
+	}
+}
+
+func TestBulkhead_AdaptiveLimit(t *testing.T) {
+	policy := BulkheadPolicy{Adaptive: true, MinLimit: 2, MaxLimit: 20, TargetLatency: 100 * time.Millisecond}
+	b := newBulkhead(10)
+
+	for i := 0; i < 50; i++ {
+		b.Acquire(context.Background(), 0, 0)
+		b.Release(time.Second, false, policy)
+	}
+	if got := b.Limit(); got != 2 {
+		t.Errorf("Expected slow responses to shrink the limit to 2, got %d", got)
+	}
+}
+
+func TestConcurrencyFilter_Rejects(t *testing.T) {
+	f := &ConcurrencyFilter{}
+	f.Convert(GenericFilter{Name: "Concurrency", Settings: map[string]interface{}{"max_concurrent": float64(1)}})
+	f.SetScope("test-reject")
+
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	handler := f.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wg.Done()
+		<-release
+	}))
+
+	wg.Add(1)
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	wg.Wait()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while the slot is taken, got %d", w.Code)
+	}
+	close(release)
+}
@@ -0,0 +1,56 @@
+package filters
+
+import "context"
+
+// Consumer is the identity attached to a request once a credential has been
+// matched against the consumer registry.
+type Consumer struct {
+	ID           string
+	Username     string
+	Groups       []string
+	CredentialID string
+	// Limits overrides rate limits and quotas for this consumer, if set.
+	Limits *ConsumerLimits
+}
+
+// ConsumerLookup resolves a credential presented with the given auth type
+// ("bearer", "basic", "api-key" or "mtls") to a registered consumer.
+type ConsumerLookup func(authType, credential string) (*Consumer, bool)
+
+type consumerContextKey struct{}
+
+// WithConsumer returns a copy of ctx carrying the authenticated consumer.
+func WithConsumer(ctx context.Context, c *Consumer) context.Context {
+	return context.WithValue(ctx, consumerContextKey{}, c)
+}
+
+// ConsumerFromContext returns the consumer authenticated for the request, if any.
+func ConsumerFromContext(ctx context.Context) (*Consumer, bool) {
+	c, ok := ctx.Value(consumerContextKey{}).(*Consumer)
+	return c, ok && c != nil
+}
+
+type tokenClaimsContextKey struct{}
+
+// WithTokenClaims returns a copy of ctx carrying the claims of the verified
+// token the consumer presented.
+func WithTokenClaims(ctx context.Context, claims map[string]interface{}) context.Context {
+	return context.WithValue(ctx, tokenClaimsContextKey{}, claims)
+}
+
+// TokenClaimsFromContext returns the claims of the presented token, if it
+// was a verified JWT.
+func TokenClaimsFromContext(ctx context.Context) map[string]interface{} {
+	claims, _ := ctx.Value(tokenClaimsContextKey{}).(map[string]interface{})
+	return claims
+}
+
+// InGroup reports whether the consumer is a member of group.
+func (c *Consumer) InGroup(group string) bool {
+	for _, g := range c.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
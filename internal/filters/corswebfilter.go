@@ -96,6 +96,11 @@ func (f *CorsWebFilter) Convert(filter GenericFilter) {
 	f.Settings = settings
 }
 
+// AllowsOrigin reports whether origin may make cross-origin requests.
+func (f CorsWebFilter) AllowsOrigin(origin string) bool {
+	return f.isOriginAllowed(origin)
+}
+
 func (f CorsWebFilter) isOriginAllowed(origin string) bool {
 	if len(f.Settings.AllowOrigins) == 0 {
 		return false
@@ -0,0 +1,298 @@
+package filters
+
+import (
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RecordFault is called for every fault injected. The gateway points it at
+// its metrics collector.
+var RecordFault = func(fault string) {}
+
+// FaultInjectionFilter injects delays, errors and broken responses into a
+// share of the requests to test how clients cope with them.
+type FaultInjectionFilter struct {
+	Name     string
+	Settings FaultInjectionSettings
+}
+
+type FaultInjectionSettings struct {
+	// TriggerHeader limits faults to requests carrying the header, with
+	// TriggerValue as its value when set.
+	TriggerHeader string
+	TriggerValue  string
+	Delay         *DelayFault
+	Abort         *AbortFault
+	Reset         *Fault
+	Truncate      *TruncateFault
+	Corrupt       *CorruptFault
+}
+
+// Fault is injected into Percentage percent of the requests.
+type Fault struct {
+	Percentage float64
+}
+
+func (f Fault) roll() bool {
+	return rand.Float64()*100 < f.Percentage
+}
+
+type DelayFault struct {
+	Fault
+	// Distribution is fixed, uniform, normal or exponential.
+	Distribution string
+	Fixed        time.Duration
+	Min          time.Duration
+	Max          time.Duration
+	Mean         time.Duration
+	StdDev       time.Duration
+}
+
+type AbortFault struct {
+	Fault
+	// StatusCodes are picked from at random.
+	StatusCodes []int
+	Body        string
+}
+
+type TruncateFault struct {
+	Fault
+	// AfterBytes is how much of the response body is sent before the
+	// connection is cut.
+	AfterBytes int64
+}
+
+type CorruptFault struct {
+	Fault
+	// Bytes is how many bytes of the response body are flipped.
+	Bytes int
+}
+
+func (f FaultInjectionFilter) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := f.Settings
+		if s.TriggerHeader != "" {
+			value := r.Header.Get(s.TriggerHeader)
+			if value == "" || (s.TriggerValue != "" && value != s.TriggerValue) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		if s.Delay != nil && s.Delay.roll() {
+			RecordFault("delay")
+			timer := time.NewTimer(s.Delay.duration())
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		if s.Reset != nil && s.Reset.roll() {
+			RecordFault("reset")
+			resetConnection(w)
+			return
+		}
+
+		if s.Abort != nil && s.Abort.roll() {
+			status := http.StatusServiceUnavailable
+			if len(s.Abort.StatusCodes) > 0 {
+				status = s.Abort.StatusCodes[rand.Intn(len(s.Abort.StatusCodes))]
+			}
+			RecordFault("abort:" + strconv.Itoa(status))
+			w.Header().Set("X-Zentro-Fault", "abort")
+			http.Error(w, s.Abort.Body, status)
+			return
+		}
+
+		truncate := s.Truncate != nil && s.Truncate.roll()
+		corrupt := s.Corrupt != nil && s.Corrupt.roll()
+		if !truncate && !corrupt {
+			next.ServeHTTP(w, r)
+			return
+		}
+		fw := &faultWriter{ResponseWriter: w, truncateAt: -1}
+		if truncate {
+			RecordFault("truncate")
+			fw.truncateAt = s.Truncate.AfterBytes
+		}
+		if corrupt {
+			RecordFault("corrupt")
+			fw.corrupt = s.Corrupt.Bytes
+		}
+		next.ServeHTTP(fw, r)
+		if truncate {
+			// The body was shorter than the cut-off, cut it at the end.
+			fw.cut()
+		}
+	})
+}
+
+func (d *DelayFault) duration() time.Duration {
+	var delay float64
+	switch d.Distribution {
+	case "uniform":
+		delay = float64(d.Min) + rand.Float64()*float64(d.Max-d.Min)
+	case "normal":
+		delay = float64(d.Mean) + rand.NormFloat64()*float64(d.StdDev)
+	case "exponential":
+		delay = rand.ExpFloat64() * float64(d.Mean)
+	default:
+		return d.Fixed
+	}
+	if d.Max > 0 {
+		delay = math.Min(delay, float64(d.Max))
+	}
+	return time.Duration(math.Max(delay, 0))
+}
+
+// resetConnection drops the client connection without a response. HTTP/1
+// connections are closed with a TCP reset; other protocols abort the
+// stream.
+func resetConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// faultWriter corrupts the response body and cuts it short.
+type faultWriter struct {
+	http.ResponseWriter
+	truncateAt  int64
+	corrupt     int
+	written     int64
+	wroteHeader bool
+}
+
+func (fw *faultWriter) WriteHeader(code int) {
+	if code >= 200 {
+		fw.wroteHeader = true
+	}
+	fw.ResponseWriter.WriteHeader(code)
+}
+
+func (fw *faultWriter) Write(p []byte) (int, error) {
+	fw.wroteHeader = true
+	n := len(p)
+	if fw.truncateAt >= 0 && fw.written+int64(len(p)) >= fw.truncateAt {
+		p = p[:fw.truncateAt-fw.written]
+	}
+	if fw.corrupt > 0 && len(p) > 0 {
+		p = append([]byte(nil), p...)
+		for ; fw.corrupt > 0; fw.corrupt-- {
+			p[rand.Intn(len(p))] ^= 0xff
+		}
+	}
+	if _, err := fw.ResponseWriter.Write(p); err != nil {
+		return 0, err
+	}
+	fw.written += int64(len(p))
+	if fw.truncateAt >= 0 && fw.written >= fw.truncateAt {
+		fw.cut()
+	}
+	return n, nil
+}
+
+// cut sends what was written so far and aborts the connection, so the
+// client sees a body shorter than announced.
+func (fw *faultWriter) cut() {
+	if !fw.wroteHeader {
+		fw.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(fw.ResponseWriter).Flush()
+	panic(http.ErrAbortHandler)
+}
+
+func (fw *faultWriter) Unwrap() http.ResponseWriter {
+	return fw.ResponseWriter
+}
+
+func (f *FaultInjectionFilter) Convert(filter GenericFilter) {
+	f.Name = filter.Name
+	settings := FaultInjectionSettings{}
+	settings.TriggerHeader, _ = filter.Settings["trigger_header"].(string)
+	settings.TriggerValue, _ = filter.Settings["trigger_value"].(string)
+
+	if raw, ok := filter.Settings["delay"].(map[string]interface{}); ok {
+		d := &DelayFault{Fault: faultSetting(raw), Distribution: "fixed"}
+		if distribution, ok := raw["distribution"].(string); ok {
+			switch distribution {
+			case "fixed", "uniform", "normal", "exponential":
+				d.Distribution = distribution
+			default:
+				log.Printf("distribution must be fixed, uniform, normal or exponential for FaultInjectionFilter, defaulting to fixed.")
+			}
+		}
+		d.Fixed = msSetting(raw, "fixed_ms")
+		d.Min = msSetting(raw, "min_ms")
+		d.Max = msSetting(raw, "max_ms")
+		d.Mean = msSetting(raw, "mean_ms")
+		d.StdDev = msSetting(raw, "stddev_ms")
+		settings.Delay = d
+	}
+	if raw, ok := filter.Settings["abort"].(map[string]interface{}); ok {
+		a := &AbortFault{Fault: faultSetting(raw)}
+		codes, _ := raw["status_codes"].([]interface{})
+		for _, code := range codes {
+			if c, ok := code.(float64); ok && c >= 100 && c <= 999 {
+				a.StatusCodes = append(a.StatusCodes, int(c))
+			} else {
+				log.Println("status_codes must be HTTP status codes for FaultInjectionFilter")
+			}
+		}
+		if a.Body, ok = raw["body"].(string); !ok {
+			a.Body = "Fault injected"
+		}
+		settings.Abort = a
+	}
+	if raw, ok := filter.Settings["reset"].(map[string]interface{}); ok {
+		fault := faultSetting(raw)
+		settings.Reset = &fault
+	}
+	if raw, ok := filter.Settings["truncate"].(map[string]interface{}); ok {
+		t := &TruncateFault{Fault: faultSetting(raw)}
+		if after, ok := intSetting(raw, "after_bytes"); ok && after >= 0 {
+			t.AfterBytes = int64(after)
+		}
+		settings.Truncate = t
+	}
+	if raw, ok := filter.Settings["corrupt"].(map[string]interface{}); ok {
+		c := &CorruptFault{Fault: faultSetting(raw), Bytes: 1}
+		if n, ok := intSetting(raw, "bytes"); ok && n > 0 {
+			c.Bytes = n
+		}
+		settings.Corrupt = c
+	}
+	f.Settings = settings
+}
+
+// faultSetting reads a fault's percentage, which defaults to every request.
+func faultSetting(raw map[string]interface{}) Fault {
+	fault := Fault{Percentage: 100}
+	if p, ok := raw["percentage"].(float64); ok {
+		if p < 0 || p > 100 {
+			log.Println("percentage must be between 0 and 100 for FaultInjectionFilter")
+		}
+		fault.Percentage = math.Min(math.Max(p, 0), 100)
+	}
+	return fault
+}
+
+func msSetting(raw map[string]interface{}, key string) time.Duration {
+	if ms, ok := intSetting(raw, key); ok && ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+package filters
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newFaultServer(settings string) *httptest.Server {
+	var parsed map[string]interface{}
+	json.Unmarshal([]byte(settings), &parsed)
+	filter := &FaultInjectionFilter{}
+	filter.Convert(GenericFilter{Name: "FaultInjection", Settings: parsed})
+	return httptest.NewServer(filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "26")
+		w.Write([]byte("abcdefghijklmnopqrstuvwxyz"))
+	})))
+}
+
+func TestFaultInjection_AbortWithTrigger(t *testing.T) {
+	var faults []string
+	RecordFault = func(fault string) { faults = append(faults, fault) }
+	defer func() { RecordFault = func(string) {} }()
+
+	server := newFaultServer(`{"trigger_header": "X-Chaos", "abort": {"status_codes": [418]}}`)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected requests without the trigger to pass, got %v %v", resp, err)
+	}
+	resp.Body.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("X-Chaos", "1")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusTeapot {
+		t.Fatalf("Expected an injected 418, got %v %v", resp, err)
+	}
+	resp.Body.Close()
+	if len(faults) != 1 || faults[0] != "abort:418" {
+		t.Errorf("Expected the abort to be counted, got %v", faults)
+	}
+}
+
+func TestFaultInjection_Delay(t *testing.T) {
+	server := newFaultServer(`{"delay": {"fixed_ms": 50}}`)
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected a response: %v", err)
+	}
+	resp.Body.Close()
+	if time.Since(start) < 50*time.Millisecond {
+		t.Errorf("Expected the response to be delayed")
+	}
+}
+
+func TestFaultInjection_BrokenResponses(t *testing.T) {
+	truncated := newFaultServer(`{"truncate": {"after_bytes": 10}}`)
+	defer truncated.Close()
+	resp, err := http.Get(truncated.URL)
+	if err != nil {
+		t.Fatalf("Expected headers before the cut: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err == nil || string(body) != "abcdefghij" {
+		t.Errorf("Expected a body cut after 10 bytes, got %q, %v", body, err)
+	}
+
+	corrupted := newFaultServer(`{"corrupt": {"bytes": 3}}`)
+	defer corrupted.Close()
+	resp, err = http.Get(corrupted.URL)
+	if err != nil {
+		t.Fatalf("Expected a response: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if len(body) != 26 || string(body) == "abcdefghijklmnopqrstuvwxyz" {
+		t.Errorf("Expected a corrupted body of the same length, got %q", body)
+	}
+
+	reset := newFaultServer(`{"reset": {"percentage": 100}}`)
+	defer reset.Close()
+	if resp, err := http.Get(reset.URL); err == nil {
+		resp.Body.Close()
+		t.Errorf("Expected the connection to be reset")
+	}
+}
@@ -7,6 +7,13 @@ type Filter interface {
 	Convert(filter GenericFilter)
 }
 
+// Scoped is implemented by filters that keep state between requests. The
+// router gives every instance a scope unique to its route and position in
+// the chain, so state is never shared between routes.
+type Scoped interface {
+	SetScope(scope string)
+}
+
 
 type FilterType int
 
@@ -31,6 +38,20 @@ const (
     MapRequestHeaderFilterType
     PreserveHostHeaderFilterType
     RequestSizeFilterType
+    AclFilterType
+    ConcurrencyFilterType
+    CacheFilterType
+    CompressionFilterType
+    JsonTransformFilterType
+    SchemaValidationFilterType
+    MockFilterType
+    FaultInjectionFilterType
+    IpFilterType
+    RewriteResponseHeadersFilterType
+    SecurityHeadersFilterType
+    WafFilterType
+    HmacSignatureFilterType
+    InternalJwtFilterType
 )
 
 func FilterTypeFromName(name string) FilterType {
@@ -69,6 +90,34 @@ func FilterTypeFromName(name string) FilterType {
         return PreserveHostHeaderFilterType
     case "RequestSize":
         return RequestSizeFilterType
+    case "Acl":
+        return AclFilterType
+    case "Concurrency":
+        return ConcurrencyFilterType
+    case "Cache":
+        return CacheFilterType
+    case "Compression":
+        return CompressionFilterType
+    case "JsonTransform":
+        return JsonTransformFilterType
+    case "SchemaValidation":
+        return SchemaValidationFilterType
+    case "Mock":
+        return MockFilterType
+    case "FaultInjection":
+        return FaultInjectionFilterType
+    case "IpFilter":
+        return IpFilterType
+    case "RewriteResponseHeaders":
+        return RewriteResponseHeadersFilterType
+    case "SecurityHeaders":
+        return SecurityHeadersFilterType
+    case "Waf":
+        return WafFilterType
+    case "HmacSignature":
+        return HmacSignatureFilterType
+    case "InternalJwt":
+        return InternalJwtFilterType
     default:
         return UnknownFilter
     }
@@ -0,0 +1,54 @@
+package filters
+
+import "context"
+
+type preserveHostKey struct{}
+
+type forwardedPrefixKey struct{}
+
+// WithPreserveHost marks the request so the proxy sends the client's Host
+// header to the upstream instead of the upstream's own.
+func WithPreserveHost(ctx context.Context) context.Context {
+	return context.WithValue(ctx, preserveHostKey{}, true)
+}
+
+// PreserveHost reports whether the request was marked by WithPreserveHost.
+func PreserveHost(ctx context.Context) bool {
+	preserve, _ := ctx.Value(preserveHostKey{}).(bool)
+	return preserve
+}
+
+// WithForwardedPrefix records a path prefix removed from the request, which
+// the proxy reports in X-Forwarded-Prefix.
+func WithForwardedPrefix(ctx context.Context, prefix string) context.Context {
+	return context.WithValue(ctx, forwardedPrefixKey{}, ForwardedPrefix(ctx)+prefix)
+}
+
+// ForwardedPrefix returns the prefixes removed from the request so far.
+func ForwardedPrefix(ctx context.Context) string {
+	prefix, _ := ctx.Value(forwardedPrefixKey{}).(string)
+	return prefix
+}
+
+type pathChangesKey struct{}
+
+// PathChanges records how the filters of a route changed the request path,
+// so paths in responses can be mapped back.
+type PathChanges struct {
+	// Stripped is what StripPrefix removed from the front of the path.
+	Stripped string
+	// Added is what PrefixPath put in front of it.
+	Added string
+}
+
+// WithPathChanges returns a copy of ctx in which path changes are recorded.
+func WithPathChanges(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pathChangesKey{}, &PathChanges{})
+}
+
+// PathChangesFromContext returns the path changes recorded so far, or nil
+// when they are not tracked.
+func PathChangesFromContext(ctx context.Context) *PathChanges {
+	changes, _ := ctx.Value(pathChangesKey{}).(*PathChanges)
+	return changes
+}
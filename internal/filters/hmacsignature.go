@@ -0,0 +1,310 @@
+package filters
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HmacSecret is a key a signature can be checked against, with the
+// consumer it belongs to.
+type HmacSecret struct {
+	Key      []byte
+	Consumer *Consumer
+}
+
+// HmacSecrets returns the keys registered under a key id. The gateway
+// points it at the consumer registry.
+var HmacSecrets = func(keyID string) []HmacSecret { return nil }
+
+// TouchCredential is called with the id of a credential that verified a
+// request.
+var TouchCredential = func(id string) {}
+
+// HmacSignatureFilter verifies requests signed with a shared secret, as
+// sent by webhooks and partners.
+type HmacSignatureFilter struct {
+	Name     string
+	Settings HmacSignatureSettings
+}
+
+type HmacSignatureSettings struct {
+	Algorithm func() hash.Hash
+	// Secret verifies every request; without it the key is looked up by the
+	// id in KeyIdHeader.
+	Secret          []byte
+	KeyIdHeader     string
+	SignatureHeader string
+	// SignaturePrefix is stripped from the header value, e.g. "sha256=".
+	SignaturePrefix string
+	Encoding        string
+	// Components are joined with Separator to form the signed string.
+	Components      []string
+	Separator       string
+	TimestampHeader string
+	NonceHeader     string
+	Tolerance       time.Duration
+	MaxBody         int64
+}
+
+var hmacAlgorithms = map[string]func() hash.Hash{
+	"hmac-sha1":   sha1.New,
+	"hmac-sha256": sha256.New,
+	"hmac-sha512": sha512.New,
+}
+
+// seenSignatures remembers nonces, or signatures when there is no nonce,
+// until their timestamps fall out of the tolerance window.
+var seenSignatures = &nonceCache{seen: map[string]time.Time{}}
+
+type nonceCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+// add records key until expires, reporting false if it is already there.
+func (c *nonceCache) add(key string, expires, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastSweep) > time.Minute {
+		for k, t := range c.seen {
+			if now.After(t) {
+				delete(c.seen, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	if t, ok := c.seen[key]; ok && now.Before(t) {
+		return false
+	}
+	c.seen[key] = expires
+	return true
+}
+
+func (f HmacSignatureFilter) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := f.Settings
+		reject := func(reason string) {
+			RecordRejection("HmacSignature", reason)
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		}
+
+		signature, ok := s.signature(r)
+		if !ok {
+			reject("signature")
+			return
+		}
+		now := time.Now()
+		timestamp := ""
+		if s.TimestampHeader != "" {
+			timestamp = r.Header.Get(s.TimestampHeader)
+			seconds, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil || now.Sub(time.Unix(seconds, 0)).Abs() > s.Tolerance {
+				reject("timestamp")
+				return
+			}
+		}
+		nonce := ""
+		if s.NonceHeader != "" {
+			if nonce = r.Header.Get(s.NonceHeader); nonce == "" {
+				reject("nonce")
+				return
+			}
+		}
+
+		var body []byte
+		if s.signsBody() && r.Body != nil && r.Body != http.NoBody {
+			raw, fits, err := bufferRequestBody(r, s.MaxBody)
+			if err != nil {
+				http.Error(w, "Error reading request body", readErrorStatus(err))
+				return
+			}
+			if !fits {
+				http.Error(w, "Payload Too Large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			body = raw
+			r.Body = io.NopCloser(bytes.NewReader(raw))
+		}
+
+		message := s.message(r, timestamp, nonce, body)
+		keyID := ""
+		secrets := []HmacSecret{{Key: s.Secret}}
+		if s.Secret == nil {
+			keyID = r.Header.Get(s.KeyIdHeader)
+			secrets = HmacSecrets(keyID)
+		}
+		var matched *HmacSecret
+		for i := range secrets {
+			mac := hmac.New(s.Algorithm, secrets[i].Key)
+			mac.Write(message)
+			if hmac.Equal(mac.Sum(nil), signature) {
+				matched = &secrets[i]
+				break
+			}
+		}
+		if matched == nil {
+			reject("signature")
+			return
+		}
+
+		// A request is only new within the window its timestamp allows.
+		replayKey := nonce
+		if replayKey == "" {
+			replayKey = string(signature)
+		}
+		if !seenSignatures.add(keyID+"|"+replayKey, now.Add(s.Tolerance), now) {
+			reject("replay")
+			return
+		}
+
+		if matched.Consumer != nil {
+			TouchCredential(matched.Consumer.CredentialID)
+			r = r.WithContext(WithConsumer(r.Context(), matched.Consumer))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// signature decodes the signature header.
+func (s HmacSignatureSettings) signature(r *http.Request) ([]byte, bool) {
+	value := r.Header.Get(s.SignatureHeader)
+	if value == "" || !strings.HasPrefix(value, s.SignaturePrefix) {
+		return nil, false
+	}
+	value = strings.TrimPrefix(value, s.SignaturePrefix)
+	var decoded []byte
+	var err error
+	if s.Encoding == "base64" {
+		decoded, err = base64.StdEncoding.DecodeString(value)
+	} else {
+		decoded, err = hex.DecodeString(value)
+	}
+	return decoded, err == nil
+}
+
+func (s HmacSignatureSettings) signsBody() bool {
+	for _, c := range s.Components {
+		if c == "body" || c == "body_digest" {
+			return true
+		}
+	}
+	return false
+}
+
+// message builds the signed string from the components.
+func (s HmacSignatureSettings) message(r *http.Request, timestamp, nonce string, body []byte) []byte {
+	parts := make([][]byte, 0, len(s.Components))
+	for _, c := range s.Components {
+		var part string
+		switch {
+		case c == "method":
+			part = r.Method
+		case c == "path":
+			part = r.URL.EscapedPath()
+		case c == "query":
+			part = r.URL.RawQuery
+		case c == "timestamp":
+			part = timestamp
+		case c == "nonce":
+			part = nonce
+		case c == "body":
+			parts = append(parts, body)
+			continue
+		case c == "body_digest":
+			sum := sha256.Sum256(body)
+			part = hex.EncodeToString(sum[:])
+		case strings.HasPrefix(c, "header:"):
+			part = r.Header.Get(strings.TrimPrefix(c, "header:"))
+		}
+		parts = append(parts, []byte(part))
+	}
+	return bytes.Join(parts, []byte(s.Separator))
+}
+
+func (f *HmacSignatureFilter) Convert(filter GenericFilter) {
+	f.Name = filter.Name
+	settings := HmacSignatureSettings{
+		Algorithm:       sha256.New,
+		KeyIdHeader:     "X-Key-Id",
+		SignatureHeader: "X-Signature",
+		Encoding:        "hex",
+		Components:      []string{"method", "path", "query", "timestamp", "nonce", "body_digest"},
+		Separator:       "\n",
+		TimestampHeader: "X-Timestamp",
+		NonceHeader:     "X-Nonce",
+		Tolerance:       5 * time.Minute,
+		MaxBody:         1 << 20,
+	}
+	if name, ok := filter.Settings["algorithm"].(string); ok {
+		if algorithm, ok := hmacAlgorithms[strings.ToLower(name)]; ok {
+			settings.Algorithm = algorithm
+		} else {
+			log.Println("algorithm must be hmac-sha1, hmac-sha256 or hmac-sha512 for HmacSignatureFilter")
+		}
+	}
+	if secret, ok := filter.Settings["secret"].(string); ok && secret != "" {
+		settings.Secret = []byte(secret)
+	}
+	if name, ok := filter.Settings["secret_env"].(string); ok {
+		if secret := os.Getenv(name); secret != "" {
+			settings.Secret = []byte(secret)
+		} else {
+			log.Printf("secret_env %s is not set for HmacSignatureFilter", name)
+		}
+	}
+	for key, target := range map[string]*string{
+		"key_id_header":    &settings.KeyIdHeader,
+		"signature_header": &settings.SignatureHeader,
+		"signature_prefix": &settings.SignaturePrefix,
+		"separator":        &settings.Separator,
+		// An empty header name turns the timestamp or nonce off.
+		"timestamp_header": &settings.TimestampHeader,
+		"nonce_header":     &settings.NonceHeader,
+	} {
+		if value, ok := filter.Settings[key].(string); ok {
+			*target = value
+		}
+	}
+	if encoding, ok := filter.Settings["encoding"].(string); ok {
+		if encoding == "hex" || encoding == "base64" {
+			settings.Encoding = encoding
+		} else {
+			log.Println("encoding must be hex or base64 for HmacSignatureFilter")
+		}
+	}
+	if components, ok := filter.Settings["components"].([]interface{}); ok {
+		settings.Components = nil
+		for _, c := range components {
+			name, _ := c.(string)
+			switch {
+			case name == "method", name == "path", name == "query", name == "timestamp", name == "nonce",
+				name == "body", name == "body_digest", strings.HasPrefix(name, "header:"):
+				settings.Components = append(settings.Components, name)
+			default:
+				log.Printf("unknown component %q for HmacSignatureFilter", name)
+			}
+		}
+	}
+	if seconds, ok := intSetting(filter.Settings, "tolerance_seconds"); ok && seconds > 0 {
+		settings.Tolerance = time.Duration(seconds) * time.Second
+	}
+	if maxBody, ok := intSetting(filter.Settings, "max_body"); ok && maxBody > 0 {
+		settings.MaxBody = int64(maxBody)
+	}
+	f.Settings = settings
+}
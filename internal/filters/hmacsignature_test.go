@@ -0,0 +1,92 @@
+package filters
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(key, message string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHmacSignature_ConsumerKeysAndReplay(t *testing.T) {
+	defer func(lookup func(string) []HmacSecret) { HmacSecrets = lookup }(HmacSecrets)
+	HmacSecrets = func(keyID string) []HmacSecret {
+		if keyID != "partner-1" {
+			return nil
+		}
+		return []HmacSecret{
+			{Key: []byte("old-key"), Consumer: &Consumer{ID: "c1", CredentialID: "old"}},
+			{Key: []byte("new-key"), Consumer: &Consumer{ID: "c1", CredentialID: "new"}},
+		}
+	}
+
+	var filter HmacSignatureFilter
+	filter.Convert(GenericFilter{Name: "HmacSignature", Settings: map[string]interface{}{}})
+	var consumer *Consumer
+	handler := filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		consumer, _ = ConsumerFromContext(r.Context())
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"amount":5}` {
+			t.Errorf("Expected the body to reach the upstream, got %q", body)
+		}
+	}))
+
+	send := func(timestamp time.Time, nonce, key string) int {
+		ts := strconv.FormatInt(timestamp.Unix(), 10)
+		digest := sha256.Sum256([]byte(`{"amount":5}`))
+		message := strings.Join([]string{"POST", "/payments", "a=1", ts, nonce, hex.EncodeToString(digest[:])}, "\n")
+		req := httptest.NewRequest("POST", "/payments?a=1", strings.NewReader(`{"amount":5}`))
+		req.Header.Set("X-Key-Id", "partner-1")
+		req.Header.Set("X-Timestamp", ts)
+		req.Header.Set("X-Nonce", nonce)
+		req.Header.Set("X-Signature", sign(key, message))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := send(time.Now(), "n-1", "new-key"); code != http.StatusOK || consumer == nil || consumer.CredentialID != "new" {
+		t.Errorf("Expected a valid signature to pass as its consumer, got %d %+v", code, consumer)
+	}
+	if code := send(time.Now(), "n-1", "new-key"); code != http.StatusUnauthorized {
+		t.Errorf("Expected a replayed nonce to be rejected, got %d", code)
+	}
+	if code := send(time.Now().Add(-10*time.Minute), "n-2", "new-key"); code != http.StatusUnauthorized {
+		t.Errorf("Expected a stale timestamp to be rejected, got %d", code)
+	}
+	if code := send(time.Now(), "n-3", "wrong-key"); code != http.StatusUnauthorized {
+		t.Errorf("Expected a bad signature to be rejected, got %d", code)
+	}
+}
+
+func TestHmacSignature_WebhookStyle(t *testing.T) {
+	var filter HmacSignatureFilter
+	filter.Convert(GenericFilter{Name: "HmacSignature", Settings: map[string]interface{}{
+		"secret":           "webhook-secret",
+		"signature_header": "X-Hub-Signature-256",
+		"signature_prefix": "sha256=",
+		"components":       []interface{}{"body"},
+		"timestamp_header": "",
+		"nonce_header":     "",
+	}})
+	handler := filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("POST", "/hooks", strings.NewReader(`{"action":"opened"}`))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+sign("webhook-secret", `{"action":"opened"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the webhook to be accepted, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,198 @@
+package filters
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"zentro/internal/clientip"
+	"zentro/internal/signing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// InternalJwtFilter passes the caller's identity upstream as a short-lived
+// JWT signed with the gateway key, which upstreams verify against the JWKS
+// published on the admin server.
+type InternalJwtFilter struct {
+	Name     string
+	Settings InternalJwtSettings
+}
+
+type InternalJwtSettings struct {
+	Header string
+	// Scheme prefixes the token in the header, e.g. "Bearer".
+	Scheme   string
+	Issuer   string
+	Audience string
+	Ttl      time.Duration
+	// Claims maps claim names to the request values they are taken from.
+	Claims       map[string]string
+	StaticClaims map[string]interface{}
+	// RequireConsumer rejects requests no credential was matched for.
+	RequireConsumer bool
+}
+
+// defaultInternalClaims are minted unless the claims setting drops them.
+var defaultInternalClaims = map[string]string{
+	"sub":                "consumer.id",
+	"preferred_username": "consumer.username",
+	"groups":             "consumer.groups",
+	"route":              "route.name",
+}
+
+func (f InternalJwtFilter) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := f.Settings
+		// A caller must never be able to pass its own identity token along.
+		r.Header.Del(s.Header)
+
+		consumer, ok := ConsumerFromContext(r.Context())
+		if !ok && s.RequireConsumer {
+			RecordRejection("InternalJwt", "consumer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		key, ok := signing.Current()
+		if !ok {
+			log.Println("InternalJwtFilter: no signing key is loaded")
+			http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		now := time.Now()
+		claims := jwt.MapClaims{
+			"iat": now.Unix(),
+			"exp": now.Add(s.Ttl).Unix(),
+			"jti": newTokenID(),
+		}
+		if s.Issuer != "" {
+			claims["iss"] = s.Issuer
+		}
+		if s.Audience != "" {
+			claims["aud"] = s.Audience
+		}
+		for name, source := range s.Claims {
+			if value, ok := claimValue(source, r, consumer); ok {
+				claims[name] = value
+			}
+		}
+		for name, value := range s.StaticClaims {
+			claims[name] = value
+		}
+
+		token := jwt.NewWithClaims(key.Method, claims)
+		token.Header["kid"] = key.ID
+		signed, err := token.SignedString(key.Signer)
+		if err != nil {
+			log.Printf("InternalJwtFilter: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if s.Scheme != "" {
+			signed = s.Scheme + " " + signed
+		}
+		r.Header.Set(s.Header, signed)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// claimValue resolves a claim source against the request. Sources that have
+// no value for this request leave the claim out.
+func claimValue(source string, r *http.Request, consumer *Consumer) (interface{}, bool) {
+	switch {
+	case strings.HasPrefix(source, "consumer."):
+		if consumer == nil {
+			return nil, false
+		}
+		switch source {
+		case "consumer.id":
+			return consumer.ID, consumer.ID != ""
+		case "consumer.username":
+			return consumer.Username, consumer.Username != ""
+		case "consumer.groups":
+			return consumer.Groups, len(consumer.Groups) > 0
+		case "consumer.credential_id":
+			return consumer.CredentialID, consumer.CredentialID != ""
+		}
+	case source == "route.name":
+		name := RouteNameFromContext(r.Context())
+		return name, name != ""
+	case source == "client.ip":
+		return clientip.String(r), true
+	case strings.HasPrefix(source, "token."):
+		value, ok := TokenClaimsFromContext(r.Context())[strings.TrimPrefix(source, "token.")]
+		return value, ok
+	case strings.HasPrefix(source, "header."):
+		value := r.Header.Get(strings.TrimPrefix(source, "header."))
+		return value, value != ""
+	}
+	return nil, false
+}
+
+func validClaimSource(source string) bool {
+	switch source {
+	case "consumer.id", "consumer.username", "consumer.groups", "consumer.credential_id", "route.name", "client.ip":
+		return true
+	}
+	return strings.HasPrefix(source, "token.") || strings.HasPrefix(source, "header.")
+}
+
+func newTokenID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (f *InternalJwtFilter) Convert(filter GenericFilter) {
+	f.Name = filter.Name
+	settings := InternalJwtSettings{
+		Header: "X-Zentro-Identity",
+		Issuer: "zentro",
+		Ttl:    time.Minute,
+		Claims: map[string]string{},
+	}
+	for name, source := range defaultInternalClaims {
+		settings.Claims[name] = source
+	}
+	for key, target := range map[string]*string{
+		"header":   &settings.Header,
+		"scheme":   &settings.Scheme,
+		"issuer":   &settings.Issuer,
+		"audience": &settings.Audience,
+	} {
+		if value, ok := filter.Settings[key].(string); ok {
+			*target = value
+		}
+	}
+	if settings.Header == "" {
+		settings.Header = "X-Zentro-Identity"
+	}
+	if seconds, ok := intSetting(filter.Settings, "ttl_seconds"); ok {
+		if seconds > 0 && seconds <= 3600 {
+			settings.Ttl = time.Duration(seconds) * time.Second
+		} else {
+			log.Println("ttl_seconds must be between 1 and 3600 for InternalJwtFilter")
+		}
+	}
+	if claims, ok := filter.Settings["claims"].(map[string]interface{}); ok {
+		for name, value := range claims {
+			// An empty or null source drops a default claim.
+			source, _ := value.(string)
+			if source == "" {
+				delete(settings.Claims, name)
+				continue
+			}
+			if !validClaimSource(source) {
+				log.Printf("unknown claim source %q for InternalJwtFilter", source)
+				continue
+			}
+			settings.Claims[name] = source
+		}
+	}
+	settings.StaticClaims, _ = filter.Settings["static_claims"].(map[string]interface{})
+	settings.RequireConsumer, _ = filter.Settings["require_consumer"].(bool)
+	f.Settings = settings
+}
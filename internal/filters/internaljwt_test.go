@@ -0,0 +1,65 @@
+package filters
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"zentro/internal/signing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestInternalJwt_MintsSignedIdentity(t *testing.T) {
+	if err := signing.Load(filepath.Join(t.TempDir(), "key.pem")); err != nil {
+		t.Fatal(err)
+	}
+	key, _ := signing.Current()
+
+	var filter InternalJwtFilter
+	filter.Convert(GenericFilter{Name: "InternalJwt", Settings: map[string]interface{}{
+		"audience":      "orders",
+		"claims":        map[string]interface{}{"tenant": "token.tenant", "preferred_username": nil},
+		"static_claims": map[string]interface{}{"env": "test"},
+	}})
+	var token string
+	handler := filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("X-Zentro-Identity")
+	}))
+
+	req := httptest.NewRequest("GET", "/orders", nil)
+	req.Header.Set("X-Zentro-Identity", "forged")
+	ctx := WithConsumer(req.Context(), &Consumer{ID: "c1", Username: "alice", Groups: []string{"admins"}})
+	ctx = WithTokenClaims(WithRouteName(ctx, "orders-api"), map[string]interface{}{"tenant": "acme"})
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return key.Signer.Public(), nil
+	}, jwt.WithAudience("orders"), jwt.WithIssuer("zentro"))
+	if err != nil {
+		t.Fatalf("Expected a valid token, got %v (%q)", err, token)
+	}
+	if parsed.Header["kid"] != key.ID {
+		t.Errorf("Expected kid %s, got %v", key.ID, parsed.Header["kid"])
+	}
+	if claims["sub"] != "c1" || claims["route"] != "orders-api" || claims["tenant"] != "acme" || claims["env"] != "test" {
+		t.Errorf("Unexpected claims %v", claims)
+	}
+	if _, ok := claims["preferred_username"]; ok {
+		t.Errorf("Expected preferred_username to be dropped, got %v", claims)
+	}
+}
+
+func TestInternalJwt_RequireConsumer(t *testing.T) {
+	var filter InternalJwtFilter
+	filter.Convert(GenericFilter{Name: "InternalJwt", Settings: map[string]interface{}{"require_consumer": true}})
+	handler := filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected an anonymous request to be rejected")
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", rec.Code)
+	}
+}
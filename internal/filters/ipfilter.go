@@ -0,0 +1,60 @@
+package filters
+
+import (
+	"log"
+	"net/http"
+	"net/netip"
+	"zentro/internal/clientip"
+)
+
+// IpFilter allows or denies requests by client IP.
+type IpFilter struct {
+	Name     string
+	Settings IpFilterSettings
+}
+
+type IpFilterSettings struct {
+	Allow clientip.Ranges
+	Deny  clientip.Ranges
+}
+
+// Allows reports whether addr may pass. Deny wins over Allow, and a
+// non-empty Allow list admits only the addresses in it.
+func (s IpFilterSettings) Allows(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return len(s.Allow) == 0 && len(s.Deny) == 0
+	}
+	if s.Deny.Contains(addr) {
+		return false
+	}
+	return len(s.Allow) == 0 || s.Allow.Contains(addr)
+}
+
+func (f IpFilter) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.Settings.Allows(clientip.FromRequest(r)) {
+			RecordRejection("IpFilter", "route")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (f *IpFilter) Convert(filter GenericFilter) {
+	f.Name = filter.Name
+	settings := IpFilterSettings{}
+	var err error
+	if settings.Allow, err = clientip.ParseRanges(stringList(filter.Settings["allow"])); err != nil {
+		// Fail closed: a typo must not open the route to everyone.
+		log.Printf("allow must list CIDRs or IPs for IpFilter, denying all: %v", err)
+		settings.Deny = clientip.MustParseRanges([]string{"0.0.0.0/0", "::/0"})
+	}
+	deny, err := clientip.ParseRanges(stringList(filter.Settings["deny"]))
+	if err != nil {
+		log.Printf("deny must list CIDRs or IPs for IpFilter, denying all: %v", err)
+		deny = clientip.MustParseRanges([]string{"0.0.0.0/0", "::/0"})
+	}
+	settings.Deny = append(settings.Deny, deny...)
+	f.Settings = settings
+}
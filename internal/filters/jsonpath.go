@@ -0,0 +1,256 @@
+package filters
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a parsed path in the JSONPath subset used by the JSON
+// filters: $, .name, ['name'], [index] and the [*] / .* wildcards.
+type jsonPath []pathSegment
+
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func parseJSONPath(p string) (jsonPath, error) {
+	p = strings.TrimSpace(p)
+	if p == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+	if !strings.HasPrefix(p, "$") {
+		p = "$." + p
+	}
+	var path jsonPath
+	rest := p[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			if name == "" {
+				return nil, fmt.Errorf("empty name in %q", p)
+			}
+			if name == "*" {
+				path = append(path, pathSegment{wildcard: true})
+			} else {
+				path = append(path, pathSegment{key: name})
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed bracket in %q", p)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				path = append(path, pathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				path = append(path, pathSegment{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %q in %q", inner, p)
+				}
+				path = append(path, pathSegment{index: index, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %q in %q", rest[0], p)
+		}
+	}
+	return path, nil
+}
+
+func (p jsonPath) String() string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, seg := range p {
+		switch {
+		case seg.wildcard:
+			b.WriteString("[*]")
+		case seg.isIndex:
+			fmt.Fprintf(&b, "[%d]", seg.index)
+		default:
+			fmt.Fprintf(&b, "[%q]", seg.key)
+		}
+	}
+	return b.String()
+}
+
+// hasWildcard reports whether the path can match more than one value.
+func (p jsonPath) hasWildcard() bool {
+	for _, seg := range p {
+		if seg.wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// arrayIndex resolves negative indexes from the end of the array.
+func arrayIndex(arr []interface{}, index int) (int, bool) {
+	if index < 0 {
+		index += len(arr)
+	}
+	return index, index >= 0 && index < len(arr)
+}
+
+// get returns every value the path matches in node.
+func (p jsonPath) get(node interface{}) []interface{} {
+	if len(p) == 0 {
+		return []interface{}{node}
+	}
+	seg, rest := p[0], p[1:]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if seg.wildcard {
+			var out []interface{}
+			for _, v := range n {
+				out = append(out, rest.get(v)...)
+			}
+			return out
+		}
+		if v, ok := n[seg.key]; ok && !seg.isIndex {
+			return rest.get(v)
+		}
+	case []interface{}:
+		if seg.wildcard {
+			var out []interface{}
+			for _, v := range n {
+				out = append(out, rest.get(v)...)
+			}
+			return out
+		}
+		if i, ok := arrayIndex(n, seg.index); ok && seg.isIndex {
+			return rest.get(n[i])
+		}
+	}
+	return nil
+}
+
+// set stores value at the path, creating missing objects on the way, and
+// returns the updated node. Wildcards set the value on every match.
+func (p jsonPath) set(node interface{}, value interface{}) (interface{}, error) {
+	if len(p) == 0 {
+		return value, nil
+	}
+	seg, rest := p[0], p[1:]
+	if seg.wildcard {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			for k, v := range n {
+				updated, err := rest.set(v, cloneJSON(value))
+				if err != nil {
+					return node, err
+				}
+				n[k] = updated
+			}
+		case []interface{}:
+			for i, v := range n {
+				updated, err := rest.set(v, cloneJSON(value))
+				if err != nil {
+					return node, err
+				}
+				n[i] = updated
+			}
+		}
+		return node, nil
+	}
+	if seg.isIndex {
+		arr, ok := node.([]interface{})
+		if !ok {
+			return node, fmt.Errorf("cannot index a non-array with [%d]", seg.index)
+		}
+		i, ok := arrayIndex(arr, seg.index)
+		if !ok {
+			return node, fmt.Errorf("index %d out of range", seg.index)
+		}
+		updated, err := rest.set(arr[i], value)
+		arr[i] = updated
+		return arr, err
+	}
+	obj, ok := node.(map[string]interface{})
+	if node == nil {
+		obj, ok = map[string]interface{}{}, true
+	}
+	if !ok {
+		return node, fmt.Errorf("cannot set %q on a non-object", seg.key)
+	}
+	updated, err := rest.set(obj[seg.key], value)
+	obj[seg.key] = updated
+	return obj, err
+}
+
+// remove deletes every value the path matches and returns the updated node
+// with the number of values removed.
+func (p jsonPath) remove(node interface{}) (interface{}, int) {
+	if len(p) == 0 {
+		return nil, 0
+	}
+	seg, rest := p[0], p[1:]
+	removed := 0
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			if !seg.wildcard && (seg.isIndex || k != seg.key) {
+				continue
+			}
+			if len(rest) == 0 {
+				delete(n, k)
+				removed++
+				continue
+			}
+			updated, count := rest.remove(v)
+			n[k] = updated
+			removed += count
+		}
+	case []interface{}:
+		kept := n[:0]
+		for i, v := range n {
+			if j, ok := arrayIndex(n, seg.index); !seg.wildcard && (!seg.isIndex || !ok || i != j) {
+				kept = append(kept, v)
+				continue
+			}
+			if len(rest) == 0 {
+				removed++
+				continue
+			}
+			updated, count := rest.remove(v)
+			kept = append(kept, updated)
+			removed += count
+		}
+		return kept, removed
+	}
+	return node, removed
+}
+
+// cloneJSON deep-copies a decoded JSON value so that values taken from
+// settings or from elsewhere in a document are never shared.
+func cloneJSON(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			out[k] = cloneJSON(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			out[i] = cloneJSON(val)
+		}
+		return out
+	default:
+		return v
+	}
+}
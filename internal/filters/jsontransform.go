@@ -0,0 +1,423 @@
+package filters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// JsonTransformFilter restructures JSON request and/or response bodies with
+// a list of declarative operations.
+type JsonTransformFilter struct {
+	Name     string
+	Settings JsonTransformSettings
+}
+
+type JsonTransformSettings struct {
+	Operations []JsonOperation
+	// ApplyTo is "request", "response" or "both".
+	ApplyTo string
+	// PathPattern such as "/users/{id}" names path segments for templates.
+	PathPattern string
+	MaxBuffer   int64
+	// OnError is "reject" to answer with the error status or "pass" to
+	// forward the body unchanged.
+	OnError             string
+	RequestErrorStatus  int
+	ResponseErrorStatus int
+}
+
+// JsonOperation is one step of a transform. Set and template values may
+// contain {{...}} placeholders.
+type JsonOperation struct {
+	Op       string
+	Path     jsonPath
+	From     jsonPath
+	To       string
+	Value    interface{}
+	Required bool
+}
+
+var jsonContentTypes = []string{"application/json", "application/*+json"}
+
+func (f JsonTransformFilter) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(f.Settings.Operations) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := templateContext{request: r, params: matchPathPattern(f.Settings.PathPattern, r.URL.Path)}
+
+		if f.appliesTo("request") && r.Body != nil && r.Body != http.NoBody &&
+			bodyEligible(r.Header, r.ContentLength, jsonContentTypes, f.Settings.MaxBuffer) {
+			raw, fits, err := bufferRequestBody(r, f.Settings.MaxBuffer)
+			if err != nil {
+				log.Printf("Error reading request body: %v", err)
+				http.Error(w, "Error reading request body", readErrorStatus(err))
+				return
+			}
+			if fits {
+				body, err := f.transform(raw, r.Header.Get("Content-Encoding"), ctx)
+				switch {
+				case err == nil:
+					setRequestBody(r, body)
+				case f.Settings.OnError == "pass":
+					log.Printf("JSON transform of request failed, forwarding it unchanged: %v", err)
+					r.Body = io.NopCloser(bytes.NewReader(raw))
+				default:
+					http.Error(w, "Invalid request body: "+err.Error(), f.Settings.RequestErrorStatus)
+					return
+				}
+			}
+		}
+
+		if !f.appliesTo("response") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rw := &rewriteWriter{
+			ResponseWriter: w,
+			maxBuffer:      f.Settings.MaxBuffer,
+			head:           r.Method == http.MethodHead,
+			accepts: func(h http.Header, contentLength int64) bool {
+				return bodyEligible(h, contentLength, jsonContentTypes, f.Settings.MaxBuffer)
+			},
+			rewrite: func(h http.Header, status int, raw []byte) (int, []byte, bool) {
+				body, err := f.transform(raw, h.Get("Content-Encoding"), ctx)
+				if err == nil {
+					return status, body, true
+				}
+				log.Printf("JSON transform of response from %s failed: %v", r.URL.Path, err)
+				if f.Settings.OnError == "pass" {
+					return status, nil, false
+				}
+				h.Set("Content-Type", "text/plain; charset=utf-8")
+				h.Set("X-Content-Type-Options", "nosniff")
+				return f.Settings.ResponseErrorStatus, []byte("Invalid upstream response\n"), true
+			},
+		}
+		defer rw.finish()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func (f JsonTransformFilter) appliesTo(side string) bool {
+	return f.Settings.ApplyTo == side || f.Settings.ApplyTo == "both"
+}
+
+// transform decodes a body, runs the operations and encodes the result.
+func (f JsonTransformFilter) transform(raw []byte, encoding string, ctx templateContext) ([]byte, error) {
+	decoded, ok := decodeBody(raw, encoding, f.Settings.MaxBuffer)
+	if !ok {
+		return nil, fmt.Errorf("cannot decode %s body", encoding)
+	}
+	// Numbers stay json.Number so large IDs survive the round trip.
+	decoder := json.NewDecoder(bytes.NewReader(decoded))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("malformed JSON: %v", err)
+	}
+	ctx.body = doc
+	for _, op := range f.Settings.Operations {
+		var err error
+		if doc, err = op.apply(doc, &ctx); err != nil {
+			return nil, err
+		}
+		ctx.body = doc
+	}
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+func (op JsonOperation) apply(doc interface{}, ctx *templateContext) (interface{}, error) {
+	switch op.Op {
+	case "set":
+		value, err := ctx.expand(op.Value)
+		if err != nil {
+			return doc, err
+		}
+		return op.Path.set(doc, value)
+	case "template":
+		return ctx.expand(op.Value)
+	case "remove":
+		updated, removed := op.Path.remove(doc)
+		if removed == 0 && op.Required {
+			return doc, fmt.Errorf("%s not found", op.Path)
+		}
+		return updated, nil
+	case "rename":
+		parent, key := op.Path[:len(op.Path)-1], op.Path[len(op.Path)-1].key
+		renamed := 0
+		for _, node := range parent.get(doc) {
+			if obj, ok := node.(map[string]interface{}); ok {
+				if v, ok := obj[key]; ok {
+					delete(obj, key)
+					obj[op.To] = v
+					renamed++
+				}
+			}
+		}
+		if renamed == 0 && op.Required {
+			return doc, fmt.Errorf("%s not found", op.Path)
+		}
+		return doc, nil
+	case "move", "copy":
+		values := op.From.get(doc)
+		if len(values) == 0 {
+			if op.Required {
+				return doc, fmt.Errorf("%s not found", op.From)
+			}
+			return doc, nil
+		}
+		var value interface{} = cloneJSON(values[0])
+		if op.From.hasWildcard() {
+			value = cloneJSON(values)
+		}
+		if op.Op == "move" {
+			doc, _ = op.From.remove(doc)
+		}
+		return op.Path.set(doc, value)
+	}
+	return doc, nil
+}
+
+// templateContext resolves {{...}} placeholders against the request and
+// the document being transformed.
+type templateContext struct {
+	request *http.Request
+	params  map[string]string
+	body    interface{}
+}
+
+var placeholder = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// expand returns a copy of v with placeholders replaced. A string that is a
+// single placeholder takes the referenced value with its JSON type; other
+// strings have the values interpolated as text.
+func (c *templateContext) expand(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		if m := placeholder.FindStringSubmatch(t); m != nil && m[0] == t {
+			return c.resolve(m[1])
+		}
+		var err error
+		out := placeholder.ReplaceAllStringFunc(t, func(match string) string {
+			value, resolveErr := c.resolve(placeholder.FindStringSubmatch(match)[1])
+			if resolveErr != nil {
+				err = resolveErr
+				return ""
+			}
+			if s, ok := value.(string); ok {
+				return s
+			}
+			if value == nil {
+				return ""
+			}
+			data, _ := json.Marshal(value)
+			return string(data)
+		})
+		return out, err
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			expanded, err := c.expand(val)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = expanded
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			expanded, err := c.expand(val)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = expanded
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+func (c *templateContext) resolve(expr string) (interface{}, error) {
+	if strings.HasPrefix(expr, "$") {
+		path, err := parseJSONPath(expr)
+		if err != nil {
+			return nil, err
+		}
+		values := path.get(c.body)
+		switch {
+		case path.hasWildcard():
+			return cloneJSON(values), nil
+		case len(values) == 0:
+			return nil, nil
+		default:
+			return cloneJSON(values[0]), nil
+		}
+	}
+
+	source, name, _ := strings.Cut(expr, ".")
+	switch source {
+	case "header":
+		return c.request.Header.Get(name), nil
+	case "query":
+		return c.request.URL.Query().Get(name), nil
+	case "param":
+		return c.params[name], nil
+	case "request":
+		switch name {
+		case "method":
+			return c.request.Method, nil
+		case "path":
+			return c.request.URL.Path, nil
+		case "host":
+			return c.request.Host, nil
+		}
+	case "consumer":
+		consumer, ok := ConsumerFromContext(c.request.Context())
+		if !ok {
+			return nil, nil
+		}
+		switch name {
+		case "id":
+			return consumer.ID, nil
+		case "username":
+			return consumer.Username, nil
+		case "groups":
+			groups := make([]interface{}, len(consumer.Groups))
+			for i, g := range consumer.Groups {
+				groups[i] = g
+			}
+			return groups, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown template value %q", expr)
+}
+
+// matchPathPattern extracts the {name} segments of pattern from path.
+func matchPathPattern(pattern, path string) map[string]string {
+	if pattern == "" {
+		return nil
+	}
+	patternSegs := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(pathSegs) < len(patternSegs) {
+		return nil
+	}
+	params := map[string]string{}
+	for i, seg := range patternSegs {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			params[seg[1:len(seg)-1]] = pathSegs[i]
+		} else if seg != pathSegs[i] {
+			return nil
+		}
+	}
+	return params
+}
+
+func (f *JsonTransformFilter) Convert(filter GenericFilter) {
+	f.Name = filter.Name
+	settings := JsonTransformSettings{
+		ApplyTo:             "request",
+		MaxBuffer:           defaultBodyTransformBuffer,
+		OnError:             "reject",
+		RequestErrorStatus:  http.StatusBadRequest,
+		ResponseErrorStatus: http.StatusBadGateway,
+	}
+
+	if applyTo, ok := filter.Settings["apply_to"].(string); ok {
+		switch applyTo {
+		case "request", "response", "both":
+			settings.ApplyTo = applyTo
+		default:
+			log.Printf("apply_to must be request, response or both for JsonTransformFilter, defaulting to request.")
+		}
+	}
+	settings.PathPattern, _ = filter.Settings["path_pattern"].(string)
+	if size, ok := intSetting(filter.Settings, "max_buffer"); ok && size > 0 {
+		settings.MaxBuffer = int64(size)
+	}
+	if onError, ok := filter.Settings["on_error"].(string); ok && (onError == "reject" || onError == "pass") {
+		settings.OnError = onError
+	}
+	if status, ok := intSetting(filter.Settings, "request_error_status"); ok && status >= 400 && status < 600 {
+		settings.RequestErrorStatus = status
+	}
+	if status, ok := intSetting(filter.Settings, "response_error_status"); ok && status >= 400 && status < 600 {
+		settings.ResponseErrorStatus = status
+	}
+
+	operations, _ := filter.Settings["operations"].([]interface{})
+	for i, raw := range operations {
+		op, err := parseJsonOperation(raw)
+		if err != nil {
+			log.Printf("Ignoring operation %d of JsonTransformFilter: %v", i, err)
+			continue
+		}
+		settings.Operations = append(settings.Operations, op)
+	}
+
+	f.Settings = settings
+}
+
+func parseJsonOperation(raw interface{}) (JsonOperation, error) {
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return JsonOperation{}, fmt.Errorf("operation must be an object")
+	}
+	op := JsonOperation{Value: m["value"]}
+	op.Op, _ = m["op"].(string)
+	op.To, _ = m["to"].(string)
+	op.Required, _ = m["required"].(bool)
+
+	var err error
+	pathSetting, _ := m["path"].(string)
+	if op.Op != "template" {
+		if op.Path, err = parseJSONPath(pathSetting); err != nil {
+			return op, fmt.Errorf("path: %v", err)
+		}
+	}
+	switch op.Op {
+	case "set":
+		if _, ok := m["value"]; !ok {
+			return op, fmt.Errorf("set needs a value")
+		}
+	case "template":
+		if op.Value == nil {
+			return op, fmt.Errorf("template needs a value")
+		}
+	case "remove":
+		if len(op.Path) == 0 {
+			return op, fmt.Errorf("cannot remove the whole document")
+		}
+	case "rename":
+		if len(op.Path) == 0 || op.Path[len(op.Path)-1].isIndex || op.Path[len(op.Path)-1].wildcard {
+			return op, fmt.Errorf("rename needs a path ending in a field name")
+		}
+		if op.To == "" {
+			return op, fmt.Errorf("rename needs a new name in to")
+		}
+	case "move", "copy":
+		from, _ := m["from"].(string)
+		if op.From, err = parseJSONPath(from); err != nil {
+			return op, fmt.Errorf("from: %v", err)
+		}
+	default:
+		return op, fmt.Errorf("unknown op %q", op.Op)
+	}
+	return op, nil
+}
@@ -0,0 +1,122 @@
+package filters
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newJsonTransform(settings map[string]interface{}) *JsonTransformFilter {
+	var parsed map[string]interface{}
+	data, _ := json.Marshal(settings)
+	json.Unmarshal(data, &parsed)
+	filter := &JsonTransformFilter{}
+	filter.Convert(GenericFilter{Name: "JsonTransform", Settings: parsed})
+	return filter
+}
+
+func TestJsonPath_SetGetRemove(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"items":[{"id":1,"secret":"a"},{"id":2,"secret":"b"}]}`), &doc)
+
+	path, err := parseJSONPath("$.items[*].secret")
+	if err != nil {
+		t.Fatalf("Expected path to parse: %v", err)
+	}
+	if got := path.get(doc); len(got) != 2 {
+		t.Errorf("Expected 2 matches, got %v", got)
+	}
+	doc, removed := path.remove(doc)
+	if removed != 2 {
+		t.Errorf("Expected 2 removals, got %d", removed)
+	}
+
+	last, _ := parseJSONPath("items[-1]['label']")
+	doc, err = last.set(doc, "last")
+	if err != nil {
+		t.Fatalf("Expected set to succeed: %v", err)
+	}
+	data, _ := json.Marshal(doc)
+	if string(data) != `{"items":[{"id":1},{"id":2,"label":"last"}]}` {
+		t.Errorf("Unexpected document %s", data)
+	}
+}
+
+func TestJsonTransform_Request(t *testing.T) {
+	filter := newJsonTransform(map[string]interface{}{
+		"path_pattern": "/users/{id}",
+		"operations": []interface{}{
+			map[string]interface{}{"op": "rename", "path": "$.user_name", "to": "username"},
+			map[string]interface{}{"op": "remove", "path": "$.internal"},
+			map[string]interface{}{"op": "move", "from": "$.profile.email", "path": "$.contact.email"},
+			map[string]interface{}{"op": "set", "path": "$.meta", "value": map[string]interface{}{
+				"user":   "{{param.id}}",
+				"tenant": "{{header.X-Tenant}}",
+				"label":  "user {{$.username}}",
+			}},
+		},
+	})
+
+	var received map[string]interface{}
+	handler := filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &received)
+		if !strings.Contains(string(data), `"id":12345678901234567890`) {
+			t.Errorf("Expected large numbers to be kept exactly, got %s", data)
+		}
+		if r.ContentLength != int64(len(data)) {
+			t.Errorf("Expected Content-Length %d, got %d", len(data), r.ContentLength)
+		}
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/users/42", strings.NewReader(
+		`{"user_name":"ada","internal":true,"profile":{"email":"ada@example.com"},"id":12345678901234567890}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant", "acme")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	want := map[string]interface{}{
+		"username": "ada",
+		"profile":  map[string]interface{}{},
+		"contact":  map[string]interface{}{"email": "ada@example.com"},
+		"id":       1.2345678901234567e+19,
+		"meta":     map[string]interface{}{"user": "42", "tenant": "acme", "label": "user ada"},
+	}
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("Expected %v, got %v", want, received)
+	}
+
+	rec := httptest.NewRecorder()
+	bad := httptest.NewRequest(http.MethodPost, "/users/42", strings.NewReader(`{"user_name":`))
+	bad.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(rec, bad)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for malformed JSON, got %d", rec.Code)
+	}
+}
+
+func TestJsonTransform_ResponseTemplate(t *testing.T) {
+	filter := newJsonTransform(map[string]interface{}{
+		"apply_to": "response",
+		"operations": []interface{}{
+			map[string]interface{}{"op": "template", "value": map[string]interface{}{
+				"data":   "{{$.result}}",
+				"ids":    "{{$.result[*].id}}",
+				"method": "{{request.method}}",
+			}},
+		},
+	})
+	handler := filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"result":[{"id":1},{"id":2}],"debug":"x"}`)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Body.String() != `{"data":[{"id":1},{"id":2}],"ids":[1,2],"method":"GET"}` {
+		t.Errorf("Unexpected response %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,209 @@
+package filters
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MockFilter answers requests with configured responses instead of calling
+// the upstream, so clients can be built before the backend exists.
+type MockFilter struct {
+	Name     string
+	Settings MockSettings
+}
+
+type MockSettings struct {
+	// Enabled turns mock mode on; a disabled filter forwards every request.
+	Enabled   bool
+	Responses []MockResponse
+}
+
+// MockResponse is returned for requests matching its path, methods and
+// headers. Requests no response matches are forwarded to the upstream.
+type MockResponse struct {
+	// Path uses the path_pattern syntax of JsonTransform and must match
+	// the whole request path; an empty path matches any request.
+	Path    string
+	Methods []string
+	// MatchHeaders must all be present with these values; "*" accepts any
+	// value.
+	MatchHeaders map[string]string
+	Status       int
+	Headers      map[string]string
+	// Body is sent as is when it is a string and as JSON otherwise.
+	Body     interface{}
+	Template bool
+	Latency  time.Duration
+}
+
+func (f MockFilter) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.Settings.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, mock := range f.Settings.Responses {
+			if params, ok := mock.matches(r); ok {
+				mock.serve(w, r, params)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m MockResponse) matches(r *http.Request) (map[string]string, bool) {
+	if len(m.Methods) > 0 && !containsFold(m.Methods, r.Method) {
+		return nil, false
+	}
+	for name, value := range m.MatchHeaders {
+		got := r.Header.Get(name)
+		if got == "" || (value != "*" && got != value) {
+			return nil, false
+		}
+	}
+	if m.Path == "" {
+		return map[string]string{}, true
+	}
+	if strings.Count(strings.Trim(m.Path, "/"), "/") != strings.Count(strings.Trim(r.URL.Path, "/"), "/") {
+		return nil, false
+	}
+	params := matchPathPattern(m.Path, r.URL.Path)
+	return params, params != nil
+}
+
+func (m MockResponse) serve(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	if m.Latency > 0 {
+		timer := time.NewTimer(m.Latency)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return
+		}
+	}
+
+	headers := m.Headers
+	body := m.Body
+	if m.Template {
+		ctx := templateContext{request: r, params: params, body: mockRequestBody(r)}
+		expanded, err := ctx.expand(body)
+		if err != nil {
+			log.Printf("Error expanding mock response for %s: %v", r.URL.Path, err)
+			http.Error(w, "Invalid mock response template", http.StatusInternalServerError)
+			return
+		}
+		body = expanded
+		headers = make(map[string]string, len(m.Headers))
+		for name, value := range m.Headers {
+			expanded, _ := ctx.expand(value)
+			headers[name], _ = expanded.(string)
+		}
+	}
+
+	var data []byte
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case string:
+		data = []byte(b)
+		contentType = "text/plain; charset=utf-8"
+	default:
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(b); err != nil {
+			http.Error(w, "Invalid mock response body", http.StatusInternalServerError)
+			return
+		}
+		data = buf.Bytes()
+	}
+
+	h := w.Header()
+	if data != nil {
+		h.Set("Content-Type", contentType)
+		h.Set("Content-Length", strconv.Itoa(len(data)))
+	}
+	for name, value := range headers {
+		h.Set(name, value)
+	}
+	h.Set("X-Zentro-Mock", "true")
+	w.WriteHeader(m.Status)
+	if r.Method != http.MethodHead {
+		w.Write(data)
+	}
+}
+
+// mockRequestBody decodes a JSON request body so templates can echo it.
+func mockRequestBody(r *http.Request) interface{} {
+	if r.Body == nil || r.Body == http.NoBody ||
+		!bodyEligible(r.Header, r.ContentLength, jsonContentTypes, defaultBodyTransformBuffer) {
+		return nil
+	}
+	raw, err := io.ReadAll(io.LimitReader(r.Body, defaultBodyTransformBuffer))
+	if err != nil {
+		return nil
+	}
+	raw, ok := decodeBody(raw, r.Header.Get("Content-Encoding"), defaultBodyTransformBuffer)
+	if !ok {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc interface{}
+	if dec.Decode(&doc) != nil {
+		return nil
+	}
+	return doc
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *MockFilter) Convert(filter GenericFilter) {
+	f.Name = filter.Name
+	settings := MockSettings{Enabled: true}
+	if enabled, ok := filter.Settings["enabled"].(bool); ok {
+		settings.Enabled = enabled
+	}
+
+	responses, _ := filter.Settings["responses"].([]interface{})
+	if len(responses) == 0 {
+		log.Println("responses must be a non-empty list for MockFilter")
+	}
+	for _, raw := range responses {
+		entry, ok := raw.(map[string]interface{})
+		if !ok {
+			log.Println("responses entries must be objects for MockFilter")
+			continue
+		}
+		mock := MockResponse{Status: http.StatusOK, Body: entry["body"]}
+		if match, ok := entry["match"].(map[string]interface{}); ok {
+			mock.Path, _ = match["path"].(string)
+			mock.Methods = stringList(match["methods"])
+			mock.MatchHeaders = stringMap(match["headers"])
+		}
+		if status, ok := intSetting(entry, "status"); ok && status >= 100 && status <= 999 {
+			mock.Status = status
+		}
+		mock.Headers = stringMap(entry["headers"])
+		mock.Template, _ = entry["template"].(bool)
+		if latency, ok := intSetting(entry, "latency_ms"); ok && latency > 0 {
+			mock.Latency = time.Duration(latency) * time.Millisecond
+		}
+		settings.Responses = append(settings.Responses, mock)
+	}
+	f.Settings = settings
+}
@@ -0,0 +1,74 @@
+package filters
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newMock(settings string) *MockFilter {
+	var parsed map[string]interface{}
+	json.Unmarshal([]byte(settings), &parsed)
+	filter := &MockFilter{}
+	filter.Convert(GenericFilter{Name: "Mock", Settings: parsed})
+	return filter
+}
+
+func TestMock_SelectsResponse(t *testing.T) {
+	filter := newMock(`{"responses": [
+		{"match": {"path": "/pets/{id}", "methods": ["GET"], "headers": {"X-Scenario": "missing"}}, "status": 404, "body": {"error": "not found"}},
+		{"match": {"path": "/pets/{id}", "methods": ["GET"]}, "template": true,
+		 "headers": {"X-Pet": "{{param.id}}"}, "body": {"id": "{{param.id}}", "name": "{{query.name}}"}},
+		{"match": {"path": "/pets", "methods": ["POST"]}, "status": 201, "template": true, "body": {"created": "{{$.name}}"}}
+	]}`)
+	upstream := false
+	handler := filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream = true
+	}))
+
+	tests := []struct {
+		method, path, scenario, body string
+		status                       int
+		want                         string
+	}{
+		{"GET", "/pets/7?name=rex", "", "", 200, `{"id":"7","name":"rex"}`},
+		{"GET", "/pets/7", "missing", "", 404, `{"error":"not found"}`},
+		{"POST", "/pets", "", `{"name":"rex"}`, 201, `{"created":"rex"}`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if tt.scenario != "" {
+			req.Header.Set("X-Scenario", tt.scenario)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status || strings.TrimSpace(rec.Body.String()) != tt.want {
+			t.Errorf("%s %s: expected %d %s, got %d %s", tt.method, tt.path, tt.status, tt.want, rec.Code, rec.Body.String())
+		}
+		if rec.Header().Get("X-Zentro-Mock") != "true" {
+			t.Errorf("Expected mocked responses to be marked")
+		}
+	}
+
+	// A nested path is not matched by /pets/{id} and goes to the upstream.
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/pets/7/photos", nil))
+	if !upstream {
+		t.Errorf("Expected unmatched requests to be forwarded")
+	}
+}
+
+func TestMock_Disabled(t *testing.T) {
+	filter := newMock(`{"enabled": false, "responses": [{"status": 200, "body": "mocked"}]}`)
+	rec := httptest.NewRecorder()
+	filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("upstream"))
+	})).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Body.String() != "upstream" {
+		t.Errorf("Expected a disabled mock to forward, got %q", rec.Body.String())
+	}
+}
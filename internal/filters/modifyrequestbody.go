@@ -5,36 +5,36 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"strconv"
-	"strings"
 )
 
 type ModifyRequestBodyFilter struct {
 	Name     string
-	Settings ModifyRequestBodySettings
-}
-
-type ModifyRequestBodySettings struct {
-	From string
-	To   string
+	Settings BodyTransformSettings
 }
 
 func (f ModifyRequestBodyFilter) Apply(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		bodyBytes, err := io.ReadAll(r.Body)
+		if r.Body == nil || r.Body == http.NoBody || !f.Settings.accepts(r.Header, r.ContentLength) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		raw, fits, err := bufferRequestBody(r, f.Settings.MaxBuffer)
 		if err != nil {
 			log.Printf("Error reading request body: %v", err)
-			http.Error(w, "Error reading request body", http.StatusInternalServerError)
+			http.Error(w, "Error reading request body", readErrorStatus(err))
+			return
+		}
+		if !fits {
+			next.ServeHTTP(w, r)
 			return
 		}
-		defer r.Body.Close()
-
-		modifiedBody := strings.Replace(string(bodyBytes), f.Settings.From, f.Settings.To, -1)
 
-		newBody := []byte(modifiedBody)
-		r.Body = io.NopCloser(bytes.NewBuffer(newBody))
-		r.ContentLength = int64(len(newBody))
-		r.Header.Set("Content-Length", strconv.Itoa(len(newBody)))
+		if body, ok := f.Settings.transform(raw, r.Header.Get("Content-Encoding")); ok {
+			setRequestBody(r, body)
+		} else {
+			r.Body = io.NopCloser(bytes.NewReader(raw))
+		}
 
 		next.ServeHTTP(w, r)
 	})
@@ -42,18 +42,7 @@ func (f ModifyRequestBodyFilter) Apply(next http.Handler) http.Handler {
 
 func (f *ModifyRequestBodyFilter) Convert(filter GenericFilter) {
 	f.Name = filter.Name
-	settings := ModifyRequestBodySettings{}
-	if from, ok := filter.Settings["from"].(string); ok {
-		settings.From = from
-	} else {
-		log.Println("from must be a string for ModifyRequestBodyFilter")
-	}
-	if to, ok := filter.Settings["to"].(string); ok {
-		settings.To = to
-	} else {
-		log.Println("to must be a string for ModifyRequestBodyFilter")
-	}
-	f.Settings = settings
+	f.Settings = convertBodyTransform(filter, "ModifyRequestBodyFilter")
 }
 
 func (f ModifyRequestBodyFilter) IsResponseFilter() bool {
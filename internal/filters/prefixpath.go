@@ -18,6 +18,9 @@ func (f PrefixPathFilter) Apply(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		r.URL.Path = f.Settings.Prefix + r.URL.Path
 		log.Printf("Adding prefix %s to path. New path: %s", f.Settings.Prefix, r.URL.Path)
+		if changes := PathChangesFromContext(r.Context()); changes != nil {
+			changes.Added = f.Settings.Prefix + changes.Added
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -17,11 +17,9 @@ type PreserveHostHeaderSettings struct {
 
 func (f PreserveHostHeaderFilter) Apply(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		originalHost := r.Host // This is the host from the incoming request
-		log.Printf("Preserving Host header. Original Host: %s", originalHost)
-
-		r.Host = originalHost
-		next.ServeHTTP(w, r)
+		log.Printf("Preserving Host header. Original Host: %s", r.Host)
+		// The proxy rewrites Host to the upstream unless told otherwise.
+		next.ServeHTTP(w, r.WithContext(WithPreserveHost(r.Context())))
 	})
 }
 
@@ -0,0 +1,144 @@
+package filters
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuotaStore counts requests per key over calendar days and months (UTC).
+// Counters are periodically written to a JSON file so quotas survive restarts.
+type QuotaStore struct {
+	mu     sync.Mutex
+	path   string
+	counts map[string]int64
+	dirty  bool
+}
+
+// QuotaResult describes the tightest quota that applied to a request.
+type QuotaResult struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	Reset     time.Time
+}
+
+// Quotas is the process wide quota store. It is memory only until InitQuotas
+// is called with a state file.
+var Quotas = &QuotaStore{counts: make(map[string]int64)}
+
+// InitQuotas loads quota counters from path and flushes them back every
+// interval.
+func InitQuotas(path string, interval time.Duration) error {
+	store := &QuotaStore{path: path, counts: make(map[string]int64)}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &store.counts); err != nil {
+			return err
+		}
+	}
+	store.prune(time.Now().UTC())
+	Quotas = store
+
+	go func() {
+		for range time.Tick(interval) {
+			if err := store.Flush(); err != nil {
+				log.Println("❌ Quota flush failed:", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func dayPeriod(now time.Time) (string, time.Time) {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return "d:" + start.Format("2006-01-02"), start.AddDate(0, 0, 1)
+}
+
+func monthPeriod(now time.Time) (string, time.Time) {
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return "m:" + start.Format("2006-01"), start.AddDate(0, 1, 0)
+}
+
+// Consume counts one request for key against the daily and monthly limits.
+// A limit of zero disables that window. Nothing is counted when a quota is
+// already exhausted.
+func (q *QuotaStore) Consume(key string, daily, monthly int64, now time.Time) QuotaResult {
+	now = now.UTC()
+	type window struct {
+		counter string
+		limit   int64
+		reset   time.Time
+	}
+	var windows []window
+	if daily > 0 {
+		period, reset := dayPeriod(now)
+		windows = append(windows, window{period + "|" + key, daily, reset})
+	}
+	if monthly > 0 {
+		period, reset := monthPeriod(now)
+		windows = append(windows, window{period + "|" + key, monthly, reset})
+	}
+	if len(windows) == 0 {
+		return QuotaResult{Allowed: true}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	result := QuotaResult{Allowed: true, Remaining: -1}
+	for _, w := range windows {
+		used := q.counts[w.counter]
+		if used >= w.limit {
+			return QuotaResult{Allowed: false, Limit: w.limit, Remaining: 0, Reset: w.reset}
+		}
+		if remaining := w.limit - used - 1; result.Remaining < 0 || remaining < result.Remaining {
+			result = QuotaResult{Allowed: true, Limit: w.limit, Remaining: remaining, Reset: w.reset}
+		}
+	}
+	for _, w := range windows {
+		q.counts[w.counter]++
+	}
+	q.dirty = true
+	return result
+}
+
+// prune drops counters that belong to past days and months.
+func (q *QuotaStore) prune(now time.Time) {
+	day, _ := dayPeriod(now)
+	month, _ := monthPeriod(now)
+	for k := range q.counts {
+		if !strings.HasPrefix(k, day+"|") && !strings.HasPrefix(k, month+"|") {
+			delete(q.counts, k)
+		}
+	}
+}
+
+// Flush writes the counters to the state file if anything changed.
+func (q *QuotaStore) Flush() error {
+	q.mu.Lock()
+	if !q.dirty || q.path == "" {
+		q.mu.Unlock()
+		return nil
+	}
+	q.prune(time.Now().UTC())
+	data, err := json.Marshal(q.counts)
+	q.dirty = false
+	q.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.path)
+}
@@ -0,0 +1,58 @@
+package filters
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQuotaStore_DailyLimit(t *testing.T) {
+	q := &QuotaStore{counts: make(map[string]int64)}
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if res := q.Consume("consumer:a", 2, 0, now); !res.Allowed {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+	res := q.Consume("consumer:a", 2, 0, now)
+	if res.Allowed {
+		t.Error("Expected third request to exceed the daily quota")
+	}
+	if want := time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC); !res.Reset.Equal(want) {
+		t.Errorf("Expected reset at %v, got %v", want, res.Reset)
+	}
+
+	if !q.Consume("consumer:a", 2, 0, now.Add(24*time.Hour)).Allowed {
+		t.Error("Expected quota to reset on the next day")
+	}
+	if !q.Consume("consumer:b", 2, 0, now).Allowed {
+		t.Error("Expected quotas to be tracked per key")
+	}
+}
+
+func TestQuotaStore_ReportsTightestWindow(t *testing.T) {
+	q := &QuotaStore{counts: make(map[string]int64)}
+	res := q.Consume("consumer:a", 100, 5, time.Now())
+	if res.Limit != 5 || res.Remaining != 4 {
+		t.Errorf("Expected monthly window (limit 5, remaining 4), got limit %d remaining %d", res.Limit, res.Remaining)
+	}
+}
+
+func TestQuotaStore_PersistsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotas.json")
+	if err := InitQuotas(path, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	Quotas.Consume("consumer:a", 1, 0, time.Now())
+	if err := Quotas.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitQuotas(path, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if Quotas.Consume("consumer:a", 1, 0, time.Now()).Allowed {
+		t.Error("Expected quota usage to be restored from the state file")
+	}
+}
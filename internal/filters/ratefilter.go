@@ -1,76 +1,219 @@
 package filters
 
 import (
+	"errors"
+	"log"
 	"net/http"
-	"sync"
+	"strconv"
 	"time"
+	"zentro/internal/clientip"
+	"zentro/internal/ratelimit"
 )
 
-type ClientLimiter struct {
-	Requests  int
-	LastReset time.Time
-	Window    time.Duration
-}
+// RateLimitStore holds the state of every RateLimit filter. Keys are
+// prefixed with the filter's scope so routes never share allowances.
+var RateLimitStore ratelimit.Store = newDefaultRateLimitStore()
 
-var limiters = make(map[string]*ClientLimiter)
-var mu sync.Mutex
+func newDefaultRateLimitStore() ratelimit.Store {
+	store := ratelimit.NewMemoryStore(ratelimit.DefaultMaxKeys)
+	store.StartJanitor(time.Minute)
+	return store
+}
 
 type RateLimitFilter struct {
 	Name     string
 	Settings RateLimitFilterSettings
+	Scope    string
 }
 
 type RateLimitFilterSettings struct {
+	// Algorithm is one of fixed_window (default), token_bucket,
+	// sliding_log or sliding_window.
+	Algorithm   string
 	MaxRequests int
 	PerSeconds  int
+	// Burst is the token bucket capacity; it defaults to MaxRequests.
+	Burst int
+	// KeyBy selects what identifies a client: "consumer" (default, falling
+	// back to the client IP), "ip", "header" or "claim".
+	KeyBy        string
+	KeyHeader    string
+	KeyClaim     string
+	DailyQuota   int64
+	MonthlyQuota int64
 }
 
-func (r *RateLimitFilter) Apply(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		ip := req.RemoteAddr
-
-		mu.Lock()
-		limiter, exists := limiters[ip]
+// ConsumerLimits overrides a route's rate limit and quotas for one consumer.
+// Zero values keep the route setting.
+type ConsumerLimits struct {
+	MaxRequests  int   `json:"max_requests,omitempty"`
+	PerSeconds   int   `json:"per_second,omitempty"`
+	DailyQuota   int64 `json:"daily_quota,omitempty"`
+	MonthlyQuota int64 `json:"monthly_quota,omitempty"`
+}
 
-		window := time.Duration(r.Settings.PerSeconds) * time.Second
-		maxRequests := r.Settings.MaxRequests
+func (r *RateLimitFilter) SetScope(scope string) {
+	r.Scope = scope
+}
 
-		if !exists || time.Since(limiter.LastReset) > window {
-			limiter = &ClientLimiter{
-				Requests:  0,
-				LastReset: time.Now(),
-				Window:    window,
-			}
-			limiters[ip] = limiter
+func (r *RateLimitFilter) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := r.clientKey(req)
+		settings := r.effectiveSettings(req)
+
+		rule := ratelimit.Rule{
+			Algorithm: settings.Algorithm,
+			Limit:     settings.MaxRequests,
+			Window:    time.Duration(settings.PerSeconds) * time.Second,
+			Burst:     settings.Burst,
+		}
+		decision, err := RateLimitStore.Take(r.Scope+"|"+key, rule, time.Now())
+		if errors.Is(err, ratelimit.ErrUnavailable) {
+			w.Header().Set("Retry-After", retryAfter(decision.RetryAfter))
+			http.Error(w, "Rate limit store unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			log.Printf("Rate limit skipped for %s: %v", r.Scope, err)
+			next.ServeHTTP(w, req)
+			return
 		}
 
-		if limiter.Requests >= maxRequests {
-			mu.Unlock()
+		setRateLimitHeaders(w.Header(), decision.Limit, decision.Remaining, decision.Reset)
+		if !decision.Allowed {
+			w.Header().Set("Retry-After", retryAfter(decision.RetryAfter))
 			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
 
-		limiter.Requests++
-		mu.Unlock()
+		quota := Quotas.Consume(r.Scope+"|"+key, settings.DailyQuota, settings.MonthlyQuota, time.Now())
+		if quota.Limit > 0 {
+			w.Header().Set("X-Quota-Limit", strconv.FormatInt(quota.Limit, 10))
+			w.Header().Set("X-Quota-Remaining", strconv.FormatInt(quota.Remaining, 10))
+			w.Header().Set("X-Quota-Reset", strconv.FormatInt(quota.Reset.Unix(), 10))
+		}
+		if !quota.Allowed {
+			w.Header().Set("Retry-After", retryAfter(time.Until(quota.Reset)))
+			http.Error(w, "Quota exceeded", http.StatusTooManyRequests)
+			return
+		}
 
 		next.ServeHTTP(w, req)
 	})
 }
 
+func setRateLimitHeaders(h http.Header, limit, remaining int, reset time.Duration) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	h.Set("X-RateLimit-Reset", retryAfter(reset))
+}
+
+// retryAfter renders a duration as whole seconds, rounded up.
+func retryAfter(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
+
+// clientKey identifies the caller a limit is tracked for.
+func (r *RateLimitFilter) clientKey(req *http.Request) string {
+	switch r.Settings.KeyBy {
+	case "ip":
+		return "ip:" + clientip.String(req)
+	case "header":
+		if v := req.Header.Get(r.Settings.KeyHeader); v != "" {
+			return "header:" + v
+		}
+	case "claim":
+		if v := verifiedClaim(req, r.Settings.KeyClaim); v != "" {
+			return "claim:" + v
+		}
+	default:
+		if c, ok := ConsumerFromContext(req.Context()); ok {
+			return "consumer:" + c.ID
+		}
+	}
+	return "ip:" + clientip.String(req)
+}
+
+// effectiveSettings applies the authenticated consumer's overrides, if any.
+func (r *RateLimitFilter) effectiveSettings(req *http.Request) RateLimitFilterSettings {
+	settings := r.Settings
+	c, ok := ConsumerFromContext(req.Context())
+	if !ok || c.Limits == nil {
+		return settings
+	}
+	if c.Limits.MaxRequests > 0 {
+		settings.MaxRequests = c.Limits.MaxRequests
+	}
+	if c.Limits.PerSeconds > 0 {
+		settings.PerSeconds = c.Limits.PerSeconds
+	}
+	if c.Limits.DailyQuota > 0 {
+		settings.DailyQuota = c.Limits.DailyQuota
+	}
+	if c.Limits.MonthlyQuota > 0 {
+		settings.MonthlyQuota = c.Limits.MonthlyQuota
+	}
+	return settings
+}
+
+// verifiedClaim reads a claim of the token verified by the auth filter.
+// Unverified tokens are ignored, as a client could forge a fresh value for
+// every request.
+func verifiedClaim(req *http.Request, claim string) string {
+	if claim == "" {
+		return ""
+	}
+	switch v := TokenClaimsFromContext(req.Context())[claim].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
 func (r *RateLimitFilter) Convert(filter GenericFilter) {
+	r.Name = filter.Name
 	settings := RateLimitFilterSettings{}
 
-	if max, ok := filter.Settings["max_requests"].(int); ok {
+	if algorithm, ok := filter.Settings["algorithm"].(string); ok {
+		settings.Algorithm = algorithm
+	} else {
+		settings.Algorithm = ratelimit.FixedWindow
+	}
+
+	if max, ok := intSetting(filter.Settings, "max_requests"); ok {
 		settings.MaxRequests = max
 	} else {
 		settings.MaxRequests = 100
 	}
 
-	if seconds, ok := filter.Settings["per_second"].(int); ok {
+	if seconds, ok := intSetting(filter.Settings, "per_second"); ok {
 		settings.PerSeconds = seconds
 	} else {
 		settings.PerSeconds = 10
 	}
 
+	settings.Burst, _ = intSetting(filter.Settings, "burst")
+
+	if keyBy, ok := filter.Settings["key_by"].(string); ok {
+		settings.KeyBy = keyBy
+	} else {
+		settings.KeyBy = "consumer"
+	}
+	settings.KeyHeader, _ = filter.Settings["key_header"].(string)
+	settings.KeyClaim, _ = filter.Settings["key_claim"].(string)
+
+	if daily, ok := intSetting(filter.Settings, "daily_quota"); ok {
+		settings.DailyQuota = int64(daily)
+	}
+	if monthly, ok := intSetting(filter.Settings, "monthly_quota"); ok {
+		settings.MonthlyQuota = int64(monthly)
+	}
+
 	r.Settings = settings
 }
@@ -32,9 +32,10 @@ func (f RequestSizeFilter) Apply(next http.Handler) http.Handler {
 					http.Error(w, "Payload Too Large", http.StatusRequestEntityTooLarge)
 					return
 				}
-			} else {
-				log.Printf("Content-Length header missing, skipping request body size check for filter '%s'.", f.Name)
 			}
+			// Chunked bodies have no Content-Length, so the limit is also
+			// enforced as the body streams; the proxy then answers 413.
+			LimitBody(w, r, int64(f.Settings.MaxSize))
 		}
 		next.ServeHTTP(w, r)
 	})
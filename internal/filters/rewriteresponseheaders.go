@@ -0,0 +1,277 @@
+package filters
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RewriteResponseHeadersFilter maps upstream addresses in Location,
+// Content-Location and Set-Cookie back to the public host and path, and
+// hardens cookie attributes.
+type RewriteResponseHeadersFilter struct {
+	Name     string
+	Settings RewriteResponseHeadersSettings
+}
+
+type RewriteResponseHeadersSettings struct {
+	// Auto reverses the upstream URL, StripPrefix and PrefixPath.
+	Auto bool
+	// PublicURL is the scheme and host clients use. It defaults to the
+	// request's own.
+	PublicURL *url.URL
+	// LocationRules replace URL prefixes in Location and Content-Location
+	// before the automatic mapping.
+	LocationRules []LocationRule
+	// CookieDomains and CookiePaths map cookie attributes exactly (domains)
+	// or by prefix (paths).
+	CookieDomains map[string]string
+	CookiePaths   map[string]string
+	// Secure, HttpOnly and SameSite are set on every cookie when enabled.
+	Secure   bool
+	HttpOnly bool
+	SameSite string
+}
+
+type LocationRule struct {
+	From string
+	To   string
+}
+
+func (f RewriteResponseHeadersFilter) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		public := f.Settings.PublicURL
+		if public == nil {
+			public = &url.URL{Scheme: "http", Host: r.Host}
+			if r.TLS != nil {
+				public.Scheme = "https"
+			}
+		}
+		m := &headerMapper{settings: f.Settings, public: public, r: r}
+		next.ServeHTTP(&headerRewriter{ResponseWriter: w, rewrite: m.rewrite}, r)
+	})
+}
+
+// headerMapper rewrites the headers of one response.
+type headerMapper struct {
+	settings RewriteResponseHeadersSettings
+	public   *url.URL
+	r        *http.Request
+}
+
+// upstream returns the upstream URL and the path prefixes to reverse. The
+// path changes are read late, once every filter of the route has run.
+func (m *headerMapper) upstream() (*url.URL, string, string) {
+	upstream, _ := url.Parse(UpstreamFromContext(m.r.Context()))
+	if upstream == nil {
+		upstream = &url.URL{}
+	}
+	added := strings.TrimSuffix(upstream.Path, "/")
+	stripped := ""
+	if changes := PathChangesFromContext(m.r.Context()); changes != nil {
+		added += changes.Added
+		stripped = changes.Stripped
+	}
+	return upstream, strings.TrimSuffix(stripped, "/"), added
+}
+
+// publicPath maps an upstream path to the path the client would use.
+func (m *headerMapper) publicPath(p string) string {
+	_, stripped, added := m.upstream()
+	if added != "" {
+		if p != added && !strings.HasPrefix(p, strings.TrimSuffix(added, "/")+"/") {
+			return p
+		}
+		p = strings.TrimPrefix(p, strings.TrimSuffix(added, "/"))
+		if p == "" && stripped != "" {
+			return stripped
+		}
+	}
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return stripped + p
+}
+
+func (m *headerMapper) location(value string) string {
+	for _, rule := range m.settings.LocationRules {
+		if strings.HasPrefix(value, rule.From) {
+			return rule.To + strings.TrimPrefix(value, rule.From)
+		}
+	}
+	if !m.settings.Auto {
+		return value
+	}
+	loc, err := url.Parse(value)
+	if err != nil {
+		return value
+	}
+	upstream, _, _ := m.upstream()
+	switch {
+	case loc.Host == "" && strings.HasPrefix(loc.Path, "/"):
+		// A path relative to the upstream host.
+	case loc.Host != "" && strings.EqualFold(loc.Host, upstream.Host):
+		loc.Scheme, loc.Host = m.public.Scheme, m.public.Host
+	default:
+		return value
+	}
+	loc.Path = m.publicPath(loc.Path)
+	loc.RawPath = ""
+	return loc.String()
+}
+
+func (m *headerMapper) cookie(value string) string {
+	parts := strings.Split(value, ";")
+	upstream, _, _ := m.upstream()
+	upstreamHost := upstream.Hostname()
+
+	attrs := parts[:1]
+	seen := map[string]bool{}
+	for _, part := range parts[1:] {
+		name, attr, _ := strings.Cut(strings.TrimSpace(part), "=")
+		key := strings.ToLower(name)
+		seen[key] = true
+		switch key {
+		case "domain":
+			domain := strings.TrimPrefix(attr, ".")
+			if to, ok := m.settings.CookieDomains[domain]; ok {
+				attr = to
+			} else if m.settings.Auto && strings.EqualFold(domain, upstreamHost) {
+				// A host-only cookie belongs to the public host.
+				continue
+			}
+		case "path":
+			attr = m.cookiePath(attr)
+		case "samesite":
+			if m.settings.SameSite != "" {
+				attr = m.settings.SameSite
+			}
+		}
+		if attr == "" && !strings.Contains(part, "=") {
+			attrs = append(attrs, name)
+		} else {
+			attrs = append(attrs, name+"="+attr)
+		}
+	}
+	// Browsers drop SameSite=None cookies that are not Secure.
+	if (m.settings.Secure || m.settings.SameSite == "None") && !seen["secure"] {
+		attrs = append(attrs, "Secure")
+	}
+	if m.settings.HttpOnly && !seen["httponly"] {
+		attrs = append(attrs, "HttpOnly")
+	}
+	if m.settings.SameSite != "" && !seen["samesite"] {
+		attrs = append(attrs, "SameSite="+m.settings.SameSite)
+	}
+	return strings.Join(attrs, "; ")
+}
+
+func (m *headerMapper) cookiePath(p string) string {
+	longest := ""
+	for from := range m.settings.CookiePaths {
+		if strings.HasPrefix(p, from) && len(from) > len(longest) {
+			longest = from
+		}
+	}
+	if longest != "" {
+		return m.settings.CookiePaths[longest] + strings.TrimPrefix(p, longest)
+	}
+	if m.settings.Auto {
+		return m.publicPath(p)
+	}
+	return p
+}
+
+func (m *headerMapper) rewrite(h http.Header) {
+	for _, name := range []string{"Location", "Content-Location"} {
+		if value := h.Get(name); value != "" {
+			h.Set(name, m.location(value))
+		}
+	}
+	cookies := h.Values("Set-Cookie")
+	for i, value := range cookies {
+		cookies[i] = m.cookie(value)
+	}
+}
+
+// headerRewriter calls rewrite on the response headers just before they
+// are sent.
+type headerRewriter struct {
+	http.ResponseWriter
+	rewrite     func(http.Header)
+	wroteHeader bool
+}
+
+func (h *headerRewriter) WriteHeader(code int) {
+	// Informational responses share the header map, so it is rewritten
+	// once, for the final response.
+	if code >= 200 && !h.wroteHeader {
+		h.rewrite(h.ResponseWriter.Header())
+		h.wroteHeader = true
+	}
+	h.ResponseWriter.WriteHeader(code)
+}
+
+func (h *headerRewriter) Write(p []byte) (int, error) {
+	if !h.wroteHeader {
+		h.WriteHeader(http.StatusOK)
+	}
+	return h.ResponseWriter.Write(p)
+}
+
+func (h *headerRewriter) Flush() {
+	if !h.wroteHeader {
+		h.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(h.ResponseWriter).Flush()
+}
+
+func (h *headerRewriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(h.ResponseWriter).Hijack()
+}
+
+func (h *headerRewriter) Unwrap() http.ResponseWriter {
+	return h.ResponseWriter
+}
+
+func (f *RewriteResponseHeadersFilter) Convert(filter GenericFilter) {
+	f.Name = filter.Name
+	settings := RewriteResponseHeadersSettings{Auto: true}
+	if auto, ok := filter.Settings["auto"].(bool); ok {
+		settings.Auto = auto
+	}
+	if raw, ok := filter.Settings["public_url"].(string); ok {
+		if public, err := url.Parse(raw); err == nil && public.Scheme != "" && public.Host != "" {
+			settings.PublicURL = &url.URL{Scheme: public.Scheme, Host: public.Host}
+		} else {
+			log.Println("public_url must be an absolute URL for RewriteResponseHeadersFilter")
+		}
+	}
+	rules, _ := filter.Settings["location_rules"].([]interface{})
+	for _, raw := range rules {
+		rule, _ := raw.(map[string]interface{})
+		from, _ := rule["from"].(string)
+		to, _ := rule["to"].(string)
+		if from == "" {
+			log.Println("location_rules entries need a from for RewriteResponseHeadersFilter")
+			continue
+		}
+		settings.LocationRules = append(settings.LocationRules, LocationRule{From: from, To: to})
+	}
+	settings.CookieDomains = stringMap(filter.Settings["cookie_domains"])
+	settings.CookiePaths = stringMap(filter.Settings["cookie_paths"])
+	settings.Secure, _ = filter.Settings["cookie_secure"].(bool)
+	settings.HttpOnly, _ = filter.Settings["cookie_http_only"].(bool)
+	if sameSite, ok := filter.Settings["cookie_same_site"].(string); ok {
+		switch strings.ToLower(sameSite) {
+		case "strict", "lax", "none":
+			settings.SameSite = strings.ToUpper(sameSite[:1]) + strings.ToLower(sameSite[1:])
+		default:
+			log.Println("cookie_same_site must be Strict, Lax or None for RewriteResponseHeadersFilter")
+		}
+	}
+	f.Settings = settings
+}
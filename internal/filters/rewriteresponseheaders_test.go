@@ -0,0 +1,55 @@
+package filters
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRewriteResponseHeaders_ReversesPathChanges(t *testing.T) {
+	var filter RewriteResponseHeadersFilter
+	filter.Convert(GenericFilter{Name: "RewriteResponseHeaders", Settings: map[string]interface{}{
+		"cookie_secure":    true,
+		"cookie_same_site": "lax",
+		"location_rules":   []interface{}{map[string]interface{}{"from": "http://auth.internal/", "to": "https://login.example.com/"}},
+	}})
+	strip := StripPrefixFilter{Settings: StripPrefixSettings{Prefix: "/billing"}}
+
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/invoices" {
+			t.Errorf("Expected the stripped path, got %s", r.URL.Path)
+		}
+		h := w.Header()
+		h.Set("Location", "http://billing.internal:8080/v1/invoices/7?x=1")
+		h.Set("Content-Location", "/v1/invoices/7")
+		h.Add("Set-Cookie", "session=abc; Domain=billing.internal; Path=/v1; HttpOnly")
+		h.Add("Set-Cookie", "theme=dark; Path=/other")
+		w.WriteHeader(http.StatusFound)
+	})
+	handler := filter.Apply(strip.Apply(upstream))
+
+	req := httptest.NewRequest("GET", "https://api.example.com/billing/invoices", nil)
+	ctx := WithPathChanges(WithUpstream(req.Context(), "http://billing.internal:8080/v1"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req.WithContext(ctx))
+
+	h := rec.Header()
+	if got := h.Get("Location"); got != "https://api.example.com/billing/invoices/7?x=1" {
+		t.Errorf("Unexpected Location %s", got)
+	}
+	if got := h.Get("Content-Location"); got != "/billing/invoices/7" {
+		t.Errorf("Unexpected Content-Location %s", got)
+	}
+	cookies := h.Values("Set-Cookie")
+	if cookies[0] != "session=abc; Path=/billing; HttpOnly; Secure; SameSite=Lax" {
+		t.Errorf("Unexpected cookie %s", cookies[0])
+	}
+	if cookies[1] != "theme=dark; Path=/other; Secure; SameSite=Lax" {
+		t.Errorf("Expected paths outside the upstream to stay, got %s", cookies[1])
+	}
+
+	m := &headerMapper{settings: filter.Settings, r: req}
+	if got := m.location("http://auth.internal/login"); got != "https://login.example.com/login" {
+		t.Errorf("Expected the explicit rule to apply, got %s", got)
+	}
+}
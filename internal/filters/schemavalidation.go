@@ -0,0 +1,302 @@
+package filters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// SchemaValidationFilter rejects requests whose body, query parameters or
+// headers do not match the JSON Schemas configured for their method.
+type SchemaValidationFilter struct {
+	Name     string
+	Settings SchemaValidationSettings
+}
+
+// SchemaValidationSettings maps HTTP methods, or "*" for any method, to
+// compiled schemas.
+type SchemaValidationSettings struct {
+	Body      map[string]*jsonschema.Schema
+	Query     map[string]*jsonschema.Schema
+	Headers   map[string]*jsonschema.Schema
+	MaxBuffer int64
+	// Invalid is the first schema that failed to compile. Requests are then
+	// rejected rather than passed on unvalidated.
+	Invalid error
+}
+
+// Violation is one reason a request failed validation.
+type Violation struct {
+	// In is "body", "query" or "header".
+	In      string `json:"in"`
+	Path    string `json:"path"`
+	Keyword string `json:"keyword,omitempty"`
+	Message string `json:"message"`
+}
+
+var (
+	schemaMu sync.Mutex
+	// schemas holds the compiled schemas of the loaded routes, keyed by
+	// schemaKey. CompileRouteFilters replaces it on every routes load.
+	schemas = map[string]*jsonschema.Schema{}
+)
+
+// CompileSchemas compiles every schema referenced by SchemaValidation
+// filters, failing on the first invalid one, and makes them the set used by
+// requests.
+func CompileSchemas(filters []GenericFilter) error {
+	compiled, err := compileFilterSchemas(filters)
+	if err != nil {
+		return err
+	}
+	schemaMu.Lock()
+	schemas = compiled
+	schemaMu.Unlock()
+	return nil
+}
+
+// CheckSchemas reports the first invalid schema without changing the set
+// in use.
+func CheckSchemas(filters []GenericFilter) error {
+	_, err := compileFilterSchemas(filters)
+	return err
+}
+
+func compileFilterSchemas(filters []GenericFilter) (map[string]*jsonschema.Schema, error) {
+	compiled := map[string]*jsonschema.Schema{}
+	for _, filter := range filters {
+		if FilterTypeFromName(filter.Name) != SchemaValidationFilterType {
+			continue
+		}
+		for _, section := range []string{"body", "query", "headers"} {
+			refs, _ := filter.Settings[section].(map[string]interface{})
+			for method, ref := range refs {
+				key, err := schemaKey(ref)
+				if err == nil {
+					_, err = compiledSchema(key, ref, compiled)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("%s schema for %s: %w", section, method, err)
+				}
+			}
+		}
+	}
+	return compiled, nil
+}
+
+// schemaKey identifies a schema reference: a file path, or an inline schema
+// by its content.
+func schemaKey(ref interface{}) (string, error) {
+	switch v := ref.(type) {
+	case string:
+		return "file:" + v, nil
+	case map[string]interface{}, bool:
+		data, err := json.Marshal(v)
+		return "inline:" + string(data), err
+	default:
+		return "", fmt.Errorf("schema must be a file path or an object")
+	}
+}
+
+// compiledSchema returns the schema for key from cache, compiling it there
+// if it is missing.
+func compiledSchema(key string, ref interface{}, cache map[string]*jsonschema.Schema) (*jsonschema.Schema, error) {
+	if schema, ok := cache[key]; ok {
+		return schema, nil
+	}
+	compiler := jsonschema.NewCompiler()
+	url := "inline.json"
+	if path, ok := ref.(string); ok {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		url = abs
+	} else if err := compiler.AddResource(url, strings.NewReader(strings.TrimPrefix(key, "inline:"))); err != nil {
+		return nil, err
+	}
+	schema, err := compiler.Compile(url)
+	if err != nil {
+		return nil, err
+	}
+	cache[key] = schema
+	return schema, nil
+}
+
+// lookupSchema finds a schema compiled at load time. Schemas added since,
+// for example through the management API, are compiled on first use.
+func lookupSchema(ref interface{}) (*jsonschema.Schema, error) {
+	key, err := schemaKey(ref)
+	if err != nil {
+		return nil, err
+	}
+	schemaMu.Lock()
+	defer schemaMu.Unlock()
+	return compiledSchema(key, ref, schemas)
+}
+
+func (f SchemaValidationFilter) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f.Settings.Invalid != nil {
+			log.Printf("SchemaValidationFilter %s: %v", f.Name, f.Settings.Invalid)
+			http.Error(w, "Invalid schema configuration", http.StatusInternalServerError)
+			return
+		}
+		var violations []Violation
+
+		if schema := forMethod(f.Settings.Query, r.Method); schema != nil {
+			query := map[string]interface{}{}
+			for name, values := range r.URL.Query() {
+				query[name] = stringsOrString(values)
+			}
+			violations = append(violations, validateAgainst(schema, query, "query")...)
+		}
+		if schema := forMethod(f.Settings.Headers, r.Method); schema != nil {
+			headers := map[string]interface{}{}
+			for name, values := range r.Header {
+				headers[strings.ToLower(name)] = stringsOrString(values)
+			}
+			violations = append(violations, validateAgainst(schema, headers, "header")...)
+		}
+
+		if schema := forMethod(f.Settings.Body, r.Method); schema != nil {
+			doc, status, err := f.readBody(r)
+			if err != nil {
+				writeViolations(w, status, []Violation{{In: "body", Path: "", Message: err.Error()}})
+				return
+			}
+			violations = append(violations, validateAgainst(schema, doc, "body")...)
+		}
+
+		if len(violations) > 0 {
+			writeViolations(w, http.StatusBadRequest, violations)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readBody decodes the JSON body and leaves it readable for the upstream.
+// An empty body is validated as null.
+func (f SchemaValidationFilter) readBody(r *http.Request) (interface{}, int, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, 0, nil
+	}
+	raw, fits, err := bufferRequestBody(r, f.Settings.MaxBuffer)
+	if err != nil {
+		return nil, readErrorStatus(err), fmt.Errorf("could not read body: %v", err)
+	}
+	if !fits {
+		return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("body exceeds %d bytes", f.Settings.MaxBuffer)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, 0, nil
+	}
+	if !contentTypeAllowed(r.Header.Get("Content-Type"), jsonContentTypes) {
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("body must be JSON")
+	}
+	decoded, ok := decodeBody(raw, r.Header.Get("Content-Encoding"), f.Settings.MaxBuffer)
+	if !ok {
+		return nil, http.StatusBadRequest, fmt.Errorf("could not decode %s body", r.Header.Get("Content-Encoding"))
+	}
+	decoder := json.NewDecoder(bytes.NewReader(decoded))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("malformed JSON: %v", err)
+	}
+	return doc, 0, nil
+}
+
+func forMethod(schemas map[string]*jsonschema.Schema, method string) *jsonschema.Schema {
+	if schema, ok := schemas[method]; ok {
+		return schema
+	}
+	return schemas["*"]
+}
+
+func stringsOrString(values []string) interface{} {
+	if len(values) == 1 {
+		return values[0]
+	}
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
+
+// validateAgainst lists every violation, one per failing keyword.
+func validateAgainst(schema *jsonschema.Schema, doc interface{}, in string) []Violation {
+	err := schema.Validate(doc)
+	if err == nil {
+		return nil
+	}
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []Violation{{In: in, Message: err.Error()}}
+	}
+	var violations []Violation
+	var collect func(*jsonschema.ValidationError)
+	collect = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			violations = append(violations, Violation{In: in, Path: e.InstanceLocation, Keyword: e.KeywordLocation, Message: e.Message})
+		}
+		for _, cause := range e.Causes {
+			collect(cause)
+		}
+	}
+	collect(ve)
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Path < violations[j].Path })
+	return violations
+}
+
+func writeViolations(w http.ResponseWriter, status int, violations []Violation) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":      "Request validation failed",
+		"violations": violations,
+	})
+}
+
+func (f *SchemaValidationFilter) Convert(filter GenericFilter) {
+	f.Name = filter.Name
+	settings := SchemaValidationSettings{MaxBuffer: defaultBodyTransformBuffer}
+	if size, ok := intSetting(filter.Settings, "max_buffer"); ok && size > 0 {
+		settings.MaxBuffer = int64(size)
+	}
+	var errs [3]error
+	settings.Body, errs[0] = convertSchemas(filter.Settings, "body")
+	settings.Query, errs[1] = convertSchemas(filter.Settings, "query")
+	settings.Headers, errs[2] = convertSchemas(filter.Settings, "headers")
+	for _, err := range errs {
+		if err != nil && settings.Invalid == nil {
+			settings.Invalid = err
+		}
+	}
+	f.Settings = settings
+}
+
+func convertSchemas(settings map[string]interface{}, section string) (map[string]*jsonschema.Schema, error) {
+	refs, _ := settings[section].(map[string]interface{})
+	out := map[string]*jsonschema.Schema{}
+	for method, ref := range refs {
+		schema, err := lookupSchema(ref)
+		if err != nil {
+			return nil, fmt.Errorf("%s schema for %s: %w", section, method, err)
+		}
+		out[strings.ToUpper(method)] = schema
+	}
+	return out, nil
+}
@@ -0,0 +1,97 @@
+package filters
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSchemaValidation_ListsEveryViolation(t *testing.T) {
+	dir := t.TempDir()
+	schemaPath := filepath.Join(dir, "user.json")
+	os.WriteFile(schemaPath, []byte(`{
+		"type": "object",
+		"required": ["name", "email"],
+		"properties": {
+			"name": {"type": "string", "minLength": 2},
+			"age": {"type": "integer", "minimum": 0}
+		}
+	}`), 0644)
+
+	generic := GenericFilter{Name: "SchemaValidation", Settings: map[string]interface{}{
+		"body": map[string]interface{}{"POST": schemaPath},
+		"query": map[string]interface{}{"*": map[string]interface{}{
+			"properties": map[string]interface{}{"dry_run": map[string]interface{}{"enum": []interface{}{"true", "false"}}},
+		}},
+	}}
+	if err := CompileSchemas([]GenericFilter{generic}); err != nil {
+		t.Fatalf("Expected schemas to compile: %v", err)
+	}
+	filter := &SchemaValidationFilter{}
+	filter.Convert(generic)
+
+	var forwarded string
+	handler := filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		forwarded = string(data)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/users?dry_run=maybe", strings.NewReader(`{"name":"a","age":-1}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", rec.Code)
+	}
+	var resp struct {
+		Violations []Violation `json:"violations"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	paths := map[string]bool{}
+	for _, v := range resp.Violations {
+		paths[v.In+":"+v.Path] = true
+	}
+	for _, want := range []string{"body:", "body:/name", "body:/age", "query:/dry_run"} {
+		if !paths[want] {
+			t.Errorf("Expected a violation at %s, got %+v", want, resp.Violations)
+		}
+	}
+
+	valid := `{"name":"ada","email":"ada@example.com"}`
+	req = httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(valid))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || forwarded != valid {
+		t.Errorf("Expected valid request to be forwarded intact, got %d %q", rec.Code, forwarded)
+	}
+}
+
+func TestCompileSchemas_RejectsInvalidSchema(t *testing.T) {
+	err := CompileSchemas([]GenericFilter{{Name: "SchemaValidation", Settings: map[string]interface{}{
+		"body": map[string]interface{}{"POST": map[string]interface{}{"type": 12}},
+	}}})
+	if err == nil {
+		t.Errorf("Expected an invalid schema to fail the load")
+	}
+}
+
+func TestSchemaValidation_InvalidSchemaRejectsRequests(t *testing.T) {
+	var filter SchemaValidationFilter
+	filter.Convert(GenericFilter{Name: "SchemaValidation", Settings: map[string]interface{}{
+		"body": map[string]interface{}{"POST": map[string]interface{}{"type": 12}},
+	}})
+	handler := filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected a route with an invalid schema to fail closed with 500, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,207 @@
+package filters
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// CspNonceHeader carries the per-request CSP nonce to the upstream, which
+// puts it on its inline scripts and styles.
+const CspNonceHeader = "X-Zentro-Csp-Nonce"
+
+// SecurityHeadersFilter adds browser security headers to responses and
+// removes headers that reveal upstream software.
+type SecurityHeadersFilter struct {
+	Name     string
+	Settings SecurityHeadersSettings
+}
+
+type SecurityHeadersSettings struct {
+	Enabled bool
+	// Headers are set on every response, keyed by header name.
+	Headers map[string]string
+	// Remove lists upstream headers to drop.
+	Remove []string
+	// Override replaces headers the upstream set itself.
+	Override bool
+}
+
+// securityHeaderKeys maps setting keys to the headers they control.
+var securityHeaderKeys = map[string]string{
+	"hsts":                         "Strict-Transport-Security",
+	"content_security_policy":      "Content-Security-Policy",
+	"content_type_options":         "X-Content-Type-Options",
+	"frame_options":                "X-Frame-Options",
+	"referrer_policy":              "Referrer-Policy",
+	"permissions_policy":           "Permissions-Policy",
+	"cross_origin_opener_policy":   "Cross-Origin-Opener-Policy",
+	"cross_origin_embedder_policy": "Cross-Origin-Embedder-Policy",
+	"cross_origin_resource_policy": "Cross-Origin-Resource-Policy",
+}
+
+var securityHeaderPresets = map[string]map[string]string{
+	"none": {},
+	"basic": {
+		"hsts":                 "max-age=31536000",
+		"content_type_options": "nosniff",
+		"frame_options":        "SAMEORIGIN",
+		"referrer_policy":      "strict-origin-when-cross-origin",
+	},
+	"strict": {
+		"hsts":                         "max-age=63072000; includeSubDomains",
+		"content_security_policy":      "default-src 'self'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'",
+		"content_type_options":         "nosniff",
+		"frame_options":                "DENY",
+		"referrer_policy":              "no-referrer",
+		"permissions_policy":           "camera=(), microphone=(), geolocation=(), payment=()",
+		"cross_origin_opener_policy":   "same-origin",
+		"cross_origin_embedder_policy": "require-corp",
+		"cross_origin_resource_policy": "same-origin",
+	},
+	"api": {
+		"hsts":                    "max-age=31536000",
+		"content_security_policy": "default-src 'none'; frame-ancestors 'none'",
+		"content_type_options":    "nosniff",
+		"frame_options":           "DENY",
+		"referrer_policy":         "no-referrer",
+	},
+}
+
+// leakyHeaders are removed unless the remove setting says otherwise.
+var leakyHeaders = []string{"Server", "X-Powered-By", "X-AspNet-Version", "X-AspNetMvc-Version"}
+
+func (f SecurityHeadersFilter) Apply(next http.Handler) http.Handler {
+	if !f.Settings.Enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(f.Wrap(w, r), r)
+	})
+}
+
+// Wrap returns a writer that sets the headers when the response starts.
+// When the policy uses a nonce, a fresh one is put on r for the upstream.
+func (f SecurityHeadersFilter) Wrap(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	headers := f.Settings.Headers
+	if csp := cspHeader(headers); strings.Contains(headers[csp], "{nonce}") {
+		nonce := newNonce()
+		r.Header.Set(CspNonceHeader, nonce)
+		headers = make(map[string]string, len(f.Settings.Headers))
+		for name, value := range f.Settings.Headers {
+			headers[name] = value
+		}
+		headers[csp] = strings.ReplaceAll(headers[csp], "{nonce}", nonce)
+	}
+	return &headerRewriter{ResponseWriter: w, rewrite: func(h http.Header) {
+		for _, name := range f.Settings.Remove {
+			h.Del(name)
+		}
+		for name, value := range headers {
+			if f.Settings.Override || h.Get(name) == "" {
+				h.Set(name, value)
+			}
+		}
+	}}
+}
+
+func cspHeader(headers map[string]string) string {
+	if _, ok := headers["Content-Security-Policy-Report-Only"]; ok {
+		return "Content-Security-Policy-Report-Only"
+	}
+	return "Content-Security-Policy"
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func (f *SecurityHeadersFilter) Convert(filter GenericFilter) {
+	f.Name = filter.Name
+	settings, err := ParseSecurityHeaders(filter.Settings)
+	if err != nil {
+		log.Printf("%v for SecurityHeadersFilter", err)
+	}
+	f.Settings = settings
+}
+
+// ParseSecurityHeaders reads SecurityHeaders settings. The preset (basic by
+// default) is applied first; a header key set to a string replaces its
+// value and one set to false drops it. Invalid entries are skipped and
+// reported in the error.
+func ParseSecurityHeaders(raw map[string]interface{}) (SecurityHeadersSettings, error) {
+	settings := SecurityHeadersSettings{Enabled: true, Remove: leakyHeaders}
+	var problems []string
+	if enabled, ok := raw["enabled"].(bool); ok {
+		settings.Enabled = enabled
+	}
+	settings.Override, _ = raw["override"].(bool)
+
+	preset := "basic"
+	if name, ok := raw["preset"].(string); ok {
+		preset = name
+	}
+	values, ok := securityHeaderPresets[preset]
+	if !ok {
+		problems = append(problems, fmt.Sprintf("unknown preset %q", preset))
+		values = securityHeaderPresets["basic"]
+	}
+	settings.Headers = map[string]string{}
+	for key, value := range values {
+		settings.Headers[securityHeaderKeys[key]] = value
+	}
+
+	for key, value := range raw {
+		switch key {
+		case "enabled", "override", "preset", "csp_report_only":
+			continue
+		case "remove":
+			list, ok := value.([]interface{})
+			if !ok {
+				problems = append(problems, "remove must be a list of header names")
+				continue
+			}
+			settings.Remove = nil
+			for _, item := range list {
+				if name, ok := item.(string); ok {
+					settings.Remove = append(settings.Remove, name)
+				}
+			}
+			continue
+		}
+		header, ok := securityHeaderKeys[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown setting %q", key))
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			settings.Headers[header] = v
+		case bool:
+			if !v {
+				delete(settings.Headers, header)
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("%s must be a string or false", key))
+		default:
+			problems = append(problems, fmt.Sprintf("%s must be a string or false", key))
+		}
+	}
+
+	if reportOnly, _ := raw["csp_report_only"].(bool); reportOnly {
+		if csp, ok := settings.Headers["Content-Security-Policy"]; ok {
+			delete(settings.Headers, "Content-Security-Policy")
+			settings.Headers["Content-Security-Policy-Report-Only"] = csp
+		}
+	}
+
+	if len(problems) > 0 {
+		return settings, fmt.Errorf("security_headers: %s", strings.Join(problems, "; "))
+	}
+	return settings, nil
+}
@@ -0,0 +1,45 @@
+package filters
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSecurityHeaders_PresetNonceAndRemoval(t *testing.T) {
+	var filter SecurityHeadersFilter
+	filter.Convert(GenericFilter{Name: "SecurityHeaders", Settings: map[string]interface{}{
+		"preset":                       "strict",
+		"content_security_policy":      "script-src 'self' 'nonce-{nonce}'",
+		"cross_origin_embedder_policy": false,
+	}})
+
+	var nonce string
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = r.Header.Get(CspNonceHeader)
+		w.Header().Set("Server", "nginx/1.25")
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		w.Write([]byte("<html></html>"))
+	})
+	rec := httptest.NewRecorder()
+	filter.Apply(upstream).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	h := rec.Header()
+	if nonce == "" || h.Get("Content-Security-Policy") != "script-src 'self' 'nonce-"+nonce+"'" {
+		t.Errorf("Expected the nonce in the CSP, got %q and %q", nonce, h.Get("Content-Security-Policy"))
+	}
+	if h.Get("Strict-Transport-Security") != "max-age=63072000; includeSubDomains" {
+		t.Errorf("Expected the strict HSTS, got %q", h.Get("Strict-Transport-Security"))
+	}
+	if h.Get("X-Frame-Options") != "SAMEORIGIN" {
+		t.Errorf("Expected the upstream's own header to be kept, got %q", h.Get("X-Frame-Options"))
+	}
+	if h.Get("Server") != "" || h.Get("Cross-Origin-Embedder-Policy") != "" {
+		t.Errorf("Expected Server and COEP to be absent")
+	}
+
+	if _, err := ParseSecurityHeaders(map[string]interface{}{"preset": "paranoid", "hsts": 1.0}); err == nil || !strings.Contains(err.Error(), "paranoid") {
+		t.Errorf("Expected invalid settings to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package filters
+
+// intSetting reads a whole number from filter settings. Settings decoded from
+// JSON hold float64 values, while settings built in code may hold ints.
+func intSetting(settings map[string]interface{}, key string) (int, bool) {
+	switch v := settings[key].(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
+
+// stringMap converts a decoded JSON object into a map[string]string,
+// skipping non-string values.
+func stringMap(v interface{}) map[string]string {
+	switch m := v.(type) {
+	case map[string]string:
+		return m
+	case map[string]interface{}:
+		out := make(map[string]string, len(m))
+		for k, item := range m {
+			if s, ok := item.(string); ok {
+				out[k] = s
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
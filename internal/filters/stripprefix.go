@@ -20,6 +20,12 @@ func (f StripPrefixFilter) Apply(next http.Handler) http.Handler {
 		if strings.HasPrefix(r.URL.Path, f.Settings.Prefix) {
 			r.URL.Path = strings.TrimPrefix(r.URL.Path, f.Settings.Prefix)
 			log.Printf("Stripping prefix %s from path. New path: %s", f.Settings.Prefix, r.URL.Path)
+			if prefix := strings.TrimSuffix(f.Settings.Prefix, "/"); prefix != "" {
+				r = r.WithContext(WithForwardedPrefix(r.Context(), prefix))
+			}
+			if changes := PathChangesFromContext(r.Context()); changes != nil {
+				changes.Stripped += f.Settings.Prefix
+			}
 		}
 		next.ServeHTTP(w, r)
 	})
@@ -0,0 +1,359 @@
+package filters
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"zentro/internal/clientip"
+
+	coreruleset "github.com/corazawaf/coraza-coreruleset/v4"
+	"github.com/corazawaf/coraza/v3"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// RecordWafMatch is called for every rule a request matched. The gateway
+// points it at its metrics collector.
+var RecordWafMatch = func(ruleID int) {}
+
+// WafFilter inspects requests with the Coraza engine, optionally loaded with
+// the OWASP Core Rule Set.
+type WafFilter struct {
+	Name     string
+	Settings WafSettings
+}
+
+type WafSettings struct {
+	// Mode is "block" or "detect"; detect only records matches.
+	Mode string
+	// Crs loads the bundled OWASP Core Rule Set.
+	Crs              bool
+	ParanoiaLevel    int
+	AnomalyThreshold int
+	// RuleFiles are SecLang files or globs, loaded after the CRS.
+	RuleFiles  []string
+	Directives string
+	// DisabledRules are removed by ID, to deal with false positives.
+	DisabledRules []int
+	// InspectBody and BodyLimit control body inspection; bytes past the
+	// limit reach the upstream uninspected.
+	InspectBody bool
+	BodyLimit   int
+}
+
+// WafMatches collects the rules matched while handling a request, so that
+// the router can put them in the traffic log.
+type WafMatches struct {
+	RuleIDs []int
+}
+
+type wafMatchesKey struct{}
+
+func WithWafMatches(ctx context.Context) context.Context {
+	return context.WithValue(ctx, wafMatchesKey{}, &WafMatches{})
+}
+
+func WafMatchesFromContext(ctx context.Context) *WafMatches {
+	matches, _ := ctx.Value(wafMatchesKey{}).(*WafMatches)
+	return matches
+}
+
+var (
+	wafMu sync.Mutex
+	// wafs holds the engines of the loaded routes, keyed by their
+	// directives. CompileRouteFilters replaces it on every routes load.
+	wafs = map[string]coraza.WAF{}
+)
+
+// CompileRouteFilters compiles the schemas and WAF engines of a routes
+// load and makes both the sets used by requests, or neither if either
+// fails, so requests never see new schemas next to old engines.
+func CompileRouteFilters(filters []GenericFilter) error {
+	compiledSchemas, err := compileFilterSchemas(filters)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	compiledWafs, err := compileFilterWafs(filters)
+	if err != nil {
+		return fmt.Errorf("invalid waf rules: %w", err)
+	}
+	schemaMu.Lock()
+	schemas = compiledSchemas
+	schemaMu.Unlock()
+	wafMu.Lock()
+	wafs = compiledWafs
+	wafMu.Unlock()
+	return nil
+}
+
+func compileFilterWafs(filters []GenericFilter) (map[string]coraza.WAF, error) {
+	compiled := map[string]coraza.WAF{}
+	for _, filter := range filters {
+		if FilterTypeFromName(filter.Name) != WafFilterType {
+			continue
+		}
+		var f WafFilter
+		f.Convert(filter)
+		if _, err := compiledWaf(f.Settings.directives(), compiled); err != nil {
+			return nil, err
+		}
+	}
+	return compiled, nil
+}
+
+// lookupWaf finds an engine built at load time, building it on first use
+// for filters added since. Engines take a while to build, so that happens
+// outside wafMu; if two requests race, the first engine stored wins.
+func lookupWaf(directives string) (coraza.WAF, error) {
+	wafMu.Lock()
+	waf, ok := wafs[directives]
+	wafMu.Unlock()
+	if ok {
+		return waf, nil
+	}
+
+	waf, err := newWaf(directives)
+	if err != nil {
+		return nil, err
+	}
+	wafMu.Lock()
+	defer wafMu.Unlock()
+	if existing, ok := wafs[directives]; ok {
+		return existing, nil
+	}
+	wafs[directives] = waf
+	return waf, nil
+}
+
+func compiledWaf(directives string, cache map[string]coraza.WAF) (coraza.WAF, error) {
+	if waf, ok := cache[directives]; ok {
+		return waf, nil
+	}
+	waf, err := newWaf(directives)
+	if err != nil {
+		return nil, err
+	}
+	cache[directives] = waf
+	return waf, nil
+}
+
+func newWaf(directives string) (coraza.WAF, error) {
+	waf, err := coraza.NewWAF(coraza.NewWAFConfig().WithRootFS(wafFS{}).WithDirectives(directives))
+	if err != nil {
+		return nil, fmt.Errorf("waf rules: %w", err)
+	}
+	return waf, nil
+}
+
+// wafBodyProcessors are the body parsing rules of the recommended Coraza
+// configuration.
+const wafBodyProcessors = `SecRule REQUEST_HEADERS:Content-Type "^(?:application(?:/soap\+|/)|text/)xml" "id:200000,phase:1,t:none,t:lowercase,pass,nolog,ctl:requestBodyProcessor=XML"
+SecRule REQUEST_HEADERS:Content-Type "^application/json" "id:200001,phase:1,t:none,t:lowercase,pass,nolog,ctl:requestBodyProcessor=JSON"
+SecRule REQUEST_HEADERS:Content-Type "^application/[a-z0-9.-]+[+]json" "id:200006,phase:1,t:none,t:lowercase,pass,nolog,ctl:requestBodyProcessor=JSON"
+SecRule REQBODY_ERROR "!@eq 0" "id:200002,phase:2,t:none,log,deny,status:400,msg:'Failed to parse request body.',severity:2"`
+
+// directives renders the settings as SecLang.
+func (s WafSettings) directives() string {
+	engine := "On"
+	if s.Mode == "detect" {
+		engine = "DetectionOnly"
+	}
+	bodyAccess := "Off"
+	if s.InspectBody {
+		bodyAccess = "On"
+	}
+	lines := []string{
+		"SecRuleEngine " + engine,
+		"SecRequestBodyAccess " + bodyAccess,
+		"SecRequestBodyLimit " + strconv.Itoa(s.BodyLimit),
+		"SecRequestBodyInMemoryLimit " + strconv.Itoa(s.BodyLimit),
+		"SecRequestBodyLimitAction ProcessPartial",
+		"SecResponseBodyAccess Off",
+		"SecAuditEngine Off",
+		wafBodyProcessors,
+	}
+	if s.Crs {
+		lines = append(lines,
+			"Include @crs-setup.conf.example",
+			fmt.Sprintf(`SecAction "id:900000,phase:1,pass,nolog,setvar:tx.blocking_paranoia_level=%d"`, s.ParanoiaLevel),
+			fmt.Sprintf(`SecAction "id:900110,phase:1,pass,nolog,setvar:tx.inbound_anomaly_score_threshold=%d"`, s.AnomalyThreshold),
+			"Include @owasp_crs/*.conf",
+		)
+	}
+	for _, file := range s.RuleFiles {
+		lines = append(lines, "Include "+file)
+	}
+	if s.Directives != "" {
+		lines = append(lines, s.Directives)
+	}
+	for _, id := range s.DisabledRules {
+		lines = append(lines, "SecRuleRemoveById "+strconv.Itoa(id))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (f WafFilter) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		waf, err := lookupWaf(f.Settings.directives())
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		tx := waf.NewTransaction()
+		defer func() {
+			tx.ProcessLogging()
+			tx.Close()
+		}()
+
+		interruption, err := inspectRequest(tx, r)
+		if err != nil {
+			http.Error(w, "Could not read request", readErrorStatus(err))
+			return
+		}
+		recordWafMatches(r.Context(), tx)
+		if interruption != nil {
+			RecordRejection("Waf", strconv.Itoa(interruption.RuleID))
+			status := interruption.Status
+			if status == 0 {
+				status = http.StatusForbidden
+			}
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// inspectRequest runs the request phases. The inspected part of the body
+// is put back in front of the rest for the upstream.
+func inspectRequest(tx types.Transaction, r *http.Request) (*types.Interruption, error) {
+	tx.ProcessConnection(clientip.String(r), 0, "", 0)
+	tx.ProcessURI(r.URL.RequestURI(), r.Method, r.Proto)
+	for name, values := range r.Header {
+		for _, value := range values {
+			tx.AddRequestHeader(name, value)
+		}
+	}
+	if r.Host != "" {
+		tx.AddRequestHeader("Host", r.Host)
+		tx.SetServerName(r.Host)
+	}
+	if len(r.TransferEncoding) > 0 {
+		tx.AddRequestHeader("Transfer-Encoding", r.TransferEncoding[0])
+	}
+	if interruption := tx.ProcessRequestHeaders(); interruption != nil {
+		return interruption, nil
+	}
+
+	if tx.IsRequestBodyAccessible() && r.Body != nil && r.Body != http.NoBody {
+		interruption, _, err := tx.ReadRequestBodyFrom(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		if interruption != nil {
+			return interruption, nil
+		}
+		inspected, err := tx.RequestBodyReader()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = readCloser{io.MultiReader(inspected, r.Body), r.Body}
+	}
+	return tx.ProcessRequestBody()
+}
+
+// recordWafMatches reports the rules that matched with a message, leaving
+// out the CRS setup and bookkeeping rules.
+func recordWafMatches(ctx context.Context, tx types.Transaction) {
+	var ids []int
+	for _, rule := range tx.MatchedRules() {
+		if rule.Message() == "" {
+			continue
+		}
+		ids = append(ids, rule.Rule().ID())
+		RecordWafMatch(rule.Rule().ID())
+	}
+	if matches := WafMatchesFromContext(ctx); matches != nil {
+		matches.RuleIDs = append(matches.RuleIDs, ids...)
+	}
+}
+
+// wafFS resolves "@" paths to the bundled rule set and everything else to
+// the local file system.
+type wafFS struct{}
+
+func (wafFS) Open(name string) (fs.File, error) {
+	if strings.Contains(name, "@") {
+		return coreruleset.FS.Open(name[strings.Index(name, "@"):])
+	}
+	return os.Open(name)
+}
+
+func (wafFS) ReadFile(name string) ([]byte, error) {
+	if strings.Contains(name, "@") {
+		return fs.ReadFile(coreruleset.FS, name[strings.Index(name, "@"):])
+	}
+	return os.ReadFile(name)
+}
+
+func (wafFS) Glob(pattern string) ([]string, error) {
+	if strings.Contains(pattern, "@") {
+		return fs.Glob(coreruleset.FS, pattern[strings.Index(pattern, "@"):])
+	}
+	return filepath.Glob(pattern)
+}
+
+func (f *WafFilter) Convert(filter GenericFilter) {
+	f.Name = filter.Name
+	settings := WafSettings{Mode: "block", Crs: true, ParanoiaLevel: 1, AnomalyThreshold: 5, InspectBody: true, BodyLimit: 128 * 1024}
+	if mode, ok := filter.Settings["mode"].(string); ok {
+		if mode == "block" || mode == "detect" {
+			settings.Mode = mode
+		} else {
+			log.Println("mode must be block or detect for WafFilter")
+		}
+	}
+	if crs, ok := filter.Settings["crs"].(bool); ok {
+		settings.Crs = crs
+	}
+	if level, ok := intSetting(filter.Settings, "paranoia_level"); ok {
+		if level >= 1 && level <= 4 {
+			settings.ParanoiaLevel = level
+		} else {
+			log.Println("paranoia_level must be between 1 and 4 for WafFilter")
+		}
+	}
+	if threshold, ok := intSetting(filter.Settings, "anomaly_threshold"); ok && threshold > 0 {
+		settings.AnomalyThreshold = threshold
+	}
+	files, _ := filter.Settings["rule_files"].([]interface{})
+	for _, file := range files {
+		if path, ok := file.(string); ok {
+			settings.RuleFiles = append(settings.RuleFiles, path)
+		}
+	}
+	settings.Directives, _ = filter.Settings["directives"].(string)
+	ids, _ := filter.Settings["disabled_rules"].([]interface{})
+	for _, id := range ids {
+		if n, ok := id.(float64); ok {
+			settings.DisabledRules = append(settings.DisabledRules, int(n))
+		}
+	}
+	sort.Ints(settings.DisabledRules)
+	if inspect, ok := filter.Settings["inspect_body"].(bool); ok {
+		settings.InspectBody = inspect
+	}
+	if limit, ok := intSetting(filter.Settings, "body_limit"); ok && limit > 0 {
+		settings.BodyLimit = limit
+	}
+	f.Settings = settings
+}
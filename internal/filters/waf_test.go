@@ -0,0 +1,90 @@
+package filters
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWaf_CrsBlocksAndDetects(t *testing.T) {
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	for mode, want := range map[string]int{"block": http.StatusForbidden, "detect": http.StatusOK} {
+		var filter WafFilter
+		filter.Convert(GenericFilter{Name: "Waf", Settings: map[string]interface{}{"mode": mode}})
+
+		req := httptest.NewRequest("GET", "/users?id=1%27%20OR%20%271%27=%271", nil)
+		req.Header.Set("User-Agent", "test")
+		req.Header.Set("Accept", "*/*")
+		req = req.WithContext(WithWafMatches(req.Context()))
+		rec := httptest.NewRecorder()
+		filter.Apply(upstream).ServeHTTP(rec, req)
+
+		if rec.Code != want {
+			t.Errorf("%s: expected %d, got %d", mode, want, rec.Code)
+		}
+		if ids := WafMatchesFromContext(req.Context()).RuleIDs; len(ids) == 0 {
+			t.Errorf("%s: expected matched rule IDs", mode)
+		}
+	}
+}
+
+func TestWaf_InspectsBodyAndKeepsIt(t *testing.T) {
+	var filter WafFilter
+	filter.Convert(GenericFilter{Name: "Waf", Settings: map[string]interface{}{
+		"crs":        false,
+		"directives": `SecRule REQUEST_BODY "@contains forbidden" "id:1001,phase:2,deny,status:406,msg:'forbidden word'"`,
+	}})
+
+	var got string
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = string(body)
+	})
+	handler := filter.Apply(upstream)
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader("a=forbidden"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected the rule's status, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader("a=fine"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "a=fine" {
+		t.Errorf("Expected the upstream to read the inspected body, got %q", got)
+	}
+}
+
+func TestCompileRouteFilters_KeepsBothSetsOnFailure(t *testing.T) {
+	schema := GenericFilter{Name: "SchemaValidation", Settings: map[string]interface{}{
+		"body": map[string]interface{}{"post": map[string]interface{}{"type": "object"}},
+	}}
+	if err := CompileRouteFilters([]GenericFilter{schema}); err != nil {
+		t.Fatal(err)
+	}
+
+	replacement := GenericFilter{Name: "SchemaValidation", Settings: map[string]interface{}{
+		"body": map[string]interface{}{"post": map[string]interface{}{"type": "array"}},
+	}}
+	broken := GenericFilter{Name: "Waf", Settings: map[string]interface{}{"crs": false, "directives": "SecRule broken"}}
+	if err := CompileRouteFilters([]GenericFilter{replacement, broken}); err == nil {
+		t.Fatal("Expected invalid waf rules to fail the load")
+	}
+
+	oldKey, _ := schemaKey(schema.Settings["body"].(map[string]interface{})["post"])
+	newKey, _ := schemaKey(replacement.Settings["body"].(map[string]interface{})["post"])
+	schemaMu.Lock()
+	_, hasOld := schemas[oldKey]
+	_, hasNew := schemas[newKey]
+	schemaMu.Unlock()
+	if !hasOld || hasNew {
+		t.Errorf("Expected a failed load to keep the previous schemas, got old %v new %v", hasOld, hasNew)
+	}
+}
@@ -0,0 +1,46 @@
+package global
+
+import (
+	"fmt"
+	"time"
+	"zentro/internal/cache"
+	"zentro/internal/config"
+)
+
+// NewCacheStore builds the response cache configured under "cache". A nil
+// config yields the default in-memory store.
+func NewCacheStore(cfg *config.CacheStore) (cache.CacheStore, error) {
+	if cfg == nil {
+		cfg = &config.CacheStore{}
+	}
+
+	switch cfg.Store {
+	case "", config.CacheStoreMemory:
+		return cache.NewMemoryStore(cfg.MaxBytes), nil
+	case config.CacheStoreDisk:
+		dir := cfg.Dir
+		if dir == "" {
+			dir = "config/cache"
+		}
+		store, err := cache.OpenDiskStore(dir, cfg.MaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		store.StartJanitor(10 * time.Second)
+		return store, nil
+	case config.CacheStoreRedis:
+		if cfg.Redis.Addr == "" {
+			return nil, fmt.Errorf("cache store redis needs an addr")
+		}
+		return cache.NewRedisStore(cache.RedisOptions{
+			Addr:      cfg.Redis.Addr,
+			Username:  cfg.Redis.Username,
+			Password:  cfg.Redis.Password,
+			DB:        cfg.Redis.DB,
+			KeyPrefix: cfg.Redis.KeyPrefix,
+			Timeout:   time.Duration(cfg.Redis.TimeoutMs) * time.Millisecond,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown cache store %q", cfg.Store)
+	}
+}
@@ -2,17 +2,51 @@ package global
 
 import (
 	"log"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 	"zentro/internal/config"
+	"zentro/internal/filters"
 
 	"github.com/fsnotify/fsnotify"
 )
 
 var currentConsumers atomic.Value
 
+// credentialIndex maps a secret prefix or JWT key id to the credentials that
+// carry it, so authentication does not scan every consumer.
+var credentialIndex atomic.Value
+
+// credentialUsage records when each credential was last presented. It is kept
+// out of the consumer config so requests never write to the shared snapshot.
+var credentialUsage sync.Map
+
+type credentialRef struct {
+	consumer   *config.Consumer
+	credential *config.Credential
+}
+
 // InitConsumers initializes the global consumer configuration.
 func InitConsumers(cfg *config.ConsumerConfig) {
+	index := make(map[string][]credentialRef)
+	for i := range cfg.Consumers {
+		c := &cfg.Consumers[i]
+		for j := range c.Credentials {
+			cred := &c.Credentials[j]
+			key := cred.Prefix
+			switch cred.Type {
+			case config.CredentialJwt:
+				key = "kid:" + cred.KeyId
+			case config.CredentialHmac:
+				key = "hmac:" + cred.KeyId
+			case config.CredentialMtls:
+				key = "mtls:" + cred.Subject
+			}
+			index[key] = append(index[key], credentialRef{consumer: c, credential: cred})
+		}
+	}
+	credentialIndex.Store(index)
 	currentConsumers.Store(cfg)
 }
 
@@ -26,6 +60,109 @@ func GetConsumers() *config.ConsumerConfig {
 	return val.(*config.ConsumerConfig)
 }
 
+func lookupIndex(key string) []credentialRef {
+	index, _ := credentialIndex.Load().(map[string][]credentialRef)
+	return index[key]
+}
+
+// FindConsumer returns the consumer and credential matching the secret
+// presented with the given auth type. Basic credentials are expected as
+// "username:secret"; bearer tokens may be API keys or JWTs signed with a
+// registered key id. An mtls credential is one identity of a verified client
+// certificate.
+func FindConsumer(authType, credential string) (*config.Consumer, *config.Credential, bool) {
+	if credential == "" {
+		return nil, nil, false
+	}
+	now := time.Now()
+
+	if authType == "basic" {
+		username, secret, ok := strings.Cut(credential, ":")
+		if !ok {
+			return nil, nil, false
+		}
+		for _, ref := range lookupIndex(config.SecretPrefix(secret)) {
+			if ref.consumer.Username != username || ref.credential.Type == config.CredentialJwt {
+				continue
+			}
+			if ref.credential.IsUsable(now) && ref.credential.Matches(secret) {
+				TouchCredential(ref.credential.Id)
+				return ref.consumer, ref.credential, true
+			}
+		}
+		return nil, nil, false
+	}
+
+	if authType == "bearer" && strings.Count(credential, ".") == 2 {
+		kid := config.JwtKeyId(credential)
+		for _, ref := range lookupIndex("kid:" + kid) {
+			if kid != "" && ref.credential.IsUsable(now) && ref.credential.VerifyJwt(credential) {
+				TouchCredential(ref.credential.Id)
+				return ref.consumer, ref.credential, true
+			}
+		}
+		return nil, nil, false
+	}
+
+	if authType == "mtls" {
+		for _, ref := range lookupIndex("mtls:" + credential) {
+			if ref.credential.Type == config.CredentialMtls && ref.credential.IsUsable(now) {
+				TouchCredential(ref.credential.Id)
+				return ref.consumer, ref.credential, true
+			}
+		}
+		return nil, nil, false
+	}
+
+	for _, ref := range lookupIndex(config.SecretPrefix(credential)) {
+		if ref.credential.Type != config.CredentialApiKey {
+			continue
+		}
+		if ref.credential.IsUsable(now) && ref.credential.Matches(credential) {
+			TouchCredential(ref.credential.Id)
+			return ref.consumer, ref.credential, true
+		}
+	}
+	return nil, nil, false
+}
+
+// HmacSecrets returns the usable HMAC keys registered under keyID with
+// their consumers. There is more than one while a key is being rotated.
+func HmacSecrets(keyID string) []filters.HmacSecret {
+	var secrets []filters.HmacSecret
+	now := time.Now()
+	for _, ref := range lookupIndex("hmac:" + keyID) {
+		if keyID == "" || ref.credential.Type != config.CredentialHmac || !ref.credential.IsUsable(now) {
+			continue
+		}
+		secrets = append(secrets, filters.HmacSecret{
+			Key: []byte(ref.credential.Secret),
+			Consumer: &filters.Consumer{
+				ID:           ref.consumer.Id,
+				Username:     ref.consumer.Username,
+				Groups:       ref.consumer.Groups,
+				CredentialID: ref.credential.Id,
+				Limits:       ref.consumer.RateLimit,
+			},
+		})
+	}
+	return secrets
+}
+
+// TouchCredential records that a credential was just used.
+func TouchCredential(id string) {
+	credentialUsage.Store(id, time.Now().UTC())
+}
+
+// CredentialLastUsed returns when a credential was last used by this process.
+func CredentialLastUsed(id string) (time.Time, bool) {
+	v, ok := credentialUsage.Load(id)
+	if !ok {
+		return time.Time{}, false
+	}
+	return v.(time.Time), true
+}
+
 // WatchConsumersFile watches for changes and hot-reloads the consumer config.
 func WatchConsumersFile(path string) {
 	watcher, err := fsnotify.NewWatcher()
@@ -19,6 +19,8 @@ type LogEntry struct {
 	StatusCode int           `json:"statusCode"`
 	Latency    time.Duration `json:"latency"`
 	ClientIP    string `json:"clientIp"`
+	// WafRules are the IDs of the WAF rules the request matched.
+	WafRules []int `json:"wafRules,omitempty"`
 }
 
 // DataPoint represents a single point in a time series.
@@ -41,6 +43,9 @@ type MetricsCollector struct {
 	timeSeries24        []DataPoint
 	lastTimeSeries24    time.Time
 	lastTotalRequests24 uint64
+	rejections          map[string]uint64
+	faults              map[string]uint64
+	wafRules            map[int]uint64
 }
 
 // GlobalMetrics is the single instance of the metrics collector.
@@ -54,10 +59,13 @@ var GlobalMetrics = &MetricsCollector{
 	timeSeries24:     make([]DataPoint, 0, MaxTimeSeries24Points),
 	lastTimeSeries24: time.Now(),
 	lastTotalRequests24: 0,
+	rejections:       make(map[string]uint64),
+	faults:           make(map[string]uint64),
+	wafRules:         make(map[int]uint64),
 }
 
 // RecordRequest adds a new request to the metrics collector.
-func (mc *MetricsCollector) RecordRequest(method, path string, statusCode int, latency time.Duration, client string) {
+func (mc *MetricsCollector) RecordRequest(method, path string, statusCode int, latency time.Duration, client string, wafRules []int) {
 	mc.mu.Lock()
 	defer mc.mu.Unlock()
 
@@ -77,6 +85,7 @@ func (mc *MetricsCollector) RecordRequest(method, path string, statusCode int, l
 		StatusCode: statusCode,
 		Latency:    latency,
 		ClientIP:   client,
+		WafRules:   wafRules,
 	}
 	if len(mc.requestLog) >= MaxLogEntries {
 		mc.requestLog = mc.requestLog[1:]
@@ -120,6 +129,29 @@ func (mc *MetricsCollector) RecordRequest(method, path string, statusCode int, l
 	}
 }
 
+// RecordRejection counts a request turned away by a filter, keyed
+// "filter:reason".
+func (mc *MetricsCollector) RecordRejection(filter, reason string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.rejections[filter+":"+reason]++
+}
+
+// RecordFault counts a fault injected by the FaultInjection filter, keyed
+// by its kind.
+func (mc *MetricsCollector) RecordFault(fault string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.faults[fault]++
+}
+
+// RecordWafMatch counts a request that matched a WAF rule, keyed by rule ID.
+func (mc *MetricsCollector) RecordWafMatch(ruleID int) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.wafRules[ruleID]++
+}
+
 // GetMetrics returns a snapshot of the current metrics.
 type MetricsSnapshot struct {
 	Uptime         time.Duration
@@ -130,6 +162,9 @@ type MetricsSnapshot struct {
 	ErrorRate      float64
 	TimeSeries     []DataPoint
 	TimeSeries24   []DataPoint
+	Rejections     map[string]uint64
+	Faults         map[string]uint64
+	WafRules       map[int]uint64
 }
 
 func (mc *MetricsCollector) GetMetrics() MetricsSnapshot {
@@ -158,6 +193,18 @@ func (mc *MetricsCollector) GetMetrics() MetricsSnapshot {
 	copy(tsCopy, mc.timeSeries)
 	ts24Copy := make([]DataPoint, len(mc.timeSeries24))
 	copy(ts24Copy, mc.timeSeries24)
+	rejections := make(map[string]uint64, len(mc.rejections))
+	for k, v := range mc.rejections {
+		rejections[k] = v
+	}
+	faults := make(map[string]uint64, len(mc.faults))
+	for k, v := range mc.faults {
+		faults[k] = v
+	}
+	wafRules := make(map[int]uint64, len(mc.wafRules))
+	for k, v := range mc.wafRules {
+		wafRules[k] = v
+	}
 
 	return MetricsSnapshot{
 		Uptime:         time.Since(mc.startTime),
@@ -168,5 +215,8 @@ func (mc *MetricsCollector) GetMetrics() MetricsSnapshot {
 		ErrorRate:      errorRate,
 		TimeSeries:     tsCopy,
 		TimeSeries24:   ts24Copy,
+		Rejections:     rejections,
+		Faults:         faults,
+		WafRules:       wafRules,
 	}
 }
\ No newline at end of file
@@ -0,0 +1,53 @@
+package global
+
+import (
+	"fmt"
+	"time"
+	"zentro/internal/config"
+	"zentro/internal/ratelimit"
+)
+
+// NewRateLimitStore builds the store configured under "rate_limit". A nil
+// config yields the default in-memory store.
+func NewRateLimitStore(cfg *config.RateLimitStore) (ratelimit.Store, error) {
+	if cfg == nil {
+		cfg = &config.RateLimitStore{}
+	}
+
+	local := ratelimit.NewMemoryStore(cfg.MaxKeys)
+	local.StartJanitor(time.Minute)
+
+	switch cfg.Store {
+	case "", config.RateLimitStoreMemory:
+		return local, nil
+	case config.RateLimitStoreRedis:
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", cfg.Store)
+	}
+
+	if cfg.Redis.Addr == "" {
+		return nil, fmt.Errorf("rate limit store redis needs an addr")
+	}
+	mode := cfg.FailureMode
+	switch mode {
+	case "":
+		mode = ratelimit.FailOpen
+	case ratelimit.FailOpen, ratelimit.FailClosed, ratelimit.FailLocal:
+	default:
+		return nil, fmt.Errorf("unknown rate limit failure mode %q", mode)
+	}
+
+	redisStore := ratelimit.NewRedisStore(ratelimit.RedisOptions{
+		Addr:      cfg.Redis.Addr,
+		Username:  cfg.Redis.Username,
+		Password:  cfg.Redis.Password,
+		DB:        cfg.Redis.DB,
+		KeyPrefix: cfg.Redis.KeyPrefix,
+		Timeout:   time.Duration(cfg.Redis.TimeoutMs) * time.Millisecond,
+	})
+	var primary ratelimit.Store = redisStore
+	if cfg.BatchSize > 1 {
+		primary = ratelimit.NewBatchingStore(redisStore, cfg.BatchSize, time.Second)
+	}
+	return &ratelimit.FailoverStore{Primary: primary, Local: local, Mode: mode, Backoff: time.Second}, nil
+}
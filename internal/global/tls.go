@@ -0,0 +1,48 @@
+package global
+
+import (
+	"crypto/tls"
+	"fmt"
+	"zentro/internal/config"
+	"zentro/internal/tlsserver"
+)
+
+// NewTLSStore loads the certificates of the listener configured under "tls".
+func NewTLSStore(cfg *config.TlsListener) (*tlsserver.Store, error) {
+	opts := tlsserver.Options{ClientCAFiles: cfg.ClientCAFiles}
+	for _, cert := range cfg.Certificates {
+		opts.Certificates = append(opts.Certificates, tlsserver.CertificateFiles{
+			CertFile:    cert.CertFile,
+			KeyFile:     cert.KeyFile,
+			ServerNames: cert.ServerNames,
+		})
+	}
+
+	switch cfg.MinVersion {
+	case "", "1.2":
+		opts.MinVersion = tls.VersionTLS12
+	case "1.3":
+		opts.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unknown tls min_version %q", cfg.MinVersion)
+	}
+
+	switch cfg.ClientAuth {
+	case "", config.ClientAuthNone:
+		opts.ClientAuth = tls.NoClientCert
+	case config.ClientAuthOptional:
+		opts.ClientAuth = tls.VerifyClientCertIfGiven
+	case config.ClientAuthRequire:
+		opts.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unknown tls client_auth %q", cfg.ClientAuth)
+	}
+
+	switch cfg.ForwardClientCert {
+	case "", config.ForwardClientCertNone, config.ForwardClientCertDetails, config.ForwardClientCertPem:
+	default:
+		return nil, fmt.Errorf("unknown tls forward_client_cert %q", cfg.ForwardClientCert)
+	}
+
+	return tlsserver.NewStore(opts)
+}
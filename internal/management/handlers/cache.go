@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"zentro/internal/cache"
+	"zentro/internal/filters"
+	"zentro/internal/global"
+	"zentro/internal/router"
+)
+
+type purgeRequest struct {
+	// Route is a route ID or name.
+	Route  string `json:"route"`
+	Prefix string `json:"prefix"`
+	Tag    string `json:"tag"`
+}
+
+// PurgeCacheHandler drops cached responses matching every given selector.
+func PurgeCacheHandler(w http.ResponseWriter, r *http.Request) {
+	var req purgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Route == "" && req.Prefix == "" && req.Tag == "" {
+		http.Error(w, "One of route, prefix or tag is required", http.StatusBadRequest)
+		return
+	}
+
+	purge := cache.PurgeFilter{Prefix: req.Prefix, Tag: req.Tag}
+	if req.Route != "" {
+		routes := global.GetConfig().Routes
+		for i := range routes {
+			if routes[i].ID == req.Route || routes[i].Name == req.Route {
+				purge.Scope = router.RouteScope(&routes[i])
+				break
+			}
+		}
+		if purge.Scope == "" {
+			http.Error(w, "Route not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"purged": filters.ResponseCache.Purge(purge)})
+}
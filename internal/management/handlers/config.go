@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"os"
+	"zentro/internal/config"
+	"zentro/internal/filters"
 )
 
 func GetConfigHandler(w http.ResponseWriter, r *http.Request) {
@@ -25,9 +28,19 @@ func UpdateConfigHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	// It's a good practice to validate the JSON before writing,
-	// but for now, we'll just write it as requested.
-	// A production system should unmarshal to a struct to validate.
+	var gatewayConfig config.GatewayConfig
+	if err := json.Unmarshal(body, &gatewayConfig); err != nil {
+		http.Error(w, "Invalid config", http.StatusBadRequest)
+		return
+	}
+	var routeFilters []filters.GenericFilter
+	for _, route := range gatewayConfig.Routes {
+		routeFilters = append(routeFilters, route.Filters...)
+	}
+	if err := filters.CheckSchemas(routeFilters); err != nil {
+		http.Error(w, "Invalid schema: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	if err := os.WriteFile("config/routes.json", body, 0644); err != nil {
 		http.Error(w, "Could not write config file", http.StatusInternalServerError)
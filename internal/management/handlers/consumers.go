@@ -5,91 +5,136 @@ import (
 	"net/http"
 	"os"
 	"zentro/internal/config"
+	"zentro/internal/filters"
+	"zentro/internal/global"
 	"zentro/utils"
 	"github.com/go-chi/chi/v5"
 )
 
+// readConsumerConfig reads the consumers file straight from disk so that
+// handlers always edit the persisted state rather than the in-memory copy.
+// Loading through config.LoadConsumers hashes any legacy plaintext keys.
+func readConsumerConfig() (*config.ConsumerConfig, error) {
+	return config.LoadConsumers(config.Gf.ConsumersConfigPath)
+}
+
+// writeConsumerConfig persists the consumers file and publishes it to the
+// gateway immediately instead of waiting for the file watcher.
+func writeConsumerConfig(consumerConfig *config.ConsumerConfig) error {
+	for i := range consumerConfig.Consumers {
+		consumerConfig.Consumers[i] = withUsage(consumerConfig.Consumers[i])
+	}
+
+	updatedData, err := json.MarshalIndent(consumerConfig, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(config.Gf.ConsumersConfigPath, updatedData, 0644); err != nil {
+		return err
+	}
+	global.InitConsumers(consumerConfig)
+	return nil
+}
+
+// withUsage copies the consumer with last-used times tracked in memory by the
+// gateway folded into its credentials.
+func withUsage(c config.Consumer) config.Consumer {
+	creds := make([]config.Credential, len(c.Credentials))
+	copy(creds, c.Credentials)
+	for i := range creds {
+		if t, ok := global.CredentialLastUsed(creds[i].Id); ok {
+			creds[i].LastUsedAt = &t
+		}
+	}
+	c.Credentials = creds
+	return c
+}
+
+// redacted is withUsage for API responses: HMAC keys are left out. It must
+// never be used for the config that is saved or registered.
+func redacted(c config.Consumer) config.Consumer {
+	c = withUsage(c)
+	for i := range c.Credentials {
+		c.Credentials[i].Secret = ""
+	}
+	return c
+}
+
 func GetConsumersHandler(w http.ResponseWriter, r *http.Request) {
-	data, err := os.ReadFile("config/consumers.json")
+	consumerConfig, err := readConsumerConfig()
 	if err != nil {
 		http.Error(w, "Could not read consumers file", http.StatusInternalServerError)
 		return
 	}
 
-	var consumerConfig config.ConsumerConfig
-	if err := json.Unmarshal(data, &consumerConfig); err != nil {
-		http.Error(w, "Could not parse consumers file", http.StatusInternalServerError)
-		return
+	consumers := make([]config.Consumer, 0, len(consumerConfig.Consumers))
+	for _, c := range consumerConfig.Consumers {
+		consumers = append(consumers, redacted(c))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(consumerConfig.Consumers)
+	json.NewEncoder(w).Encode(consumers)
 }
 
 func CreateConsumerHandler(w http.ResponseWriter, r *http.Request) {
 	var newConsumerReq struct {
-		Username string `json:"username"`
+		Username string   `json:"username"`
+		Groups   []string `json:"groups"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&newConsumerReq); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	data, err := os.ReadFile("config/consumers.json")
+	consumerConfig, err := readConsumerConfig()
 	if err != nil {
 		http.Error(w, "Could not read consumers file", http.StatusInternalServerError)
 		return
 	}
 
-	var consumerConfig config.ConsumerConfig
-	if err := json.Unmarshal(data, &consumerConfig); err != nil {
-		http.Error(w, "Could not parse consumers file", http.StatusInternalServerError)
-		return
+	credential, apiKey := config.NewSecretCredential(config.CredentialApiKey, nil)
+	newConsumer := config.Consumer{
+		Id:          utils.GenerateRandomID(16),
+		Username:    newConsumerReq.Username,
+		Groups:      newConsumerReq.Groups,
+		Credentials: []config.Credential{credential},
 	}
 
-	newConsumer := config.Consumer{
-		Id:       utils.GenerateRandomID(16),
-		Username: newConsumerReq.Username,
-		ApiKey:   "zen_" + utils.GenerateRandomID(32),
+	for _, g := range newConsumer.Groups {
+		if !consumerConfig.HasGroup(g) {
+			consumerConfig.Groups = append(consumerConfig.Groups, config.ConsumerGroup{Name: g})
+		}
 	}
 
 	consumerConfig.Consumers = append(consumerConfig.Consumers, newConsumer)
 
-	updatedData, err := json.MarshalIndent(consumerConfig, "", "  ")
-	if err != nil {
-		http.Error(w, "Could not marshal consumers", http.StatusInternalServerError)
-		return
-	}
-
-	if err := os.WriteFile("config/consumers.json", updatedData, 0644); err != nil {
+	if err := writeConsumerConfig(consumerConfig); err != nil {
 		http.Error(w, "Could not write consumers file", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	// The plaintext key is only ever returned here.
+	newConsumer.ApiKey = apiKey
+
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(newConsumer)
 }
 
 func GetConsumerHandler(w http.ResponseWriter, r *http.Request) {
 	consumerID := chi.URLParam(r, "id")
 
-	data, err := os.ReadFile("config/consumers.json")
+	consumerConfig, err := readConsumerConfig()
 	if err != nil {
 		http.Error(w, "Could not read consumers file", http.StatusInternalServerError)
 		return
 	}
 
-	var consumerConfig config.ConsumerConfig
-	if err := json.Unmarshal(data, &consumerConfig); err != nil {
-		http.Error(w, "Could not parse consumers file", http.StatusInternalServerError)
-		return
-	}
-
 	for _, consumer := range consumerConfig.Consumers {
 		if consumer.Id == consumerID {
 			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(consumer)
+			json.NewEncoder(w).Encode(redacted(consumer))
 			return
 		}
 	}
@@ -100,18 +145,12 @@ func GetConsumerHandler(w http.ResponseWriter, r *http.Request) {
 func DeleteConsumerHandler(w http.ResponseWriter, r *http.Request) {
 	consumerID := chi.URLParam(r, "id")
 
-	data, err := os.ReadFile("config/consumers.json")
+	consumerConfig, err := readConsumerConfig()
 	if err != nil {
 		http.Error(w, "Could not read consumers file", http.StatusInternalServerError)
 		return
 	}
 
-	var consumerConfig config.ConsumerConfig
-	if err := json.Unmarshal(data, &consumerConfig); err != nil {
-		http.Error(w, "Could not parse consumers file", http.StatusInternalServerError)
-		return
-	}
-
 	var found bool
 	var consumerIndex int
 	for i, consumer := range consumerConfig.Consumers {
@@ -129,16 +168,41 @@ func DeleteConsumerHandler(w http.ResponseWriter, r *http.Request) {
 
 	consumerConfig.Consumers = append(consumerConfig.Consumers[:consumerIndex], consumerConfig.Consumers[consumerIndex+1:]...)
 
-	updatedData, err := json.MarshalIndent(consumerConfig, "", "  ")
+	if err := writeConsumerConfig(consumerConfig); err != nil {
+		http.Error(w, "Could not write consumers file", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateConsumerRateLimitHandler sets or clears (with a null body) the
+// consumer's rate limit and quota overrides.
+func UpdateConsumerRateLimitHandler(w http.ResponseWriter, r *http.Request) {
+	var limits *filters.ConsumerLimits
+	if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	consumerConfig, err := readConsumerConfig()
 	if err != nil {
-		http.Error(w, "Could not marshal consumers", http.StatusInternalServerError)
+		http.Error(w, "Could not read consumers file", http.StatusInternalServerError)
 		return
 	}
 
-	if err := os.WriteFile("config/consumers.json", updatedData, 0644); err != nil {
+	consumer := findConsumer(consumerConfig, r)
+	if consumer == nil {
+		http.NotFound(w, r)
+		return
+	}
+	consumer.RateLimit = limits
+
+	if err := writeConsumerConfig(consumerConfig); err != nil {
 		http.Error(w, "Could not write consumers file", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redacted(*consumer))
 }
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+	"zentro/internal/config"
+	"zentro/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const defaultRotationGrace = 24 * time.Hour
+
+var (
+	errInvalidJwtCredential  = errors.New("jwt credentials need a key_id and a PEM encoded public_key")
+	errInvalidHmacCredential = errors.New("hmac credentials need a key_id and, if given, a secret of at least 32 characters")
+	errInvalidMtlsCredential = errors.New("mtls credentials need the subject of the client certificate")
+	errUnknownCredentialType = errors.New("type must be one of api-key, basic, jwt, hmac or mtls")
+)
+
+type credentialRequest struct {
+	Type      string     `json:"type"`
+	KeyId     string     `json:"key_id"`
+	PublicKey string     `json:"public_key"`
+	// Secret is an HMAC key agreed with the partner; one is generated when
+	// it is empty.
+	Secret    string     `json:"secret"`
+	// Subject is a SAN or the common name of an mtls client certificate.
+	Subject   string     `json:"subject"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	// TTL is a Go duration such as "720h", used when ExpiresAt is not set.
+	TTL         string `json:"ttl"`
+	GracePeriod string `json:"grace_period"`
+}
+
+type credentialResponse struct {
+	Credential config.Credential `json:"credential"`
+	// Secret is the plaintext key, returned once when it is generated.
+	Secret string `json:"secret,omitempty"`
+}
+
+func (req credentialRequest) expiry() (*time.Time, error) {
+	if req.ExpiresAt != nil {
+		return req.ExpiresAt, nil
+	}
+	if req.TTL == "" {
+		return nil, nil
+	}
+	ttl, err := time.ParseDuration(req.TTL)
+	if err != nil {
+		return nil, err
+	}
+	t := time.Now().UTC().Add(ttl)
+	return &t, nil
+}
+
+// newCredential builds a credential of the requested type. Secrets are
+// generated for api-key and basic credentials; jwt credentials register the
+// caller's public key under a key id, and hmac credentials a shared key.
+func newCredential(req credentialRequest) (config.Credential, string, error) {
+	expiresAt, err := req.expiry()
+	if err != nil {
+		return config.Credential{}, "", err
+	}
+
+	switch req.Type {
+	case "", config.CredentialApiKey:
+		cred, secret := config.NewSecretCredential(config.CredentialApiKey, expiresAt)
+		return cred, secret, nil
+	case config.CredentialBasic:
+		cred, secret := config.NewSecretCredential(config.CredentialBasic, expiresAt)
+		return cred, secret, nil
+	case config.CredentialJwt:
+		if req.KeyId == "" || !validPublicKey(req.PublicKey) {
+			return config.Credential{}, "", errInvalidJwtCredential
+		}
+		return config.Credential{
+			Id:        utils.GenerateRandomID(16),
+			Type:      config.CredentialJwt,
+			KeyId:     req.KeyId,
+			PublicKey: req.PublicKey,
+			Status:    config.CredentialActive,
+			CreatedAt: time.Now().UTC(),
+			ExpiresAt: expiresAt,
+		}, "", nil
+	case config.CredentialHmac:
+		secret := req.Secret
+		if secret == "" {
+			secret = utils.GenerateRandomID(48)
+		}
+		if req.KeyId == "" || len(secret) < 32 {
+			return config.Credential{}, "", errInvalidHmacCredential
+		}
+		return config.Credential{
+			Id:        utils.GenerateRandomID(16),
+			Type:      config.CredentialHmac,
+			KeyId:     req.KeyId,
+			Secret:    secret,
+			Status:    config.CredentialActive,
+			CreatedAt: time.Now().UTC(),
+			ExpiresAt: expiresAt,
+		}, secret, nil
+	case config.CredentialMtls:
+		if req.Subject == "" {
+			return config.Credential{}, "", errInvalidMtlsCredential
+		}
+		return config.Credential{
+			Id:        utils.GenerateRandomID(16),
+			Type:      config.CredentialMtls,
+			Subject:   req.Subject,
+			Status:    config.CredentialActive,
+			CreatedAt: time.Now().UTC(),
+			ExpiresAt: expiresAt,
+		}, "", nil
+	default:
+		return config.Credential{}, "", errUnknownCredentialType
+	}
+}
+
+func validPublicKey(pem string) bool {
+	key := []byte(pem)
+	if _, err := jwt.ParseRSAPublicKeyFromPEM(key); err == nil {
+		return true
+	}
+	if _, err := jwt.ParseECPublicKeyFromPEM(key); err == nil {
+		return true
+	}
+	_, err := jwt.ParseEdPublicKeyFromPEM(key)
+	return err == nil
+}
+
+// findConsumer returns a pointer into consumerConfig for the consumer in the URL.
+func findConsumer(consumerConfig *config.ConsumerConfig, r *http.Request) *config.Consumer {
+	consumerID := chi.URLParam(r, "id")
+	for i := range consumerConfig.Consumers {
+		if consumerConfig.Consumers[i].Id == consumerID {
+			return &consumerConfig.Consumers[i]
+		}
+	}
+	return nil
+}
+
+func GetCredentialsHandler(w http.ResponseWriter, r *http.Request) {
+	consumerConfig, err := readConsumerConfig()
+	if err != nil {
+		http.Error(w, "Could not read consumers file", http.StatusInternalServerError)
+		return
+	}
+
+	consumer := findConsumer(consumerConfig, r)
+	if consumer == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	creds := redacted(*consumer).Credentials
+	if creds == nil {
+		creds = []config.Credential{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(creds)
+}
+
+func CreateCredentialHandler(w http.ResponseWriter, r *http.Request) {
+	var req credentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	consumerConfig, err := readConsumerConfig()
+	if err != nil {
+		http.Error(w, "Could not read consumers file", http.StatusInternalServerError)
+		return
+	}
+
+	consumer := findConsumer(consumerConfig, r)
+	if consumer == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	cred, secret, err := newCredential(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	consumer.Credentials = append(consumer.Credentials, cred)
+
+	if err := writeConsumerConfig(consumerConfig); err != nil {
+		http.Error(w, "Could not write consumers file", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	cred.Secret = ""
+	json.NewEncoder(w).Encode(credentialResponse{Credential: cred, Secret: secret})
+}
+
+// RotateCredentialHandler issues a replacement credential of the same type and
+// keeps the old one valid for a grace period so clients can switch over.
+func RotateCredentialHandler(w http.ResponseWriter, r *http.Request) {
+	var req credentialRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	grace := defaultRotationGrace
+	if req.GracePeriod != "" {
+		d, err := time.ParseDuration(req.GracePeriod)
+		if err != nil || d < 0 {
+			http.Error(w, "Invalid grace_period", http.StatusBadRequest)
+			return
+		}
+		grace = d
+	}
+
+	consumerConfig, err := readConsumerConfig()
+	if err != nil {
+		http.Error(w, "Could not read consumers file", http.StatusInternalServerError)
+		return
+	}
+
+	consumer := findConsumer(consumerConfig, r)
+	if consumer == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	old := consumer.FindCredential(chi.URLParam(r, "credentialId"))
+	if old == nil || old.Status == config.CredentialRevoked {
+		http.Error(w, "Credential not found", http.StatusNotFound)
+		return
+	}
+
+	req.Type = old.Type
+	if req.Type == config.CredentialHmac && req.KeyId == "" {
+		// Both keys verify under the same key id until the old one expires.
+		req.KeyId = old.KeyId
+	}
+	if req.Type == config.CredentialMtls && req.Subject == "" {
+		req.Subject = old.Subject
+	}
+	cred, secret, err := newCredential(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	graceEnd := time.Now().UTC().Add(grace)
+	if old.ExpiresAt == nil || graceEnd.Before(*old.ExpiresAt) {
+		old.ExpiresAt = &graceEnd
+	}
+	old.RotatedTo = cred.Id
+	consumer.Credentials = append(consumer.Credentials, cred)
+
+	if err := writeConsumerConfig(consumerConfig); err != nil {
+		http.Error(w, "Could not write consumers file", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	cred.Secret = ""
+	json.NewEncoder(w).Encode(credentialResponse{Credential: cred, Secret: secret})
+}
+
+// RevokeCredentialHandler disables a credential immediately. The record is
+// kept so its history remains visible.
+func RevokeCredentialHandler(w http.ResponseWriter, r *http.Request) {
+	consumerConfig, err := readConsumerConfig()
+	if err != nil {
+		http.Error(w, "Could not read consumers file", http.StatusInternalServerError)
+		return
+	}
+
+	consumer := findConsumer(consumerConfig, r)
+	if consumer == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	cred := consumer.FindCredential(chi.URLParam(r, "credentialId"))
+	if cred == nil {
+		http.Error(w, "Credential not found", http.StatusNotFound)
+		return
+	}
+	cred.Status = config.CredentialRevoked
+
+	if err := writeConsumerConfig(consumerConfig); err != nil {
+		http.Error(w, "Could not write consumers file", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
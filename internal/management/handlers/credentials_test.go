@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"zentro/internal/config"
+	"zentro/internal/filters"
+	"zentro/internal/global"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestCreateCredential_HmacKeyVerifiesSignatures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "consumers.json")
+	os.WriteFile(path, []byte(`{"consumers":[{"id":"c1","username":"partner"}]}`), 0600)
+	defer func(gf *config.GatewayFlagOptions) { config.Gf = gf }(config.Gf)
+	config.Gf = &config.GatewayFlagOptions{ConsumersConfigPath: path}
+	global.InitConsumers(config.MustLoadConsumers(path))
+	defer func(lookup func(string) []filters.HmacSecret) { filters.HmacSecrets = lookup }(filters.HmacSecrets)
+	filters.HmacSecrets = global.HmacSecrets
+
+	r := chi.NewRouter()
+	r.Post("/consumers/{id}/credentials", CreateCredentialHandler)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("POST", "/consumers/c1/credentials", strings.NewReader(`{"type":"hmac","key_id":"partner-1"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var created credentialResponse
+	json.NewDecoder(rec.Body).Decode(&created)
+	if created.Secret == "" || created.Credential.Secret != "" {
+		t.Fatalf("Expected the key once, outside the credential, got %+v", created)
+	}
+
+	saved, _ := config.LoadConsumers(path)
+	if cred := saved.Consumers[0].Credentials[0]; cred.Secret != created.Secret {
+		t.Errorf("Expected the key to be saved, got %q", cred.Secret)
+	}
+
+	var filter filters.HmacSignatureFilter
+	filter.Convert(filters.GenericFilter{Name: "HmacSignature", Settings: map[string]interface{}{
+		"components":   []interface{}{"method", "path", "timestamp"},
+		"nonce_header": "",
+	}})
+	var consumer *filters.Consumer
+	handler := filter.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		consumer, _ = filters.ConsumerFromContext(r.Context())
+	}))
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(created.Secret))
+	mac.Write([]byte("GET\n/orders\n" + ts))
+	req := httptest.NewRequest("GET", "/orders", nil)
+	req.Header.Set("X-Key-Id", "partner-1")
+	req.Header.Set("X-Timestamp", ts)
+	req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || consumer == nil || consumer.ID != "c1" {
+		t.Errorf("Expected the request to verify as c1, got %d %+v", rec.Code, consumer)
+	}
+}
@@ -19,6 +19,9 @@ type FrontendMetrics struct {
     TimeSeries []global.DataPoint `json:"timeSeries"`
 	TimeSeries24 []global.DataPoint `json:"timeSeries24"`
 	Uptime time.Duration `json:"uptime"`
+	Rejections map[string]uint64 `json:"rejections"`
+	Faults map[string]uint64 `json:"faults"`
+	WafRules map[int]uint64 `json:"wafRules"`
 }
 
 
@@ -80,6 +83,9 @@ func DashboardHandler(w http.ResponseWriter, r *http.Request) {
 			TimeSeries: metrics.TimeSeries,
 			TimeSeries24:metrics.TimeSeries24,
 			Uptime: metrics.Uptime,
+			Rejections: metrics.Rejections,
+			Faults: metrics.Faults,
+			WafRules: metrics.WafRules,
 		},
 		ActiveRoutes:   len(activeRoutes),
 		SystemStatus:   systemStatus,
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 
 	"zentro/internal/config"
+	"zentro/internal/filters"
 )
 
 func GetGlobalSettingsHandler(w http.ResponseWriter, r *http.Request) {
@@ -21,22 +23,63 @@ func GetGlobalSettingsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func UpdateGlobalSettingsHandler(w http.ResponseWriter, r *http.Request) {
-	// body, err := io.ReadAll(r.Body)
-	// if err != nil {
-	// 	http.Error(w, "Could not read request body", http.StatusBadRequest)
-	// 	return
-	// }
-	// defer r.Body.Close()
-
-	// var updatedSettings config.GlobalConfig
-	// err = json.Unmarshal(body, &updatedSettings)
-	// if err != nil {
-	// 	http.Error(w, "Could not unmarshal request body", http.StatusBadRequest)
-	// 	return
-	// }
-
-	// config.UpdateGlobalConfig(&updatedSettings)
-
-	// w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Global settings updated successfully."))
+	// Fields missing from the body keep their current values. The live
+	// settings are only replaced once the update is valid.
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	var sent map[string]json.RawMessage
+	if err == nil {
+		err = json.Unmarshal(body, &sent)
+	}
+	if err != nil {
+		http.Error(w, "Could not unmarshal request body", http.StatusBadRequest)
+		return
+	}
+	updatedSettings := config.GetGlobalConfig().Clone()
+	// json merges into maps, so a sent security_headers object replaces
+	// the current one rather than adding to it.
+	if _, ok := sent["security_headers"]; ok {
+		updatedSettings.SecurityHeaders = nil
+	}
+	if err := json.Unmarshal(body, updatedSettings); err != nil {
+		http.Error(w, "Could not unmarshal request body", http.StatusBadRequest)
+		return
+	}
+
+	if updatedSettings.GlobalRateLimiting < 0 {
+		http.Error(w, "global_rate_limiting must not be negative", http.StatusBadRequest)
+		return
+	}
+	if updatedSettings.CorsPolicy.MaxAge < 0 {
+		http.Error(w, "cors_policy.max_age must not be negative", http.StatusBadRequest)
+		return
+	}
+	limits := updatedSettings.RequestLimits
+	if limits.MaxHeaderCount < 0 || limits.MaxHeaderBytes < 0 || limits.MaxURLLength < 0 ||
+		limits.MaxQueryParams < 0 || limits.MaxBodyBytes < 0 || limits.MaxJSONDepth < 0 {
+		http.Error(w, "request_limits must not be negative", http.StatusBadRequest)
+		return
+	}
+	switch updatedSettings.ForwardedHeaders.Mode {
+	case "append", "overwrite", "strip_untrusted":
+	default:
+		http.Error(w, "forwarded_headers.mode must be append, overwrite or strip_untrusted", http.StatusBadRequest)
+		return
+	}
+	if _, err := filters.ParseSecurityHeaders(updatedSettings.SecurityHeaders); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := updatedSettings.ValidateNetworks(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := config.SaveGlobalConfig(config.Gf.SettingsPath, updatedSettings); err != nil {
+		http.Error(w, "Could not save global settings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedSettings)
 }
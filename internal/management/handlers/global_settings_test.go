@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"zentro/internal/config"
+)
+
+func TestUpdateGlobalSettings_RejectedUpdateLeavesLiveSettings(t *testing.T) {
+	defer func(gf *config.GatewayFlagOptions) { config.Gf = gf }(config.Gf)
+	config.Gf = &config.GatewayFlagOptions{SettingsPath: filepath.Join(t.TempDir(), "settings.json")}
+	config.Init("test")
+	live := config.GetGlobalConfig()
+	live.SecurityHeaders = map[string]interface{}{"preset": "basic"}
+
+	rec := httptest.NewRecorder()
+	UpdateGlobalSettingsHandler(rec, httptest.NewRequest("POST", "/api/settings", strings.NewReader(
+		`{"global_rate_limiting": -1, "cors_policy": {"allow_origins": ["https://evil"]}, "security_headers": {"preset": "none"}}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", rec.Code)
+	}
+	current := config.GetGlobalConfig()
+	if current != live || live.SecurityHeaders["preset"] != "basic" || live.CorsPolicy.AllowOrigins[0] != "*" || live.GlobalRateLimiting != 10000 {
+		t.Errorf("Expected the live settings to be unchanged, got %+v", current)
+	}
+
+	rec = httptest.NewRecorder()
+	UpdateGlobalSettingsHandler(rec, httptest.NewRequest("POST", "/api/settings", strings.NewReader(
+		`{"cors_policy": {"allow_origins": ["https://app.example.com"]}}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	current = config.GetGlobalConfig()
+	if current.CorsPolicy.AllowOrigins[0] != "https://app.example.com" || live.CorsPolicy.AllowOrigins[0] != "*" {
+		t.Errorf("Expected the update to replace the live settings, got %+v", current.CorsPolicy)
+	}
+	if current.SecurityHeaders["preset"] != "basic" || current.GlobalRateLimiting != 10000 {
+		t.Errorf("Expected fields missing from the update to be kept, got %+v", current)
+	}
+
+	rec = httptest.NewRecorder()
+	UpdateGlobalSettingsHandler(rec, httptest.NewRequest("POST", "/api/settings", strings.NewReader(
+		`{"security_headers": {"preset": "basic", "hsts": "max-age=600"}}`)))
+	if headers := config.GetGlobalConfig().SecurityHeaders; rec.Code != http.StatusOK || len(headers) != 2 {
+		t.Fatalf("Expected the hsts setting to be saved, got %d %v", rec.Code, headers)
+	}
+	rec = httptest.NewRecorder()
+	UpdateGlobalSettingsHandler(rec, httptest.NewRequest("POST", "/api/settings", strings.NewReader(
+		`{"security_headers": {"preset": "basic"}}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if headers := config.GetGlobalConfig().SecurityHeaders; len(headers) != 1 || headers["preset"] != "basic" {
+		t.Errorf("Expected the sent security headers to replace the old ones, got %v", headers)
+	}
+
+	rec = httptest.NewRecorder()
+	UpdateGlobalSettingsHandler(rec, httptest.NewRequest("POST", "/api/settings", strings.NewReader(`{"security_headers": {}}`)))
+	if headers := config.GetGlobalConfig().SecurityHeaders; rec.Code != http.StatusOK || len(headers) != 0 {
+		t.Errorf("Expected an empty object to turn the security headers off, got %d %v", rec.Code, headers)
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"zentro/internal/config"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type groupResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Members     []string `json:"members"`
+}
+
+func GetGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	consumerConfig, err := readConsumerConfig()
+	if err != nil {
+		http.Error(w, "Could not read consumers file", http.StatusInternalServerError)
+		return
+	}
+
+	groups := []groupResponse{}
+	for _, g := range consumerConfig.Groups {
+		members := []string{}
+		for _, c := range consumerConfig.Members(g.Name) {
+			members = append(members, c.Id)
+		}
+		groups = append(groups, groupResponse{Name: g.Name, Description: g.Description, Members: members})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groups)
+}
+
+func CreateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var group config.ConsumerGroup
+	if err := json.NewDecoder(r.Body).Decode(&group); err != nil || group.Name == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	consumerConfig, err := readConsumerConfig()
+	if err != nil {
+		http.Error(w, "Could not read consumers file", http.StatusInternalServerError)
+		return
+	}
+
+	if consumerConfig.HasGroup(group.Name) {
+		http.Error(w, "Group already exists", http.StatusConflict)
+		return
+	}
+	consumerConfig.Groups = append(consumerConfig.Groups, group)
+
+	if err := writeConsumerConfig(consumerConfig); err != nil {
+		http.Error(w, "Could not write consumers file", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(group)
+}
+
+// DeleteGroupHandler removes a group and drops it from every consumer.
+func DeleteGroupHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	consumerConfig, err := readConsumerConfig()
+	if err != nil {
+		http.Error(w, "Could not read consumers file", http.StatusInternalServerError)
+		return
+	}
+
+	if !consumerConfig.HasGroup(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	groups := consumerConfig.Groups[:0]
+	for _, g := range consumerConfig.Groups {
+		if g.Name != name {
+			groups = append(groups, g)
+		}
+	}
+	consumerConfig.Groups = groups
+
+	for i := range consumerConfig.Consumers {
+		consumerConfig.Consumers[i].Groups = without(consumerConfig.Consumers[i].Groups, name)
+	}
+
+	if err := writeConsumerConfig(consumerConfig); err != nil {
+		http.Error(w, "Could not write consumers file", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func AddGroupMemberHandler(w http.ResponseWriter, r *http.Request) {
+	updateGroupMembership(w, r, true)
+}
+
+func RemoveGroupMemberHandler(w http.ResponseWriter, r *http.Request) {
+	updateGroupMembership(w, r, false)
+}
+
+func updateGroupMembership(w http.ResponseWriter, r *http.Request, add bool) {
+	name := chi.URLParam(r, "name")
+	consumerID := chi.URLParam(r, "id")
+
+	consumerConfig, err := readConsumerConfig()
+	if err != nil {
+		http.Error(w, "Could not read consumers file", http.StatusInternalServerError)
+		return
+	}
+
+	if !consumerConfig.HasGroup(name) {
+		http.Error(w, "Group not found", http.StatusNotFound)
+		return
+	}
+
+	var consumer *config.Consumer
+	for i := range consumerConfig.Consumers {
+		if consumerConfig.Consumers[i].Id == consumerID {
+			consumer = &consumerConfig.Consumers[i]
+			break
+		}
+	}
+	if consumer == nil {
+		http.Error(w, "Consumer not found", http.StatusNotFound)
+		return
+	}
+
+	if add {
+		if !consumer.InGroup(name) {
+			consumer.Groups = append(consumer.Groups, name)
+		}
+	} else {
+		consumer.Groups = without(consumer.Groups, name)
+	}
+
+	if err := writeConsumerConfig(consumerConfig); err != nil {
+		http.Error(w, "Could not write consumers file", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redacted(*consumer))
+}
+
+func without(list []string, value string) []string {
+	out := []string{}
+	for _, v := range list {
+		if v != value {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"zentro/internal/config"
+	"zentro/internal/openapi"
+)
+
+type importResponse struct {
+	Applied bool `json:"applied"`
+	*openapi.Plan
+}
+
+// ImportOpenAPIHandler generates routes from the OpenAPI 3 document in the
+// body and reports how they differ from the routes file. The routes are
+// only written with apply=true.
+func ImportOpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	apply, _ := strconv.ParseBool(q.Get("apply"))
+	opts := openapi.Options{Name: q.Get("name"), Upstream: q.Get("upstream")}
+	opts.Validate, _ = strconv.ParseBool(q.Get("validate"))
+	opts.Prune, _ = strconv.ParseBool(q.Get("prune"))
+	opts.Mock, _ = strconv.ParseBool(q.Get("mock"))
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, 10<<20))
+	if err != nil {
+		http.Error(w, "Could not read document", http.StatusBadRequest)
+		return
+	}
+	doc, err := openapi.Parse(data)
+	if err != nil {
+		http.Error(w, "Invalid OpenAPI document: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	routePath := config.Gf.RoutesConfigPath
+	gatewayConfig, err := config.ReadRoutesFile(routePath)
+	if err != nil {
+		http.Error(w, "Could not read routes file", http.StatusInternalServerError)
+		return
+	}
+	plan, err := openapi.Import(gatewayConfig.Routes, doc, opts)
+	if err != nil {
+		http.Error(w, "Could not import document: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if apply && len(plan.Changes) > 0 {
+		gatewayConfig.Routes = plan.Routes
+		if err := config.SaveRoutesFile(routePath, gatewayConfig); err != nil {
+			http.Error(w, "Could not write routes file", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(importResponse{Applied: apply && len(plan.Changes) > 0, Plan: plan})
+}
@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"zentro/internal/config"
+	"zentro/internal/global"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type PlaygroundResponse struct {
@@ -31,3 +35,80 @@ func PlaygroundTestHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+type mockToggle struct {
+	Enabled bool `json:"enabled"`
+}
+
+// mockState reports whether a route has a Mock filter and whether mock
+// mode is on.
+func mockState(route config.Route) (mockable, enabled bool) {
+	for _, f := range route.Filters {
+		if f.Name != "Mock" {
+			continue
+		}
+		mockable = true
+		if on, ok := f.Settings["enabled"].(bool); !ok || on {
+			enabled = true
+		}
+	}
+	return mockable, enabled
+}
+
+// SetRouteMockHandler switches the Mock filters of a route on or off, so
+// the playground can try a route against its mocks or its upstream.
+func SetRouteMockHandler(w http.ResponseWriter, r *http.Request) {
+	routeID := chi.URLParam(r, "id")
+	var toggle mockToggle
+	if err := json.NewDecoder(r.Body).Decode(&toggle); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	routePath := config.Gf.RoutesConfigPath
+	gatewayConfig, err := config.ReadRoutesFile(routePath)
+	if err != nil {
+		http.Error(w, "Could not read routes file", http.StatusInternalServerError)
+		return
+	}
+
+	index := -1
+	for i, route := range global.GetConfig().Routes {
+		if route.ID == routeID {
+			index = i
+			break
+		}
+	}
+	if index < 0 || index >= len(gatewayConfig.Routes) {
+		http.NotFound(w, r)
+		return
+	}
+
+	route := &gatewayConfig.Routes[index]
+	if mockable, _ := mockState(*route); !mockable {
+		http.Error(w, "Route has no Mock filter", http.StatusConflict)
+		return
+	}
+	for i := range route.Filters {
+		if route.Filters[i].Name == "Mock" {
+			if route.Filters[i].Settings == nil {
+				route.Filters[i].Settings = map[string]interface{}{}
+			}
+			route.Filters[i].Settings["enabled"] = toggle.Enabled
+		}
+	}
+
+	if err := config.SaveRoutesFile(routePath, gatewayConfig); err != nil {
+		http.Error(w, "Could not write routes file", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PlaygroundRoute{
+		Id:         routeID,
+		Name:       route.Name,
+		PathPrefix: route.PathPrefix,
+		Mockable:   true,
+		Mock:       toggle.Enabled,
+	})
+}
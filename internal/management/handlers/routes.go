@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"zentro/internal/config"
+	"zentro/internal/filters"
 	"zentro/internal/global"
 
 	"github.com/go-chi/chi/v5"
@@ -23,6 +24,10 @@ func CreateRouteHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if err := filters.CheckSchemas(newRoute.Filters); err != nil {
+		http.Error(w, "Invalid schema: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	data, err := os.ReadFile(routePath)
 	if err != nil {
@@ -77,6 +82,10 @@ func UpdateRouteHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if err := filters.CheckSchemas(updatedRoute.Filters); err != nil {
+		http.Error(w, "Invalid schema: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	data, err := os.ReadFile(routePath)
 	if err != nil {
@@ -173,6 +182,8 @@ type PlaygroundRoute struct{
 	Id string `json:"id"`
 	Name string `json:"name"`
 	PathPrefix	string `json:"path_prefix"`
+	Mockable bool `json:"mockable"`
+	Mock bool `json:"mock"`
 }
 
 func PlaygroundRoutesHandler(w http.ResponseWriter, r *http.Request) {
@@ -184,6 +195,7 @@ func PlaygroundRoutesHandler(w http.ResponseWriter, r *http.Request) {
 			Name: r.Name,
 			PathPrefix: r.PathPrefix,
 		}
+		route.Mockable, route.Mock = mockState(r)
 		paths=append(paths,route)
 	}
 
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"zentro/internal/config"
+)
+
+func TestCreateRoute_RejectsInvalidSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.json")
+	os.WriteFile(path, []byte(`{"routes":[]}`), 0600)
+	defer func(gf *config.GatewayFlagOptions) { config.Gf = gf }(config.Gf)
+	config.Gf = &config.GatewayFlagOptions{RoutesConfigPath: path}
+
+	rec := httptest.NewRecorder()
+	CreateRouteHandler(rec, httptest.NewRequest("POST", "/routes", strings.NewReader(
+		`{"name":"orders","filters":[{"name":"SchemaValidation","settings":{"body":{"POST":{"type":12}}}}]}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid schema, got %d", rec.Code)
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"routes":[]}` {
+		t.Errorf("Expected the routes file to be left alone, got %s", data)
+	}
+}
@@ -66,6 +66,8 @@ func SetupRouter(uiFS fs.FS) *chi.Mux {
 		w.Write([]byte("OK"))
 	})
 
+	r.Get("/.well-known/jwks.json", handlers.JWKSHandler)
+
 	r.Post("/auth/login", handlers.LoginHandler)
 	r.Post("/auth/signup", handlers.SignupHandler)
 
@@ -74,11 +76,13 @@ func SetupRouter(uiFS fs.FS) *chi.Mux {
 
 		r.Get("/dashboard", handlers.DashboardHandler)
 		r.Get("/playground/routes", handlers.PlaygroundRoutesHandler)
+		r.Put("/playground/routes/{id}/mock", handlers.SetRouteMockHandler)
 		r.HandleFunc("/playground/test", handlers.PlaygroundTestHandler)
 
 		r.Route("/routes", func(r chi.Router) {
 			r.Get("/", handlers.GetRoutesHandler)
 			r.Post("/", handlers.CreateRouteHandler)
+			r.Post("/import", handlers.ImportOpenAPIHandler)
 			r.Get("/{id}", handlers.GetRouteHandler)
 			r.Put("/{id}", handlers.UpdateRouteHandler)
 			r.Delete("/{id}", handlers.DeleteRouteHandler)
@@ -89,10 +93,27 @@ func SetupRouter(uiFS fs.FS) *chi.Mux {
 			r.Post("/", handlers.CreateConsumerHandler)
 			r.Get("/{id}", handlers.GetConsumerHandler)
 			r.Delete("/{id}", handlers.DeleteConsumerHandler)
+
+			r.Put("/{id}/rate-limit", handlers.UpdateConsumerRateLimitHandler)
+
+			r.Get("/{id}/credentials", handlers.GetCredentialsHandler)
+			r.Post("/{id}/credentials", handlers.CreateCredentialHandler)
+			r.Post("/{id}/credentials/{credentialId}/rotate", handlers.RotateCredentialHandler)
+			r.Delete("/{id}/credentials/{credentialId}", handlers.RevokeCredentialHandler)
+		})
+
+		r.Route("/groups", func(r chi.Router) {
+			r.Get("/", handlers.GetGroupsHandler)
+			r.Post("/", handlers.CreateGroupHandler)
+			r.Delete("/{name}", handlers.DeleteGroupHandler)
+			r.Put("/{name}/members/{id}", handlers.AddGroupMemberHandler)
+			r.Delete("/{name}/members/{id}", handlers.RemoveGroupMemberHandler)
 		})
 
 		r.Get("/traffic-logs", handlers.GetTrafficLogsHandler)
 
+		r.Post("/cache/purge", handlers.PurgeCacheHandler)
+
 		r.Route("/config", func(r chi.Router) {
 			r.Get("/", handlers.GetConfigHandler)
 			r.Post("/", handlers.UpdateConfigHandler)
@@ -0,0 +1,191 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"zentro/internal/config"
+	"zentro/internal/filters"
+)
+
+// Change describes what an import does to one route.
+type Change struct {
+	// Action is "add", "update" or "remove".
+	Action string        `json:"action"`
+	Name   string        `json:"name"`
+	Fields []string      `json:"fields,omitempty"`
+	Before *config.Route `json:"before,omitempty"`
+	After  *config.Route `json:"after,omitempty"`
+}
+
+// Plan is the outcome of importing a document into a list of routes.
+type Plan struct {
+	Changes   []Change `json:"changes"`
+	Unchanged int      `json:"unchanged"`
+	Warnings  []string `json:"warnings,omitempty"`
+	// Routes is the full route list after the import.
+	Routes []config.Route `json:"-"`
+}
+
+// Import generates the document's routes and merges them into existing.
+// Routes from an earlier import of the same document are matched by name;
+// only their prefix, methods, upstreams and generated filters are replaced,
+// so settings added by hand survive a re-import.
+func Import(existing []config.Route, doc *Document, opts Options) (*Plan, error) {
+	generated, warnings, err := doc.Routes(opts)
+	if err != nil {
+		return nil, err
+	}
+	owned := doc.RouteName(opts) + ":"
+
+	previous := map[string]config.Route{}
+	insertAt := -1
+	var others []config.Route
+	for _, route := range existing {
+		if strings.HasPrefix(route.Name, owned) {
+			if insertAt < 0 {
+				insertAt = len(others)
+			}
+			previous[route.Name] = route
+			continue
+		}
+		others = append(others, route)
+	}
+	if insertAt < 0 {
+		insertAt = len(others)
+	}
+
+	plan := &Plan{Warnings: warnings}
+	var imported []config.Route
+	seen := map[string]bool{}
+	for _, route := range generated {
+		seen[route.Name] = true
+		before, ok := previous[route.Name]
+		if !ok {
+			r := route
+			plan.Changes = append(plan.Changes, Change{Action: "add", Name: route.Name, After: &r})
+			imported = append(imported, route)
+			continue
+		}
+		after := merge(before, route)
+		if fields := changedFields(before, after); len(fields) > 0 {
+			b, a := before, after
+			plan.Changes = append(plan.Changes, Change{Action: "update", Name: route.Name, Fields: fields, Before: &b, After: &a})
+		} else {
+			plan.Unchanged++
+		}
+		imported = append(imported, after)
+	}
+	for _, route := range existing {
+		if _, ok := previous[route.Name]; !ok || seen[route.Name] {
+			continue
+		}
+		if opts.Prune {
+			r := route
+			plan.Changes = append(plan.Changes, Change{Action: "remove", Name: route.Name, Before: &r})
+		} else {
+			imported = append(imported, route)
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("route %s is no longer in the document, prune to remove it", route.Name))
+		}
+	}
+
+	plan.Routes = append(append(append([]config.Route{}, others[:insertAt]...), imported...), others[insertAt:]...)
+	plan.Warnings = append(plan.Warnings, shadowed(plan.Routes, owned)...)
+
+	var routeFilters []filters.GenericFilter
+	for _, route := range imported {
+		routeFilters = append(routeFilters, route.Filters...)
+	}
+	if err := filters.CheckSchemas(routeFilters); err != nil {
+		return nil, fmt.Errorf("generated schema is invalid: %w", err)
+	}
+	return plan, nil
+}
+
+// generatedFilters are the filters an import owns on its routes.
+var generatedFilters = map[string]bool{"SchemaValidation": true, "Mock": true}
+
+// merge applies the imported fields of generated to an existing route. A
+// Mock filter keeps whether mock mode was switched on or off.
+func merge(existing, generated config.Route) config.Route {
+	merged := existing
+	merged.PathPrefix = generated.PathPrefix
+	merged.Methods = generated.Methods
+	merged.Upstreams = generated.Upstreams
+	merged.Filters = nil
+	var mockEnabled interface{}
+	for _, f := range existing.Filters {
+		if f.Name == "Mock" {
+			mockEnabled = f.Settings["enabled"]
+		}
+		if !generatedFilters[f.Name] {
+			merged.Filters = append(merged.Filters, f)
+		}
+	}
+	for _, f := range generated.Filters {
+		if f.Name == "Mock" && mockEnabled != nil {
+			f.Settings["enabled"] = mockEnabled
+		}
+		merged.Filters = append(merged.Filters, f)
+	}
+	return merged
+}
+
+func changedFields(before, after config.Route) []string {
+	var fields []string
+	if before.PathPrefix != after.PathPrefix {
+		fields = append(fields, "path_prefix")
+	}
+	if !reflect.DeepEqual(before.Methods, after.Methods) {
+		fields = append(fields, "methods")
+	}
+	if !reflect.DeepEqual(before.Upstreams, after.Upstreams) {
+		fields = append(fields, "upstreams")
+	}
+	if !sameJSON(before.Filters, after.Filters) {
+		fields = append(fields, "filters")
+	}
+	return fields
+}
+
+// sameJSON compares values as they would be saved, so that numbers read
+// from the routes file equal the ones generated.
+func sameJSON(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
+
+// shadowed warns about imported routes that an earlier route always
+// matches first.
+func shadowed(routes []config.Route, owned string) []string {
+	var warnings []string
+	for i, route := range routes {
+		if !strings.HasPrefix(route.Name, owned) {
+			continue
+		}
+		for _, earlier := range routes[:i] {
+			if !earlier.IsEnabled() || earlier.Host != "" || len(earlier.Headers) > 0 || len(earlier.QueryParams) > 0 {
+				continue
+			}
+			if !strings.HasPrefix(route.PathPrefix, earlier.PathPrefix) {
+				continue
+			}
+			if len(earlier.Methods) == 0 || covers(earlier.Methods, route.Methods) {
+				warnings = append(warnings, fmt.Sprintf("route %s is shadowed by %s", route.Name, earlier.Name))
+				break
+			}
+		}
+	}
+	return warnings
+}
+
+func covers(methods, subset []string) bool {
+	for _, m := range subset {
+		if !contains(methods, m) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package openapi
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	responseRefPrefix = "#/components/responses/"
+	exampleRefPrefix  = "#/components/examples/"
+)
+
+// mockResponse builds a Mock filter response from the example of the
+// operation's first successful response. It returns nil when the document
+// has no example to serve.
+func (d *Document) mockResponse(op operation) map[string]interface{} {
+	codes := make([]string, 0, len(op.op.Responses))
+	for code := range op.op.Responses {
+		if strings.HasPrefix(code, "2") && len(code) == 3 {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		response := op.op.Responses[code]
+		if response.Ref != "" {
+			resolved, ok := d.Components.Responses[strings.TrimPrefix(response.Ref, responseRefPrefix)]
+			if !ok {
+				continue
+			}
+			response = resolved
+		}
+		status, _ := strconv.Atoi(code)
+		mock := map[string]interface{}{
+			"match":  map[string]interface{}{"path": op.path, "methods": []interface{}{op.method}},
+			"status": status,
+		}
+		if len(response.Content) == 0 {
+			return mock
+		}
+
+		contentTypes := make([]string, 0, len(response.Content))
+		for contentType := range response.Content {
+			contentTypes = append(contentTypes, contentType)
+		}
+		// Prefer JSON, then the first type by name.
+		sort.Slice(contentTypes, func(i, j int) bool {
+			ji, jj := isJSON(contentTypes[i]), isJSON(contentTypes[j])
+			if ji != jj {
+				return ji
+			}
+			return contentTypes[i] < contentTypes[j]
+		})
+		for _, contentType := range contentTypes {
+			example, ok := d.example(response.Content[contentType])
+			if !ok {
+				continue
+			}
+			if s, isString := example.(string); isString && isJSON(contentType) {
+				// A string body is sent verbatim, so quote it to keep the
+				// response valid JSON.
+				example = strconv.Quote(s)
+			}
+			mock["headers"] = map[string]interface{}{"Content-Type": contentType}
+			mock["body"] = example
+			return mock
+		}
+	}
+	return nil
+}
+
+// example returns the media type's example, its first named example, or
+// the example of its schema.
+func (d *Document) example(media MediaType) (interface{}, bool) {
+	if media.Example != nil {
+		return media.Example, true
+	}
+	names := make([]string, 0, len(media.Examples))
+	for name := range media.Examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ex := media.Examples[name]
+		if ex.Ref != "" {
+			ex = d.Components.Examples[strings.TrimPrefix(ex.Ref, exampleRefPrefix)]
+		}
+		if ex.Value != nil {
+			return ex.Value, true
+		}
+	}
+	schema := media.Schema
+	if ref, ok := schema["$ref"].(string); ok {
+		schema, _ = d.Components.Schemas[strings.TrimPrefix(ref, schemaRefPrefix)].(map[string]interface{})
+	}
+	if ex, ok := schema["example"]; ok && ex != nil {
+		return ex, true
+	}
+	return nil, false
+}
+
+func isJSON(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// sortMocks orders mock responses so literal path segments are tried
+// before templated ones.
+func sortMocks(mocks []interface{}) {
+	path := func(i int) string {
+		match := mocks[i].(map[string]interface{})["match"].(map[string]interface{})
+		return match["path"].(string)
+	}
+	sort.SliceStable(mocks, func(i, j int) bool {
+		pi, pj := path(i), path(j)
+		if ti, tj := strings.Count(pi, "{"), strings.Count(pj, "{"); ti != tj {
+			return ti < tj
+		}
+		return pi < pj
+	})
+}
@@ -0,0 +1,370 @@
+// Package openapi turns OpenAPI 3 documents into gateway routes.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"zentro/internal/config"
+	"zentro/internal/filters"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is the part of an OpenAPI 3 document the importer reads.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Server struct {
+	URL       string                    `json:"url"`
+	Variables map[string]ServerVariable `json:"variables"`
+}
+
+type ServerVariable struct {
+	Default string `json:"default"`
+}
+
+type PathItem struct {
+	Parameters []Parameter `json:"parameters"`
+	Servers    []Server    `json:"servers"`
+	Get        *Operation  `json:"get"`
+	Put        *Operation  `json:"put"`
+	Post       *Operation  `json:"post"`
+	Delete     *Operation  `json:"delete"`
+	Options    *Operation  `json:"options"`
+	Head       *Operation  `json:"head"`
+	Patch      *Operation  `json:"patch"`
+	Trace      *Operation  `json:"trace"`
+}
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Parameters  []Parameter         `json:"parameters"`
+	RequestBody *RequestBody        `json:"requestBody"`
+	Responses   map[string]Response `json:"responses"`
+	Servers     []Server            `json:"servers"`
+}
+
+type Parameter struct {
+	Ref      string                 `json:"$ref"`
+	Name     string                 `json:"name"`
+	In       string                 `json:"in"`
+	Required bool                   `json:"required"`
+	Schema   map[string]interface{} `json:"schema"`
+}
+
+type RequestBody struct {
+	Ref      string               `json:"$ref"`
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref     string               `json:"$ref"`
+	Content map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema   map[string]interface{} `json:"schema"`
+	Example  interface{}            `json:"example"`
+	Examples map[string]Example     `json:"examples"`
+}
+
+type Example struct {
+	Ref   string      `json:"$ref"`
+	Value interface{} `json:"value"`
+}
+
+type Components struct {
+	Schemas       map[string]interface{} `json:"schemas"`
+	Parameters    map[string]Parameter   `json:"parameters"`
+	RequestBodies map[string]RequestBody `json:"requestBodies"`
+	Responses     map[string]Response    `json:"responses"`
+	Examples      map[string]Example     `json:"examples"`
+}
+
+// Options controls how routes are generated.
+type Options struct {
+	// Name prefixes the generated route names and identifies the routes
+	// of one document; it defaults to the document title.
+	Name string
+	// Upstream replaces the document's servers.
+	Upstream string
+	// Validate attaches a SchemaValidation filter built from the
+	// document's parameters and request bodies.
+	Validate bool
+	// Prune removes routes of the document that are no longer in it.
+	Prune bool
+	// Mock attaches a Mock filter answering with the document's response
+	// examples.
+	Mock bool
+}
+
+// Parse reads a JSON or YAML OpenAPI 3 document.
+func Parse(data []byte) (*Document, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("not a JSON or YAML document: %w", err)
+	}
+	// Round-trip through JSON so YAML and JSON documents decode alike.
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var doc Document
+	if err := json.Unmarshal(normalized, &doc); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q, expected 3.x", doc.OpenAPI)
+	}
+	if len(doc.Paths) == 0 {
+		return nil, fmt.Errorf("document has no paths")
+	}
+	return &doc, nil
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// RouteName returns the name prefix used for the document's routes.
+func (d *Document) RouteName(opts Options) string {
+	if opts.Name != "" {
+		return opts.Name
+	}
+	if slug := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(d.Info.Title), "-"), "-"); slug != "" {
+		return slug
+	}
+	return "openapi"
+}
+
+// operation pairs an operation with its method and inherited settings.
+type operation struct {
+	method     string
+	path       string
+	op         *Operation
+	parameters []Parameter
+	servers    []Server
+}
+
+func (p PathItem) operations(path string) []operation {
+	var ops []operation
+	for _, m := range []struct {
+		method string
+		op     *Operation
+	}{
+		{"GET", p.Get}, {"PUT", p.Put}, {"POST", p.Post}, {"DELETE", p.Delete},
+		{"OPTIONS", p.Options}, {"HEAD", p.Head}, {"PATCH", p.Patch}, {"TRACE", p.Trace},
+	} {
+		if m.op == nil {
+			continue
+		}
+		servers := p.Servers
+		if len(m.op.Servers) > 0 {
+			servers = m.op.Servers
+		}
+		ops = append(ops, operation{
+			method:     m.method,
+			path:       path,
+			op:         m.op,
+			parameters: mergeParameters(p.Parameters, m.op.Parameters),
+			servers:    servers,
+		})
+	}
+	return ops
+}
+
+// mergeParameters applies operation parameters over path parameters.
+func mergeParameters(pathParams, opParams []Parameter) []Parameter {
+	merged := append([]Parameter{}, pathParams...)
+	for _, p := range opParams {
+		replaced := false
+		for i, existing := range merged {
+			if existing.Name == p.Name && existing.In == p.In && p.Ref == "" && existing.Ref == "" {
+				merged[i], replaced = p, true
+			}
+		}
+		if !replaced {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+// staticPrefix is the part of an OpenAPI path before its first template,
+// which is what a route can match on.
+func staticPrefix(path string) string {
+	if i := strings.Index(path, "{"); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// Routes generates one route per distinct path prefix, ordered so that
+// more specific prefixes match first. Warnings describe what could not be
+// expressed as routes.
+func (d *Document) Routes(opts Options) ([]config.Route, []string, error) {
+	name := d.RouteName(opts)
+	var warnings []string
+
+	type group struct {
+		prefix     string
+		methods    []string
+		upstreams  []string
+		body       map[string]interface{}
+		query      map[string]interface{}
+		headers    map[string]interface{}
+		conflicted map[string]bool
+		mocks      []interface{}
+	}
+	groups := map[string]*group{}
+
+	paths := make([]string, 0, len(d.Paths))
+	for path := range d.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		for _, op := range d.Paths[path].operations(path) {
+			prefix := staticPrefix(path)
+			g, ok := groups[prefix]
+			if !ok {
+				g = &group{prefix: prefix, body: map[string]interface{}{}, query: map[string]interface{}{}, headers: map[string]interface{}{}, conflicted: map[string]bool{}}
+				groups[prefix] = g
+			}
+
+			upstreams, err := d.upstreams(op.servers, opts)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s %s: %w", op.method, path, err)
+			}
+			if g.upstreams == nil {
+				g.upstreams = upstreams
+			} else if strings.Join(g.upstreams, ",") != strings.Join(upstreams, ",") {
+				warnings = append(warnings, fmt.Sprintf("%s %s uses other servers than the rest of %s, using %s", op.method, path, prefix, strings.Join(g.upstreams, ", ")))
+			}
+
+			if opts.Mock {
+				// Mocks match the full path, so they can tell apart
+				// operations that share a route.
+				if mock := d.mockResponse(op); mock != nil {
+					g.mocks = append(g.mocks, mock)
+				} else {
+					warnings = append(warnings, fmt.Sprintf("%s %s has no response example to mock", op.method, path))
+				}
+			}
+
+			if contains(g.methods, op.method) {
+				// Two operations share the prefix and method; the gateway
+				// cannot tell them apart, so neither is validated.
+				g.conflicted[op.method] = true
+				if opts.Validate {
+					warnings = append(warnings, fmt.Sprintf("%s %s shares route %s with another %s operation, not validating %s requests", op.method, path, prefix, op.method, op.method))
+				}
+				continue
+			}
+			g.methods = append(g.methods, op.method)
+
+			if !opts.Validate {
+				continue
+			}
+			if schema, err := d.bodySchema(op.op.RequestBody); err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s %s: %v", op.method, path, err))
+			} else if schema != nil {
+				g.body[op.method] = schema
+			}
+			query, headers, paramWarnings := d.parameterSchemas(op.parameters)
+			for _, w := range paramWarnings {
+				warnings = append(warnings, fmt.Sprintf("%s %s: %s", op.method, path, w))
+			}
+			if query != nil {
+				g.query[op.method] = query
+			}
+			if headers != nil {
+				g.headers[op.method] = headers
+			}
+		}
+	}
+
+	routes := make([]config.Route, 0, len(groups))
+	for _, g := range groups {
+		sort.Strings(g.methods)
+		route := config.Route{
+			Name:       name + ":" + g.prefix,
+			PathPrefix: g.prefix,
+			Methods:    g.methods,
+			Upstreams:  g.upstreams,
+		}
+		settings := map[string]interface{}{}
+		for section, schemas := range map[string]map[string]interface{}{"body": g.body, "query": g.query, "headers": g.headers} {
+			for method := range g.conflicted {
+				delete(schemas, method)
+			}
+			if len(schemas) > 0 {
+				settings[section] = schemas
+			}
+		}
+		if len(settings) > 0 {
+			route.Filters = append(route.Filters, filters.GenericFilter{Name: "SchemaValidation", Settings: settings})
+		}
+		if len(g.mocks) > 0 {
+			sortMocks(g.mocks)
+			route.Filters = append(route.Filters, filters.GenericFilter{Name: "Mock", Settings: map[string]interface{}{"enabled": true, "responses": g.mocks}})
+		}
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if len(routes[i].PathPrefix) != len(routes[j].PathPrefix) {
+			return len(routes[i].PathPrefix) > len(routes[j].PathPrefix)
+		}
+		return routes[i].PathPrefix < routes[j].PathPrefix
+	})
+	return routes, warnings, nil
+}
+
+// upstreams resolves the servers of an operation to absolute URLs.
+func (d *Document) upstreams(servers []Server, opts Options) ([]string, error) {
+	if opts.Upstream != "" {
+		return []string{opts.Upstream}, nil
+	}
+	if len(servers) == 0 {
+		servers = d.Servers
+	}
+	var upstreams []string
+	for _, s := range servers {
+		raw := s.URL
+		for name, v := range s.Variables {
+			raw = strings.ReplaceAll(raw, "{"+name+"}", v.Default)
+		}
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			continue
+		}
+		upstreams = append(upstreams, strings.TrimSuffix(u.String(), "/"))
+	}
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("no absolute server URL, set an upstream")
+	}
+	return upstreams, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
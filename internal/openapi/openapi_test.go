@@ -0,0 +1,166 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"zentro/internal/config"
+	"zentro/internal/filters"
+)
+
+const petstore = `
+openapi: 3.0.3
+info:
+  title: Pet Store
+  version: "1.0"
+servers:
+  - url: https://{env}.pets.example.com/v1
+    variables:
+      env:
+        default: api
+paths:
+  /pets:
+    get:
+      parameters:
+        - name: limit
+          in: query
+          schema: {type: integer}
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/Pet"}
+  /pets/{id}:
+    get:
+      responses:
+        "200":
+          content:
+            application/json:
+              example: {name: rex}
+    delete:
+      responses:
+        "204": {description: deleted}
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string}
+        tag: {type: string, nullable: true}
+`
+
+func TestImport_GeneratesRoutes(t *testing.T) {
+	doc, err := Parse([]byte(petstore))
+	if err != nil {
+		t.Fatalf("Expected document to parse: %v", err)
+	}
+	plan, err := Import(nil, doc, Options{Validate: true})
+	if err != nil {
+		t.Fatalf("Expected import to succeed: %v", err)
+	}
+	if len(plan.Changes) != 2 || len(plan.Routes) != 2 {
+		t.Fatalf("Expected 2 new routes, got %+v", plan.Changes)
+	}
+
+	// The more specific prefix must come first.
+	item, list := plan.Routes[0], plan.Routes[1]
+	if item.Name != "pet-store:/pets/" || list.Name != "pet-store:/pets" {
+		t.Errorf("Unexpected routes %s, %s", item.Name, list.Name)
+	}
+	if len(list.Upstreams) != 1 || list.Upstreams[0] != "https://api.pets.example.com/v1" {
+		t.Errorf("Expected upstream from servers, got %v", list.Upstreams)
+	}
+	if len(list.Methods) != 2 || list.Methods[0] != "GET" || list.Methods[1] != "POST" {
+		t.Errorf("Expected GET and POST, got %v", list.Methods)
+	}
+	if len(list.Filters) != 1 || list.Filters[0].Name != "SchemaValidation" {
+		t.Fatalf("Expected a SchemaValidation filter, got %v", list.Filters)
+	}
+
+	// Validate the generated schemas the way the gateway would load them.
+	data, _ := json.Marshal(list.Filters[0])
+	var generic filters.GenericFilter
+	json.Unmarshal(data, &generic)
+	var filter filters.SchemaValidationFilter
+	filter.Convert(generic)
+	for body, valid := range map[string]bool{`{"name":"rex","tag":null}`: true, `{"tag":"x"}`: false} {
+		var v interface{}
+		json.Unmarshal([]byte(body), &v)
+		if err := filter.Settings.Body["POST"].Validate(v); (err == nil) != valid {
+			t.Errorf("Expected %s valid=%v, got %v", body, valid, err)
+		}
+	}
+	if err := filter.Settings.Query["GET"].Validate(map[string]interface{}{"limit": "ten"}); err == nil {
+		t.Errorf("Expected a non-numeric limit to be rejected")
+	}
+}
+
+func TestImport_DiffKeepsManualSettings(t *testing.T) {
+	doc, _ := Parse([]byte(petstore))
+	existing := []config.Route{
+		{Name: "other", PathPrefix: "/admin", Upstreams: []string{"http://admin"}},
+		{
+			Name:       "pet-store:/pets",
+			PathPrefix: "/pets",
+			Methods:    []string{"GET"},
+			Upstreams:  []string{"https://api.pets.example.com/v1"},
+			Auth:       config.Auth{Enabled: true, Type: "bearer"},
+			Filters:    []filters.GenericFilter{{Name: "Logging"}},
+		},
+		{Name: "pet-store:/old", PathPrefix: "/old", Upstreams: []string{"http://old"}},
+	}
+
+	plan, err := Import(existing, doc, Options{Prune: true})
+	if err != nil {
+		t.Fatalf("Expected import to succeed: %v", err)
+	}
+	actions := map[string]string{}
+	for _, c := range plan.Changes {
+		actions[c.Name] = c.Action
+	}
+	want := map[string]string{"pet-store:/pets/": "add", "pet-store:/pets": "update", "pet-store:/old": "remove"}
+	for name, action := range want {
+		if actions[name] != action {
+			t.Errorf("Expected %s to be %s, got %q", name, action, actions[name])
+		}
+	}
+
+	if len(plan.Routes) != 3 || plan.Routes[0].Name != "other" {
+		t.Fatalf("Expected other routes to keep their place, got %d routes", len(plan.Routes))
+	}
+	updated := plan.Routes[2]
+	if !updated.Auth.Enabled || len(updated.Filters) != 1 || updated.Filters[0].Name != "Logging" {
+		t.Errorf("Expected auth and filters to survive the import, got %+v", updated)
+	}
+}
+
+func TestImport_Mocks(t *testing.T) {
+	doc, _ := Parse([]byte(petstore))
+	plan, err := Import(nil, doc, Options{Mock: true})
+	if err != nil {
+		t.Fatalf("Expected import to succeed: %v", err)
+	}
+	item := plan.Routes[0]
+	if len(item.Filters) != 1 || item.Filters[0].Name != "Mock" {
+		t.Fatalf("Expected a Mock filter, got %v", item.Filters)
+	}
+	responses := item.Filters[0].Settings["responses"].([]interface{})
+	if len(responses) != 2 {
+		t.Fatalf("Expected 2 mocked operations, got %v", responses)
+	}
+	get := responses[0].(map[string]interface{})
+	if get["status"] != 200 || get["match"].(map[string]interface{})["path"] != "/pets/{id}" {
+		t.Errorf("Unexpected mock %v", get)
+	}
+
+	// Switching mock mode off survives a re-import.
+	item.Filters[0].Settings["enabled"] = false
+	plan, _ = Import(plan.Routes, doc, Options{Mock: true})
+	if plan.Routes[0].Filters[0].Settings["enabled"] != false {
+		t.Errorf("Expected mock mode to stay off")
+	}
+	if len(plan.Warnings) != 2 {
+		t.Errorf("Expected warnings for the operations without examples, got %v", plan.Warnings)
+	}
+}
@@ -0,0 +1,191 @@
+package openapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	schemaRefPrefix    = "#/components/schemas/"
+	parameterRefPrefix = "#/components/parameters/"
+	bodyRefPrefix      = "#/components/requestBodies/"
+)
+
+// bodySchema returns the JSON Schema of a JSON request body, or nil when the
+// operation takes no JSON body.
+func (d *Document) bodySchema(body *RequestBody) (map[string]interface{}, error) {
+	if body == nil {
+		return nil, nil
+	}
+	if body.Ref != "" {
+		resolved, ok := d.Components.RequestBodies[strings.TrimPrefix(body.Ref, bodyRefPrefix)]
+		if !ok {
+			return nil, fmt.Errorf("unresolved request body %s", body.Ref)
+		}
+		body = &resolved
+	}
+	var media *MediaType
+	for contentType, m := range body.Content {
+		mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+		if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+			m := m
+			media = &m
+			break
+		}
+	}
+	if media == nil || media.Schema == nil {
+		return nil, nil
+	}
+
+	schema := d.convert(media.Schema).(map[string]interface{})
+	if !body.Required {
+		schema = map[string]interface{}{"anyOf": []interface{}{map[string]interface{}{"type": "null"}, schema}}
+	}
+	return d.withDefs(schema), nil
+}
+
+// parameterSchemas builds object schemas for the query and header
+// parameters. Values arrive as strings, so non-string types are checked by
+// their textual form only.
+func (d *Document) parameterSchemas(params []Parameter) (query, headers map[string]interface{}, warnings []string) {
+	sections := map[string]map[string]interface{}{}
+	for _, p := range params {
+		if p.Ref != "" {
+			resolved, ok := d.Components.Parameters[strings.TrimPrefix(p.Ref, parameterRefPrefix)]
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("unresolved parameter %s", p.Ref))
+				continue
+			}
+			p = resolved
+		}
+		name := p.Name
+		switch p.In {
+		case "query":
+		case "header":
+			name = strings.ToLower(name)
+			// OpenAPI ignores these; they are described elsewhere.
+			if name == "accept" || name == "content-type" || name == "authorization" {
+				continue
+			}
+		default:
+			continue
+		}
+		section, ok := sections[p.In]
+		if !ok {
+			section = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+			sections[p.In] = section
+		}
+		section["properties"].(map[string]interface{})[name] = d.stringForm(p.Schema)
+		if p.Required {
+			required, _ := section["required"].([]interface{})
+			section["required"] = append(required, name)
+		}
+	}
+	if s, ok := sections["query"]; ok {
+		query = d.withDefs(s)
+	}
+	if s, ok := sections["header"]; ok {
+		headers = d.withDefs(s)
+	}
+	return query, headers, warnings
+}
+
+// stringForm adapts a parameter schema to the string the gateway sees.
+func (d *Document) stringForm(schema map[string]interface{}) interface{} {
+	if schema == nil {
+		return map[string]interface{}{}
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		if resolved, ok := d.Components.Schemas[strings.TrimPrefix(ref, schemaRefPrefix)].(map[string]interface{}); ok {
+			schema = resolved
+		}
+	}
+	switch schema["type"] {
+	case "integer":
+		return map[string]interface{}{"type": "string", "pattern": `^-?[0-9]+$`}
+	case "number":
+		return map[string]interface{}{"type": "string", "pattern": `^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`}
+	case "boolean":
+		return map[string]interface{}{"enum": []interface{}{"true", "false"}}
+	case "array", "object":
+		// Serialization styles vary too much to check here.
+		return map[string]interface{}{}
+	default:
+		return d.convert(schema)
+	}
+}
+
+// convert copies an OpenAPI schema into JSON Schema: component references
+// point at $defs, and OpenAPI 3.0's nullable becomes a null type.
+func (d *Document) convert(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			if k == "$ref" {
+				if ref, ok := val.(string); ok && strings.HasPrefix(ref, schemaRefPrefix) {
+					val = "#/$defs/" + strings.TrimPrefix(ref, schemaRefPrefix)
+				}
+			}
+			out[k] = d.convert(val)
+		}
+		if nullable, _ := out["nullable"].(bool); nullable {
+			if typ, ok := out["type"].(string); ok {
+				out["type"] = []interface{}{typ, "null"}
+			}
+		}
+		delete(out, "nullable")
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			out[i] = d.convert(val)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// withDefs adds the component schemas that schema refers to, directly or
+// through other components, as $defs.
+func (d *Document) withDefs(schema map[string]interface{}) map[string]interface{} {
+	defs := map[string]interface{}{}
+	pending := refsIn(schema, nil)
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if _, done := defs[name]; done {
+			continue
+		}
+		component, ok := d.Components.Schemas[name]
+		if !ok {
+			continue
+		}
+		converted := d.convert(component)
+		defs[name] = converted
+		pending = refsIn(converted, pending)
+	}
+	if len(defs) > 0 {
+		schema["$defs"] = defs
+	}
+	return schema
+}
+
+// refsIn appends the names of the $defs that v refers to.
+func refsIn(v interface{}, names []string) []string {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := t["$ref"].(string); ok && strings.HasPrefix(ref, "#/$defs/") {
+			names = append(names, strings.TrimPrefix(ref, "#/$defs/"))
+		}
+		for _, val := range t {
+			names = refsIn(val, names)
+		}
+	case []interface{}:
+		for _, val := range t {
+			names = refsIn(val, names)
+		}
+	}
+	return names
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"zentro/internal/config"
+	"zentro/internal/filters"
+	"zentro/internal/lb"
+)
+
+func TestReverseProxy_StreamedBodyLimits(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer upstream.Close()
+	config.Init("test")
+
+	rp, err := NewReverseProxy(upstream.URL, lb.New([]string{upstream.URL}, 0, 0))
+	if err != nil {
+		t.Fatalf("Expected proxy: %v", err)
+	}
+	size := filters.RequestSizeFilter{Settings: filters.RequestSizeSettings{MaxSize: 16}}
+	handler := size.Apply(rp)
+
+	// A chunked body has no Content-Length to check up front.
+	req := httptest.NewRequest("POST", "/upload", io.NopCloser(strings.NewReader(strings.Repeat("x", 64))))
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a chunked body over the limit, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/upload", strings.NewReader(`{"a":[[[1]]]}`))
+	req.Header.Set("Content-Type", "application/json")
+	filters.LimitJSONDepth(req, 2, 0)
+	rec = httptest.NewRecorder()
+	rp.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for JSON nested too deep, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"zentro/internal/clientip"
+	"zentro/internal/config"
+	"zentro/internal/filters"
+)
+
+var xForwardedHeaders = []string{"X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host", "X-Forwarded-Port", "X-Forwarded-Prefix"}
+
+// setForwardedHeaders applies the forwarding policy to an outgoing request.
+// host is the Host the client asked for. Disabled header families are
+// removed. The ReverseProxy appends the peer to X-Forwarded-For itself, so
+// only the kept chain is left there.
+func setForwardedHeaders(out *http.Request, host string, policy config.ForwardedPolicy, trusted clientip.Ranges) {
+	keep := false
+	switch policy.Mode {
+	case "append":
+		keep = true
+	case "overwrite":
+	default:
+		peer, err := netip.ParseAddrPort(out.RemoteAddr)
+		keep = err == nil && trusted.Contains(peer.Addr())
+	}
+
+	proto := "http"
+	if out.TLS != nil {
+		proto = "https"
+	}
+	port := forwardedPort(out, host, proto)
+
+	if policy.Forwarded {
+		element := "for=" + forwardedNode(out.RemoteAddr) + ";host=" + quote(host) + ";proto=" + proto
+		if prior := out.Header.Values("Forwarded"); keep && len(prior) > 0 {
+			element = strings.Join(prior, ", ") + ", " + element
+		}
+		out.Header.Set("Forwarded", element)
+	} else {
+		out.Header.Del("Forwarded")
+	}
+
+	if !policy.XForwarded {
+		for _, name := range xForwardedHeaders {
+			out.Header.Del(name)
+		}
+		// A nil value stops the ReverseProxy adding the peer.
+		out.Header["X-Forwarded-For"] = nil
+		return
+	}
+
+	prefix := filters.ForwardedPrefix(out.Context())
+	if !keep {
+		for _, name := range xForwardedHeaders {
+			out.Header.Del(name)
+		}
+	} else if prior := out.Header.Get("X-Forwarded-Prefix"); prior != "" {
+		prefix = strings.TrimSuffix(prior, "/") + prefix
+	}
+	// The original request is described by the first proxy that saw it,
+	// so kept values win.
+	setIfMissing(out.Header, "X-Forwarded-Proto", proto)
+	setIfMissing(out.Header, "X-Forwarded-Host", host)
+	setIfMissing(out.Header, "X-Forwarded-Port", port)
+	if prefix != "" {
+		out.Header.Set("X-Forwarded-Prefix", prefix)
+	}
+}
+
+func setIfMissing(h http.Header, name, value string) {
+	if h.Get(name) == "" {
+		h.Set(name, value)
+	}
+}
+
+// forwardedPort is the port the client connected to: the one in the Host
+// header, else the listener's, else the scheme default.
+func forwardedPort(r *http.Request, host, proto string) string {
+	if _, port, err := net.SplitHostPort(host); err == nil {
+		return port
+	}
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		if _, port, err := net.SplitHostPort(addr.String()); err == nil {
+			return port
+		}
+	}
+	if proto == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// forwardedNode renders an address as a Forwarded node, quoting IPv6.
+func forwardedNode(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	if strings.Contains(host, ":") {
+		return `"[` + host + `]"`
+	}
+	return host
+}
+
+// quote quotes a Forwarded value unless it is a plain token.
+func quote(value string) string {
+	if strings.ContainsAny(value, `:[]";, `) {
+		return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+	}
+	return value
+}
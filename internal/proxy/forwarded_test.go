@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"zentro/internal/config"
+	"zentro/internal/filters"
+	"zentro/internal/lb"
+)
+
+func TestReverseProxy_ForwardedHeaders(t *testing.T) {
+	var got *http.Request
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+	defer upstream.Close()
+
+	config.Init("test")
+	settings := *config.GetGlobalConfig()
+	settings.TrustedProxies = []string{"10.0.0.0/8"}
+	settings.ForwardedHeaders.Forwarded = true
+	config.UpdateGlobalConfig(&settings)
+
+	rp, err := NewReverseProxy(upstream.URL, lb.New([]string{upstream.URL}, 0, 0))
+	if err != nil {
+		t.Fatalf("Expected proxy: %v", err)
+	}
+	strip := filters.StripPrefixFilter{Settings: filters.StripPrefixSettings{Prefix: "/api"}}
+	handler := strip.Apply(rp)
+
+	// An untrusted client cannot inject its own chain.
+	req := httptest.NewRequest("GET", "http://gateway.example.com/api/users", nil)
+	req.RemoteAddr = "203.0.113.9:5000"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	req.Header.Set("X-Forwarded-Host", "evil.example.com")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	want := map[string]string{
+		"X-Forwarded-For":    "203.0.113.9",
+		"X-Forwarded-Host":   "gateway.example.com",
+		"X-Forwarded-Proto":  "http",
+		"X-Forwarded-Port":   "80",
+		"X-Forwarded-Prefix": "/api",
+		"Forwarded":          "for=203.0.113.9;host=gateway.example.com;proto=http",
+	}
+	for name, value := range want {
+		if got.Header.Get(name) != value {
+			t.Errorf("Expected %s %q, got %q", name, value, got.Header.Get(name))
+		}
+	}
+	if got.Host == "gateway.example.com" || got.URL.Path != "/users" {
+		t.Errorf("Expected the upstream host and stripped path, got %s %s", got.Host, got.URL.Path)
+	}
+
+	// A trusted proxy's chain is kept and extended.
+	req = httptest.NewRequest("GET", "http://gateway.example.com/api/users", nil)
+	req.RemoteAddr = "10.0.0.2:5000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	filters.PreserveHostHeaderFilter{}.Apply(handler).ServeHTTP(httptest.NewRecorder(), req)
+	if xff := got.Header.Get("X-Forwarded-For"); xff != "198.51.100.7, 10.0.0.2" {
+		t.Errorf("Expected the chain to be extended, got %q", xff)
+	}
+	if got.Header.Get("X-Forwarded-Proto") != "https" {
+		t.Errorf("Expected the original scheme to be kept")
+	}
+	if got.Host != "gateway.example.com" {
+		t.Errorf("Expected PreserveHostHeader to keep the client's Host, got %s", got.Host)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"zentro/internal/config"
+	"zentro/internal/filters"
 	"zentro/internal/lb"
 )
 
@@ -19,8 +21,16 @@ func NewReverseProxy(target string,lb *lb.LoadBalancer) (*httputil.ReverseProxy,
    
     originalDirector := proxy.Director
     proxy.Director = func(req *http.Request) {
+        host := req.Host
         originalDirector(req)
 
+        if !filters.PreserveHost(req.Context()) {
+            req.Host = upstreamURL.Host
+        }
+        if settings := config.GetGlobalConfig(); settings != nil {
+            setForwardedHeaders(req, host, settings.ForwardedHeaders, config.TrustedProxyRanges())
+        }
+
         req.Header.Set("X-Zentro-Proxy", "true")
         log.Printf("Proxying %s %s -> %s", req.Method, req.URL.Path, upstreamURL)
     }
@@ -32,6 +42,12 @@ func NewReverseProxy(target string,lb *lb.LoadBalancer) (*httputil.ReverseProxy,
     }
 
     proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+        // A request body over a gateway limit is the client's fault.
+        if status, ok := filters.BodyErrorStatus(err); ok {
+            log.Printf("Request body rejected: %v", err)
+            http.Error(w, err.Error(), status)
+            return
+        }
         lb.Failure(target)
         log.Printf("Proxy error: %v", err)
         http.Error(w, "Bad gateway", http.StatusBadGateway)
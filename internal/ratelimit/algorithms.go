@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// state is the per-key bookkeeping of one algorithm.
+type state interface {
+	take(rule Rule, now time.Time) Decision
+}
+
+func newState(rule Rule, now time.Time) state {
+	switch rule.Algorithm {
+	case TokenBucket:
+		return &tokenBucket{tokens: float64(rule.capacity()), last: now}
+	case SlidingLog:
+		return &slidingLog{}
+	case SlidingWindow:
+		return &slidingWindow{start: now.Truncate(rule.Window)}
+	default:
+		return &fixedWindow{start: now}
+	}
+}
+
+// fixedWindow counts requests in consecutive windows starting at the first
+// request of each window.
+type fixedWindow struct {
+	start time.Time
+	count int
+}
+
+func (s *fixedWindow) take(rule Rule, now time.Time) Decision {
+	if now.Sub(s.start) >= rule.Window {
+		s.start = now
+		s.count = 0
+	}
+	reset := s.start.Add(rule.Window).Sub(now)
+	if s.count >= rule.Limit {
+		return Decision{Limit: rule.Limit, Reset: reset, RetryAfter: reset}
+	}
+	s.count++
+	return Decision{Allowed: true, Limit: rule.Limit, Remaining: rule.Limit - s.count, Reset: reset}
+}
+
+// tokenBucket refills Limit tokens per Window up to Burst, allowing short
+// bursts while enforcing the average rate.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (s *tokenBucket) take(rule Rule, now time.Time) Decision {
+	capacity := float64(rule.capacity())
+	rate := float64(rule.Limit) / rule.Window.Seconds()
+
+	if elapsed := now.Sub(s.last).Seconds(); elapsed > 0 {
+		s.tokens = math.Min(capacity, s.tokens+elapsed*rate)
+		s.last = now
+	}
+
+	d := Decision{Limit: rule.capacity()}
+	if s.tokens >= 1 {
+		s.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - s.tokens) / rate)
+	}
+	d.Remaining = int(s.tokens)
+	d.Reset = seconds((capacity - s.tokens) / rate)
+	return d
+}
+
+// slidingLog remembers the time of every accepted request in the last
+// window. It is exact but uses memory proportional to Limit.
+type slidingLog struct {
+	times []time.Time
+}
+
+func (s *slidingLog) take(rule Rule, now time.Time) Decision {
+	cutoff := now.Add(-rule.Window)
+	i := 0
+	for i < len(s.times) && !s.times[i].After(cutoff) {
+		i++
+	}
+	s.times = s.times[i:]
+
+	d := Decision{Limit: rule.Limit}
+	if len(s.times) < rule.Limit {
+		s.times = append(s.times, now)
+		d.Allowed = true
+	} else {
+		d.RetryAfter = s.times[0].Add(rule.Window).Sub(now)
+	}
+	d.Remaining = rule.Limit - len(s.times)
+	d.Reset = s.times[len(s.times)-1].Add(rule.Window).Sub(now)
+	return d
+}
+
+// slidingWindow approximates a sliding log by weighting the previous fixed
+// window's count by how much of it still overlaps the sliding window.
+type slidingWindow struct {
+	start    time.Time
+	previous int
+	current  int
+}
+
+func (s *slidingWindow) take(rule Rule, now time.Time) Decision {
+	if elapsed := now.Sub(s.start); elapsed >= rule.Window {
+		if elapsed >= 2*rule.Window {
+			s.previous = 0
+		} else {
+			s.previous = s.current
+		}
+		s.current = 0
+		s.start = now.Truncate(rule.Window)
+	}
+
+	overlap := 1 - float64(now.Sub(s.start))/float64(rule.Window)
+	estimate := float64(s.previous)*overlap + float64(s.current)
+	reset := s.start.Add(rule.Window).Sub(now)
+
+	d := Decision{Limit: rule.Limit, Reset: reset}
+	if estimate+1 > float64(rule.Limit) {
+		d.RetryAfter = s.retryAfter(rule, now, estimate)
+		return d
+	}
+	s.current++
+	d.Allowed = true
+	d.Remaining = int(float64(rule.Limit) - (estimate + 1))
+	return d
+}
+
+// retryAfter estimates when the previous window's weight has decayed enough
+// to admit one more request.
+func (s *slidingWindow) retryAfter(rule Rule, now time.Time, estimate float64) time.Duration {
+	if s.previous == 0 {
+		return s.start.Add(rule.Window).Sub(now)
+	}
+	excess := estimate + 1 - float64(rule.Limit)
+	wait := seconds(excess / float64(s.previous) * rule.Window.Seconds())
+	if end := s.start.Add(rule.Window).Sub(now); wait > end {
+		return end
+	}
+	return wait
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
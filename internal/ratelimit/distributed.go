@@ -0,0 +1,184 @@
+package ratelimit
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Failure modes for a FailoverStore when the shared store is unreachable.
+const (
+	// FailOpen lets every request through.
+	FailOpen = "open"
+	// FailClosed rejects every request with ErrUnavailable.
+	FailClosed = "closed"
+	// FailLocal enforces the limits per process until the store recovers.
+	FailLocal = "local"
+)
+
+// BatchingStore reserves several permits from a RedisStore in one round trip
+// and hands them out locally. This trades a little accuracy (unused permits
+// expire with their lease) for far fewer round trips on hot keys.
+type BatchingStore struct {
+	remote *RedisStore
+	size   int
+	maxAge time.Duration
+
+	mu     sync.Mutex
+	leases map[string]*lease
+	// refills holds the round trips in flight, one per lease key, so
+	// requests for a key wait for its refill while other keys go on.
+	refills map[string]*refill
+}
+
+type refill struct {
+	done chan struct{}
+	err  error
+}
+
+type lease struct {
+	permits  int
+	decision Decision
+	expires  time.Time
+}
+
+// maxLeases bounds the lease table; expired leases are pruned past it.
+const maxLeases = 10000
+
+// NewBatchingStore reserves up to size permits per round trip. Leases, and
+// cached rejections, are kept for at most maxAge.
+func NewBatchingStore(remote *RedisStore, size int, maxAge time.Duration) *BatchingStore {
+	if maxAge <= 0 {
+		maxAge = time.Second
+	}
+	return &BatchingStore{remote: remote, size: size, maxAge: maxAge, leases: make(map[string]*lease), refills: make(map[string]*refill)}
+}
+
+func (s *BatchingStore) Take(key string, rule Rule, now time.Time) (Decision, error) {
+	if s.size <= 1 {
+		return s.remote.Take(key, rule, now)
+	}
+	// Leases are only valid for the rule they were taken under.
+	leaseKey := rule.Algorithm + ":" + key
+
+	for {
+		s.mu.Lock()
+		if l, ok := s.leases[leaseKey]; ok && now.Before(l.expires) && (l.permits > 0 || !l.decision.Allowed) {
+			d := l.consume(now)
+			s.mu.Unlock()
+			return d, nil
+		}
+		if pending, ok := s.refills[leaseKey]; ok {
+			s.mu.Unlock()
+			<-pending.done
+			if pending.err != nil {
+				return Decision{}, pending.err
+			}
+			continue
+		}
+		pending := &refill{done: make(chan struct{})}
+		s.refills[leaseKey] = pending
+		s.mu.Unlock()
+
+		d, err := s.refill(leaseKey, key, rule, now, pending)
+		close(pending.done)
+		return d, err
+	}
+}
+
+// refill reserves a new lease for leaseKey from the remote store. The round
+// trip runs without s.mu held; the lock is only taken to install the lease.
+func (s *BatchingStore) refill(leaseKey, key string, rule Rule, now time.Time, pending *refill) (Decision, error) {
+	d, granted, err := s.remote.takeN(key, rule, now, s.size)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.refills, leaseKey)
+	if err != nil {
+		pending.err = err
+		delete(s.leases, leaseKey)
+		return Decision{}, err
+	}
+
+	age := s.maxAge
+	if d.Allowed && d.Reset > 0 && d.Reset < age {
+		age = d.Reset
+	}
+	if !d.Allowed && d.RetryAfter < age {
+		age = d.RetryAfter
+	}
+	l := &lease{permits: granted, decision: d, expires: now.Add(age)}
+	// The decision reported the state after all granted permits were taken;
+	// report the unused ones as still remaining.
+	l.decision.Remaining += granted
+	s.leases[leaseKey] = l
+	if len(s.leases) > maxLeases {
+		s.prune(now)
+	}
+	return l.consume(now), nil
+}
+
+func (l *lease) consume(now time.Time) Decision {
+	d := l.decision
+	if l.permits > 0 {
+		l.permits--
+		l.decision.Remaining--
+		d.Remaining--
+		d.Allowed = true
+		d.RetryAfter = 0
+		return d
+	}
+	d.Allowed = false
+	if d.RetryAfter = l.expires.Sub(now); d.RetryAfter < 0 {
+		d.RetryAfter = 0
+	}
+	return d
+}
+
+func (s *BatchingStore) prune(now time.Time) {
+	for k, l := range s.leases {
+		if !now.Before(l.expires) {
+			delete(s.leases, k)
+		}
+	}
+}
+
+// FailoverStore guards a shared store. While the store is failing it is only
+// retried after a short backoff, and requests are handled per Mode.
+type FailoverStore struct {
+	Primary Store
+	// Local is used in FailLocal mode.
+	Local   Store
+	Mode    string
+	Backoff time.Duration
+
+	retryAt atomic.Int64
+}
+
+func (s *FailoverStore) Take(key string, rule Rule, now time.Time) (Decision, error) {
+	if now.UnixNano() >= s.retryAt.Load() {
+		d, err := s.Primary.Take(key, rule, now)
+		if err == nil || !errors.Is(err, ErrUnavailable) {
+			return d, err
+		}
+		backoff := s.Backoff
+		if backoff <= 0 {
+			backoff = time.Second
+		}
+		if s.retryAt.Swap(now.Add(backoff).UnixNano()) <= now.UnixNano() {
+			log.Printf("❌ Rate limit store failing, using %q mode: %v", s.Mode, err)
+		}
+	}
+
+	switch s.Mode {
+	case FailClosed:
+		return Decision{Limit: rule.capacity(), RetryAfter: s.Backoff}, ErrUnavailable
+	case FailLocal:
+		if s.Local != nil {
+			return s.Local.Take(key, rule, now)
+		}
+	}
+	return Decision{Allowed: true, Limit: rule.capacity(), Remaining: rule.capacity()}, nil
+}
@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultMaxKeys bounds how many keys a MemoryStore tracks before it starts
+// dropping the least recently used ones.
+const DefaultMaxKeys = 100000
+
+// MemoryStore keeps rate limit state in process memory. It holds at most
+// maxKeys entries and forgets keys that have been idle long enough that their
+// state would have reset anyway.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	maxKeys int
+	stop    chan struct{}
+}
+
+type memoryEntry struct {
+	key       string
+	algorithm string
+	state     state
+	lastSeen  time.Time
+	idleAfter time.Duration
+}
+
+func NewMemoryStore(maxKeys int) *MemoryStore {
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxKeys
+	}
+	return &MemoryStore{
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		maxKeys: maxKeys,
+	}
+}
+
+func (s *MemoryStore) Take(key string, rule Rule, now time.Time) (Decision, error) {
+	if err := rule.Validate(); err != nil {
+		return Decision{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var e *memoryEntry
+	if el, ok := s.entries[key]; ok {
+		e = el.Value.(*memoryEntry)
+		s.lru.MoveToFront(el)
+		// A changed algorithm cannot reuse the old state.
+		if e.algorithm != rule.Algorithm {
+			e.algorithm = rule.Algorithm
+			e.state = newState(rule, now)
+		}
+	} else {
+		e = &memoryEntry{key: key, algorithm: rule.Algorithm, state: newState(rule, now)}
+		s.entries[key] = s.lru.PushFront(e)
+		for s.lru.Len() > s.maxKeys {
+			s.remove(s.lru.Back())
+		}
+	}
+
+	e.lastSeen = now
+	e.idleAfter = 2 * rule.Window
+	return e.state.take(rule, now), nil
+}
+
+// Evict drops keys that have been idle for more than twice their window and
+// returns how many were removed.
+func (s *MemoryStore) Evict(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for el := s.lru.Back(); el != nil; {
+		prev := el.Prev()
+		e := el.Value.(*memoryEntry)
+		if now.Sub(e.lastSeen) > e.idleAfter {
+			s.remove(el)
+			removed++
+		}
+		el = prev
+	}
+	return removed
+}
+
+func (s *MemoryStore) remove(el *list.Element) {
+	s.lru.Remove(el)
+	delete(s.entries, el.Value.(*memoryEntry).key)
+}
+
+// Len returns the number of tracked keys.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// StartJanitor evicts idle keys every interval until Close is called.
+func (s *MemoryStore) StartJanitor(interval time.Duration) {
+	s.mu.Lock()
+	if s.stop != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.stop = make(chan struct{})
+	stop := s.stop
+	s.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				s.Evict(now)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the janitor.
+func (s *MemoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnavailable is returned when a shared store cannot be reached.
+var ErrUnavailable = errors.New("rate limit store unavailable")
+
+// Algorithm names accepted in the RateLimit filter settings.
+const (
+	FixedWindow   = "fixed_window"
+	TokenBucket   = "token_bucket"
+	SlidingLog    = "sliding_log"
+	SlidingWindow = "sliding_window"
+)
+
+// Rule describes how many requests a key may make.
+type Rule struct {
+	Algorithm string
+	Limit     int
+	Window    time.Duration
+	// Burst is the bucket capacity for TokenBucket. It defaults to Limit.
+	Burst int
+}
+
+// Decision is the outcome of taking one request from a key's allowance.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the allowance is fully restored.
+	Reset time.Duration
+	// RetryAfter is how long a rejected caller should wait before retrying.
+	RetryAfter time.Duration
+}
+
+// Store keeps rate limit state for keys.
+type Store interface {
+	Take(key string, rule Rule, now time.Time) (Decision, error)
+}
+
+// Validate reports whether the rule can be enforced.
+func (r Rule) Validate() error {
+	switch r.Algorithm {
+	case FixedWindow, TokenBucket, SlidingLog, SlidingWindow:
+	default:
+		return fmt.Errorf("unknown rate limit algorithm %q", r.Algorithm)
+	}
+	if r.Limit <= 0 || r.Window <= 0 {
+		return fmt.Errorf("rate limit needs a positive limit and window")
+	}
+	return nil
+}
+
+func (r Rule) capacity() int {
+	if r.Algorithm == TokenBucket && r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Limit
+}
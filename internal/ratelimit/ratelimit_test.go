@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func takeN(t *testing.T, s *MemoryStore, key string, rule Rule, now time.Time, n int) int {
+	t.Helper()
+	allowed := 0
+	for i := 0; i < n; i++ {
+		d, err := s.Take(key, rule, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Allowed {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestAlgorithms_EnforceLimit(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, algorithm := range []string{FixedWindow, TokenBucket, SlidingLog, SlidingWindow} {
+		s := NewMemoryStore(0)
+		rule := Rule{Algorithm: algorithm, Limit: 5, Window: 10 * time.Second}
+		if got := takeN(t, s, "k", rule, now, 8); got != 5 {
+			t.Errorf("%s: expected 5 allowed requests, got %d", algorithm, got)
+		}
+		d, _ := s.Take("k", rule, now)
+		if d.Allowed || d.RetryAfter <= 0 {
+			t.Errorf("%s: expected rejection with a positive RetryAfter, got %+v", algorithm, d)
+		}
+		if got := takeN(t, s, "k", rule, now.Add(20*time.Second), 1); got != 1 {
+			t.Errorf("%s: expected allowance to recover after two windows", algorithm)
+		}
+	}
+}
+
+func TestTokenBucket_Refill(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryStore(0)
+	rule := Rule{Algorithm: TokenBucket, Limit: 10, Window: 10 * time.Second, Burst: 2}
+
+	if got := takeN(t, s, "k", rule, now, 3); got != 2 {
+		t.Fatalf("Expected burst of 2, got %d", got)
+	}
+	// One token refills every second.
+	if got := takeN(t, s, "k", rule, now.Add(time.Second), 2); got != 1 {
+		t.Errorf("Expected one refilled token, got %d", got)
+	}
+}
+
+func TestSlidingLog_NoBoundaryBurst(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore(0)
+	rule := Rule{Algorithm: SlidingLog, Limit: 4, Window: 10 * time.Second}
+
+	takeN(t, s, "k", rule, start.Add(9*time.Second), 4)
+	// A fixed window would reset here; the sliding log still sees 4 requests.
+	if got := takeN(t, s, "k", rule, start.Add(11*time.Second), 4); got != 0 {
+		t.Errorf("Expected no requests allowed right after the boundary, got %d", got)
+	}
+}
+
+func TestMemoryStore_IsBoundedAndEvictsIdleKeys(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryStore(3)
+	rule := Rule{Algorithm: FixedWindow, Limit: 1, Window: time.Second}
+
+	for _, k := range []string{"a", "b", "c", "d"} {
+		takeN(t, s, k, rule, now, 1)
+	}
+	if s.Len() != 3 {
+		t.Errorf("Expected store to hold 3 keys, got %d", s.Len())
+	}
+
+	if removed := s.Evict(now.Add(time.Second)); removed != 0 {
+		t.Errorf("Expected no eviction of recently used keys, got %d", removed)
+	}
+	if removed := s.Evict(now.Add(3 * time.Second)); removed != 3 {
+		t.Errorf("Expected all idle keys evicted, got %d", removed)
+	}
+}
+
+func TestRule_Validate(t *testing.T) {
+	if err := (Rule{Algorithm: "leaky", Limit: 1, Window: time.Second}).Validate(); err == nil {
+		t.Error("Expected unknown algorithm to be rejected")
+	}
+	if err := (Rule{Algorithm: FixedWindow, Limit: 0, Window: time.Second}).Validate(); err == nil {
+		t.Error("Expected zero limit to be rejected")
+	}
+}
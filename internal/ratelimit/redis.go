@@ -0,0 +1,201 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+	"zentro/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisOptions configures the connection to a Redis-protocol server.
+type RedisOptions struct {
+	Addr      string
+	Username  string
+	Password  string
+	DB        int
+	KeyPrefix string
+	// Timeout bounds every round trip so a slow server cannot stall requests.
+	Timeout time.Duration
+}
+
+// RedisStore shares rate limit state between gateway replicas. Every
+// algorithm runs as a Lua script so read-modify-write is atomic on the server.
+//
+// Scripts receive the caller's clock, so replicas should keep their clocks
+// in sync.
+type RedisStore struct {
+	client  *redis.Client
+	prefix  string
+	timeout time.Duration
+}
+
+// Each script takes ARGV now_ms, limit, window_ms, cost, capacity, extra and
+// returns {granted, remaining, reset_ms, retry_ms}.
+var (
+	fixedWindowScript = redis.NewScript(`
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+local limit, window, cost, reset = tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4]), tonumber(ARGV[6])
+local granted = math.max(0, math.min(cost, limit - count))
+if granted > 0 then
+  redis.call('INCRBY', KEYS[1], granted)
+  redis.call('PEXPIRE', KEYS[1], window)
+end
+local retry = 0
+if granted < cost then retry = reset end
+return {granted, math.max(0, limit - count - granted), reset, retry}
+`)
+
+	tokenBucketScript = redis.NewScript(`
+local now, limit, window, cost, capacity = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4]), tonumber(ARGV[5])
+local rate = limit / window
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or capacity
+local ts = tonumber(data[2]) or now
+if now > ts then
+  tokens = math.min(capacity, tokens + (now - ts) * rate)
+  ts = now
+end
+local granted = math.max(0, math.min(cost, math.floor(tokens)))
+tokens = tokens - granted
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(ts))
+redis.call('PEXPIRE', KEYS[1], reset + window)
+local retry = 0
+if granted < cost then retry = math.ceil((1 - tokens) / rate) end
+return {granted, math.floor(tokens), reset, retry}
+`)
+
+	slidingLogScript = redis.NewScript(`
+local now, limit, window, cost = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local granted = math.max(0, math.min(cost, limit - count))
+for i = 1, granted do
+  redis.call('ZADD', KEYS[1], now, ARGV[6] .. ':' .. i)
+end
+redis.call('PEXPIRE', KEYS[1], window)
+local retry, reset = 0, 0
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+if newest[2] then reset = tonumber(newest[2]) + window - now end
+if granted < cost and oldest[2] then retry = tonumber(oldest[2]) + window - now end
+return {granted, math.max(0, limit - count - granted), reset, retry}
+`)
+
+	slidingWindowScript = redis.NewScript(`
+local limit, window, cost, elapsed = tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4]), tonumber(ARGV[6])
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local previous = tonumber(redis.call('GET', KEYS[2]) or '0')
+local estimate = previous * (1 - elapsed / window) + current
+local granted = math.max(0, math.min(cost, math.floor(limit - estimate)))
+if granted > 0 then
+  redis.call('INCRBY', KEYS[1], granted)
+  redis.call('PEXPIRE', KEYS[1], 2 * window)
+end
+local reset = window - elapsed
+local retry = 0
+if granted < cost then
+  retry = reset
+  if previous > 0 then
+    retry = math.min(reset, math.ceil((estimate + 1 - limit) / previous * window))
+  end
+end
+return {granted, math.max(0, math.floor(limit - estimate - granted)), reset, retry}
+`)
+)
+
+func NewRedisStore(opts RedisOptions) *RedisStore {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 100 * time.Millisecond
+	}
+	if opts.KeyPrefix == "" {
+		opts.KeyPrefix = "zentro:rl:"
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:         opts.Addr,
+		Username:     opts.Username,
+		Password:     opts.Password,
+		DB:           opts.DB,
+		DialTimeout:  opts.Timeout,
+		ReadTimeout:  opts.Timeout,
+		WriteTimeout: opts.Timeout,
+	})
+	return &RedisStore{client: client, prefix: opts.KeyPrefix, timeout: opts.Timeout}
+}
+
+func (s *RedisStore) Take(key string, rule Rule, now time.Time) (Decision, error) {
+	d, _, err := s.takeN(key, rule, now, 1)
+	return d, err
+}
+
+// takeN asks for up to cost permits in one round trip and reports how many
+// were granted. The returned Decision describes the first permit.
+func (s *RedisStore) takeN(key string, rule Rule, now time.Time, cost int) (Decision, int, error) {
+	if err := rule.Validate(); err != nil {
+		return Decision{}, 0, err
+	}
+
+	nowMs := now.UnixMilli()
+	windowMs := rule.Window.Milliseconds()
+	if windowMs <= 0 {
+		windowMs = 1
+	}
+	base := s.prefix + rule.Algorithm + ":" + key
+	windowIndex := nowMs / windowMs
+	elapsed := nowMs - windowIndex*windowMs
+
+	var script *redis.Script
+	var keys []string
+	var extra string
+	switch rule.Algorithm {
+	case TokenBucket:
+		script, keys = tokenBucketScript, []string{base}
+	case SlidingLog:
+		script, keys, extra = slidingLogScript, []string{base}, strconv.FormatInt(nowMs, 10)+":"+utils.GenerateRandomID(8)
+	case SlidingWindow:
+		script = slidingWindowScript
+		keys = []string{fmt.Sprintf("%s:%d", base, windowIndex), fmt.Sprintf("%s:%d", base, windowIndex-1)}
+		extra = strconv.FormatInt(elapsed, 10)
+	default:
+		script = fixedWindowScript
+		keys = []string{fmt.Sprintf("%s:%d", base, windowIndex)}
+		extra = strconv.FormatInt(windowMs-elapsed, 10)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	res, err := script.Run(ctx, s.client, keys, nowMs, rule.Limit, windowMs, cost, rule.capacity(), extra).Int64Slice()
+	if err != nil {
+		return Decision{}, 0, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if len(res) != 4 {
+		return Decision{}, 0, fmt.Errorf("%w: unexpected script reply %v", ErrUnavailable, res)
+	}
+
+	granted := int(res[0])
+	d := Decision{
+		Allowed:    granted > 0,
+		Limit:      rule.capacity(),
+		Remaining:  int(res[1]),
+		Reset:      time.Duration(res[2]) * time.Millisecond,
+		RetryAfter: time.Duration(res[3]) * time.Millisecond,
+	}
+	if d.Allowed {
+		d.RetryAfter = 0
+	}
+	return d, granted, nil
+}
+
+// Ping checks that the server is reachable.
+func (s *RedisStore) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
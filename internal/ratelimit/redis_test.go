@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func takeFrom(t *testing.T, s Store, key string, rule Rule, now time.Time, n int) int {
+	t.Helper()
+	allowed := 0
+	for i := 0; i < n; i++ {
+		d, err := s.Take(key, rule, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Allowed {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestRedisStore_SharedAcrossInstances(t *testing.T) {
+	mr := miniredis.RunT(t)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, algorithm := range []string{FixedWindow, TokenBucket, SlidingLog, SlidingWindow} {
+		a := NewRedisStore(RedisOptions{Addr: mr.Addr()})
+		b := NewRedisStore(RedisOptions{Addr: mr.Addr()})
+		rule := Rule{Algorithm: algorithm, Limit: 6, Window: 10 * time.Second}
+
+		got := takeFrom(t, a, "k", rule, now, 4) + takeFrom(t, b, "k", rule, now, 4)
+		if got != 6 {
+			t.Errorf("%s: expected 6 allowed requests across instances, got %d", algorithm, got)
+		}
+		d, _ := b.Take("k", rule, now)
+		if d.Allowed || d.RetryAfter <= 0 {
+			t.Errorf("%s: expected rejection with a positive RetryAfter, got %+v", algorithm, d)
+		}
+		if got := takeFrom(t, a, "k", rule, now.Add(20*time.Second), 1); got != 1 {
+			t.Errorf("%s: expected allowance to recover after two windows", algorithm)
+		}
+		a.Close()
+		b.Close()
+	}
+}
+
+func TestBatchingStore_ReservesPermits(t *testing.T) {
+	mr := miniredis.RunT(t)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	remote := NewRedisStore(RedisOptions{Addr: mr.Addr()})
+	defer remote.Close()
+	s := NewBatchingStore(remote, 5, time.Second)
+	rule := Rule{Algorithm: FixedWindow, Limit: 12, Window: time.Minute}
+
+	before := mr.CommandCount()
+	if got := takeFrom(t, s, "k", rule, now, 15); got != 12 {
+		t.Errorf("Expected 12 allowed requests, got %d", got)
+	}
+	batched := mr.CommandCount() - before
+
+	before = mr.CommandCount()
+	takeFrom(t, remote, "other", rule, now, 15)
+	if unbatched := mr.CommandCount() - before; batched*2 >= unbatched {
+		t.Errorf("Expected batching to cut round trips, got %d commands vs %d", batched, unbatched)
+	}
+}
+
+func TestBatchingStore_ConcurrentRefills(t *testing.T) {
+	mr := miniredis.RunT(t)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	remote := NewRedisStore(RedisOptions{Addr: mr.Addr()})
+	defer remote.Close()
+	s := NewBatchingStore(remote, 5, time.Second)
+	rule := Rule{Algorithm: FixedWindow, Limit: 12, Window: time.Minute}
+
+	var mu sync.Mutex
+	allowed := map[string]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		key := []string{"a", "b"}[i%2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d, err := s.Take(key, rule, now)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if d.Allowed {
+				mu.Lock()
+				allowed[key]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed["a"] != 12 || allowed["b"] != 12 {
+		t.Errorf("Expected 12 allowed requests per key, got %v", allowed)
+	}
+}
+
+func TestFailoverStore_Modes(t *testing.T) {
+	mr := miniredis.RunT(t)
+	remote := NewRedisStore(RedisOptions{Addr: mr.Addr(), Timeout: 50 * time.Millisecond})
+	defer remote.Close()
+	mr.Close()
+
+	now := time.Now()
+	rule := Rule{Algorithm: FixedWindow, Limit: 2, Window: time.Minute}
+
+	open := &FailoverStore{Primary: remote, Mode: FailOpen}
+	if got := takeFrom(t, open, "k", rule, now, 3); got != 3 {
+		t.Errorf("Expected fail-open to allow every request, got %d", got)
+	}
+
+	closed := &FailoverStore{Primary: remote, Mode: FailClosed}
+	if _, err := closed.Take("k", rule, now); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable in closed mode, got %v", err)
+	}
+
+	local := &FailoverStore{Primary: remote, Local: NewMemoryStore(0), Mode: FailLocal}
+	if got := takeFrom(t, local, "k", rule, now, 3); got != 2 {
+		t.Errorf("Expected local fallback to enforce the limit, got %d", got)
+	}
+}
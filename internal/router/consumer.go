@@ -0,0 +1,21 @@
+package router
+
+import (
+	"zentro/internal/filters"
+	"zentro/internal/global"
+)
+
+// lookupConsumer adapts the global consumer registry to filters.ConsumerLookup.
+func lookupConsumer(authType, credential string) (*filters.Consumer, bool) {
+	c, cred, ok := global.FindConsumer(authType, credential)
+	if !ok {
+		return nil, false
+	}
+	return &filters.Consumer{
+		ID:           c.Id,
+		Username:     c.Username,
+		Groups:       c.Groups,
+		CredentialID: cred.Id,
+		Limits:       c.RateLimit,
+	}, true
+}
@@ -0,0 +1,246 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"zentro/internal/clientip"
+	"zentro/internal/config"
+	"zentro/internal/filters"
+	"zentro/internal/global"
+	"zentro/internal/ratelimit"
+)
+
+// clientAddress resolves the client IP once per request, believing
+// forwarding headers only from the configured trusted proxies.
+func clientAddress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr := clientip.Resolve(r, config.TrustedProxyRanges())
+		next.ServeHTTP(w, r.WithContext(clientip.WithAddr(r.Context(), addr)))
+	})
+}
+
+// globalSettings enforces the gateway-wide IP policy, request limits, rate
+// limit, security headers and default CORS policy from the live GlobalConfig, so changes made through /api/settings apply to
+// the next request. Routes can adjust both through their "global" block.
+func globalSettings(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settings := config.GetGlobalConfig()
+		if settings == nil {
+			next.ServeHTTP(w, withMatchedRoute(r, MatchRoute(r, global.GetConfig().Routes)))
+			return
+		}
+		if !globalIpAllowed(r) {
+			filters.RecordRejection("IpFilter", "gateway")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if !withinRequestLimits(w, r, settings.RequestLimits) {
+			return
+		}
+		route := MatchRoute(r, global.GetConfig().Routes)
+		r = withMatchedRoute(r, route)
+
+		if len(settings.SecurityHeaders) > 0 && routeUsesGlobalSecurityHeaders(route) {
+			var headers filters.SecurityHeadersFilter
+			headers.Convert(filters.GenericFilter{Name: "global-security-headers", Settings: settings.SecurityHeaders})
+			if headers.Settings.Enabled {
+				w = headers.Wrap(w, r)
+			}
+		}
+
+		if !takeGlobalLimit(w, settings, route) {
+			return
+		}
+
+		if settings.Cors && routeUsesGlobalCors(route) {
+			applyGlobalCors(w, r, settings.CorsPolicy, next)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type matchedRouteKey struct{}
+
+// withMatchedRoute records the route matched for r, nil for none, so the
+// handler doesn't match it again.
+func withMatchedRoute(r *http.Request, route *config.Route) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), matchedRouteKey{}, route))
+}
+
+// matchedRoute returns the route recorded for r, matching it when the
+// request did not pass through globalSettings.
+func matchedRoute(r *http.Request) *config.Route {
+	if route, ok := r.Context().Value(matchedRouteKey{}).(*config.Route); ok {
+		return route
+	}
+	return MatchRoute(r, global.GetConfig().Routes)
+}
+
+// globalIpAllowed checks r against the gateway-wide IP policy, parsed when
+// the settings last changed.
+func globalIpAllowed(r *http.Request) bool {
+	allow, deny := config.IpFilterRanges()
+	if len(allow) == 0 && len(deny) == 0 {
+		return true
+	}
+	settings := filters.IpFilterSettings{Allow: allow, Deny: deny}
+	return settings.Allows(clientip.FromRequest(r))
+}
+
+// withinRequestLimits rejects requests whose URL or headers break the
+// limits, writing the rejection itself, and limits the body as it streams.
+func withinRequestLimits(w http.ResponseWriter, r *http.Request, limits config.RequestLimits) bool {
+	reject := func(reason string, status int, message string) bool {
+		filters.RecordRejection("RequestLimits", reason)
+		http.Error(w, message, status)
+		return false
+	}
+	if limits.MaxURLLength > 0 && len(r.RequestURI) > limits.MaxURLLength {
+		return reject("url_length", http.StatusRequestURITooLong, "URI too long")
+	}
+	if limits.MaxQueryParams > 0 && queryParamCount(r.URL.RawQuery) > limits.MaxQueryParams {
+		return reject("query_params", http.StatusBadRequest, "Too many query parameters")
+	}
+	if limits.MaxHeaderCount > 0 || limits.MaxHeaderBytes > 0 {
+		count, size := 0, len(r.Host)
+		for name, values := range r.Header {
+			count += len(values)
+			for _, value := range values {
+				size += len(name) + len(value)
+			}
+		}
+		if limits.MaxHeaderCount > 0 && count > limits.MaxHeaderCount {
+			return reject("header_count", http.StatusRequestHeaderFieldsTooLarge, "Too many request headers")
+		}
+		if limits.MaxHeaderBytes > 0 && size > limits.MaxHeaderBytes {
+			return reject("header_bytes", http.StatusRequestHeaderFieldsTooLarge, "Request headers too large")
+		}
+	}
+	if limits.MaxBodyBytes > 0 {
+		if r.ContentLength > limits.MaxBodyBytes {
+			return reject("body_bytes", http.StatusRequestEntityTooLarge, "Payload Too Large")
+		}
+		filters.LimitBody(w, r, limits.MaxBodyBytes)
+	}
+	if limits.MaxJSONDepth > 0 {
+		if err := filters.LimitJSONDepth(r, limits.MaxJSONDepth, limits.MaxBodyBytes); err != nil {
+			return reject("json_encoding", http.StatusUnsupportedMediaType, "Unsupported Content-Encoding")
+		}
+	}
+	return true
+}
+
+func queryParamCount(rawQuery string) int {
+	count := 0
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// takeGlobalLimit reports whether the request fits under the global ceiling.
+// It writes the rejection itself when it does not.
+func takeGlobalLimit(w http.ResponseWriter, settings *config.GlobalConfig, route *config.Route) bool {
+	limit, key := settings.GlobalRateLimiting, "global|gateway"
+	if route != nil && route.Global != nil && route.Global.RateLimit != nil {
+		limit, key = *route.Global.RateLimit, "global|"+RouteScope(route)
+	}
+	if limit <= 0 {
+		return true
+	}
+
+	rule := ratelimit.Rule{Algorithm: ratelimit.TokenBucket, Limit: limit, Window: time.Second}
+	decision, err := filters.RateLimitStore.Take(key, rule, time.Now())
+	if errors.Is(err, ratelimit.ErrUnavailable) {
+		http.Error(w, "Rate limit store unavailable", http.StatusServiceUnavailable)
+		return false
+	}
+	if err != nil || decision.Allowed {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int((decision.RetryAfter+time.Second-1)/time.Second)))
+	http.Error(w, "Gateway rate limit exceeded", http.StatusTooManyRequests)
+	return false
+}
+
+// routeUsesGlobalCors is false for routes that opt out or bring their own
+// CorsWebFilter.
+func routeUsesGlobalCors(route *config.Route) bool {
+	if route == nil {
+		return true
+	}
+	if route.Global != nil && route.Global.Cors != nil && !*route.Global.Cors {
+		return false
+	}
+	for _, f := range route.Filters {
+		if filters.FilterTypeFromName(f.Name) == filters.CorsWebFilterType {
+			return false
+		}
+	}
+	return true
+}
+
+// routeUsesGlobalSecurityHeaders is false for routes that opt out or bring
+// their own SecurityHeaders filter, which is merged with the default instead.
+func routeUsesGlobalSecurityHeaders(route *config.Route) bool {
+	if route == nil {
+		return true
+	}
+	if route.Global != nil && route.Global.SecurityHeaders != nil && !*route.Global.SecurityHeaders {
+		return false
+	}
+	for _, f := range route.Filters {
+		if filters.FilterTypeFromName(f.Name) == filters.SecurityHeadersFilterType {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeSecurityHeaders lays a route's SecurityHeaders settings over the
+// gateway default. A route that picks a preset starts from it instead of
+// the default's headers.
+func mergeSecurityHeaders(defaults map[string]interface{}, filter filters.GenericFilter) filters.GenericFilter {
+	_, ownPreset := filter.Settings["preset"]
+	merged := map[string]interface{}{}
+	for key, value := range defaults {
+		if ownPreset && key != "remove" && key != "override" {
+			continue
+		}
+		merged[key] = value
+	}
+	for key, value := range filter.Settings {
+		merged[key] = value
+	}
+	filter.Settings = merged
+	return filter
+}
+
+func applyGlobalCors(w http.ResponseWriter, r *http.Request, policy config.CorsPolicy, next http.Handler) {
+	cors := filters.CorsWebFilter{
+		Name: "global-cors",
+		Settings: filters.CorsWebSettings{
+			AllowOrigins:     policy.AllowOrigins,
+			AllowMethods:     policy.AllowMethods,
+			AllowHeaders:     policy.AllowHeaders,
+			AllowCredentials: policy.AllowCredentials,
+			MaxAge:           policy.MaxAge,
+		},
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin != "" && r.Method != http.MethodOptions && cors.AllowsOrigin(origin) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if policy.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+	cors.Apply(next).ServeHTTP(w, r)
+}
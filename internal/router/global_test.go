@@ -0,0 +1,182 @@
+package router
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"zentro/internal/clientip"
+	"zentro/internal/config"
+	"zentro/internal/filters"
+	"zentro/internal/global"
+)
+
+func TestTakeGlobalLimit_RouteOverride(t *testing.T) {
+	settings := &config.GlobalConfig{GlobalRateLimiting: 2}
+	exempt := 0
+	route := &config.Route{Name: "exempt", PathPrefix: "/free", Global: &config.GlobalOverrides{RateLimit: &exempt}}
+
+	for i := 0; i < 5; i++ {
+		if !takeGlobalLimit(httptest.NewRecorder(), settings, route) {
+			t.Fatalf("Expected exempt route to bypass the global limit")
+		}
+	}
+
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		if takeGlobalLimit(w, settings, nil) {
+			allowed++
+		} else if w.Code != http.StatusTooManyRequests {
+			t.Errorf("Expected 429, got %d", w.Code)
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("Expected 2 requests under the global limit, got %d", allowed)
+	}
+}
+
+func TestRouteUsesGlobalCors(t *testing.T) {
+	off := false
+	cases := map[string]struct {
+		route *config.Route
+		want  bool
+	}{
+		"no route":   {nil, true},
+		"plain":      {&config.Route{}, true},
+		"opted out":  {&config.Route{Global: &config.GlobalOverrides{Cors: &off}}, false},
+		"own filter": {&config.Route{Filters: []filters.GenericFilter{{Name: "CorsWebFilter"}}}, false},
+	}
+	for name, c := range cases {
+		if got := routeUsesGlobalCors(c.route); got != c.want {
+			t.Errorf("%s: expected %v, got %v", name, c.want, got)
+		}
+	}
+}
+
+func TestGlobalIpAllowed(t *testing.T) {
+	config.Init("test")
+	settings := config.GetGlobalConfig().Clone()
+	settings.IpFilter = config.IpPolicy{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.13"}}
+	config.UpdateGlobalConfig(settings)
+	defer config.Init("test")
+	for addr, want := range map[string]bool{"10.1.2.3:1": true, "10.0.0.13:1": false, "192.0.2.1:1": false} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = addr
+		if got := globalIpAllowed(r); got != want {
+			t.Errorf("%s: expected %v, got %v", addr, want, got)
+		}
+	}
+}
+
+func TestMergeSecurityHeaders(t *testing.T) {
+	defaults := map[string]interface{}{"preset": "basic", "hsts": "max-age=600", "remove": []interface{}{"Server"}}
+	merged := mergeSecurityHeaders(defaults, filters.GenericFilter{Name: "SecurityHeaders", Settings: map[string]interface{}{"frame_options": false}})
+	if merged.Settings["hsts"] != "max-age=600" || merged.Settings["frame_options"] != false {
+		t.Errorf("Expected route settings over the defaults, got %v", merged.Settings)
+	}
+	merged = mergeSecurityHeaders(defaults, filters.GenericFilter{Name: "SecurityHeaders", Settings: map[string]interface{}{"preset": "api"}})
+	if _, ok := merged.Settings["hsts"]; ok || merged.Settings["remove"] == nil {
+		t.Errorf("Expected a route preset to replace the default headers, got %v", merged.Settings)
+	}
+}
+
+func TestWithinRequestLimits(t *testing.T) {
+	limits := config.RequestLimits{MaxHeaderCount: 3, MaxHeaderBytes: 64, MaxURLLength: 40, MaxQueryParams: 2, MaxBodyBytes: 8}
+	cases := map[string]struct {
+		target  string
+		headers int
+		body    string
+		want    int
+	}{
+		"within":       {"/a?x=1&y=2", 2, "small", 0},
+		"long url":     {"/" + strings.Repeat("a", 50), 0, "", http.StatusRequestURITooLong},
+		"query params": {"/a?x=1&y=2&z=3", 0, "", http.StatusBadRequest},
+		"headers":      {"/a", 4, "", http.StatusRequestHeaderFieldsTooLarge},
+		"body":         {"/a", 0, "far too large", http.StatusRequestEntityTooLarge},
+	}
+	for name, c := range cases {
+		r := httptest.NewRequest("POST", c.target, strings.NewReader(c.body))
+		for i := 0; i < c.headers; i++ {
+			r.Header.Set(fmt.Sprintf("X-H%d", i), "v")
+		}
+		w := httptest.NewRecorder()
+		ok := withinRequestLimits(w, r, limits)
+		if ok != (c.want == 0) || (!ok && w.Code != c.want) {
+			t.Errorf("%s: expected %d, got %v %d", name, c.want, ok, w.Code)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/a", nil)
+	r.Header.Set("X-Big", strings.Repeat("v", 100))
+	if w := httptest.NewRecorder(); withinRequestLimits(w, r, limits) || w.Code != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("Expected oversized headers to be rejected with 431, got %d", w.Code)
+	}
+}
+
+func TestWithinRequestLimits_CompressedJSONDepth(t *testing.T) {
+	limits := config.RequestLimits{MaxJSONDepth: 2}
+	var deep bytes.Buffer
+	zw := gzip.NewWriter(&deep)
+	zw.Write([]byte(`{"a":[[[1]]]}`))
+	zw.Close()
+
+	r := httptest.NewRequest("POST", "/a", &deep)
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Encoding", "gzip")
+	if !withinRequestLimits(httptest.NewRecorder(), r, limits) {
+		t.Fatalf("Expected the body to be checked as it streams")
+	}
+	_, err := io.ReadAll(r.Body)
+	if status, _ := filters.BodyErrorStatus(err); status != http.StatusBadRequest {
+		t.Errorf("Expected a gzipped body nested too deep to fail with 400, got %v", err)
+	}
+
+	r = httptest.NewRequest("POST", "/a", strings.NewReader("opaque"))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Encoding", "compress")
+	if w := httptest.NewRecorder(); withinRequestLimits(w, r, limits) || w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected an undecodable JSON body to be rejected with 415, got %d", w.Code)
+	}
+}
+
+func TestClientAddress_BelievesTrustedProxies(t *testing.T) {
+	config.Init("test")
+	settings := config.GetGlobalConfig().Clone()
+	settings.TrustedProxies = []string{"10.0.0.0/8"}
+	config.UpdateGlobalConfig(settings)
+
+	var client string
+	handler := clientAddress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client = clientip.String(r)
+	}))
+	for remote, want := range map[string]string{"10.1.2.3:4000": "198.51.100.7", "203.0.113.9:4000": "203.0.113.9"} {
+		req := httptest.NewRequest("GET", "/orders/7", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-For", "198.51.100.7")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if client != want {
+			t.Errorf("Expected client %q behind %s, got %q", want, remote, client)
+		}
+	}
+}
+
+func TestGlobalSettings_PassesMatchedRoute(t *testing.T) {
+	config.Init("test")
+	global.InitConfig(&config.GatewayConfig{Routes: []config.Route{{Name: "orders", PathPrefix: "/orders"}}})
+
+	var route *config.Route
+	handler := globalSettings(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Routes reloaded after the match must not change the request's route.
+		global.InitConfig(&config.GatewayConfig{})
+		route = matchedRoute(r)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders/7", nil))
+	if route == nil || route.Name != "orders" {
+		t.Errorf("Expected the route matched by globalSettings, got %+v", route)
+	}
+}
@@ -24,6 +24,20 @@ func MatchFilter(name string) filters.Filter {
     case filters.MapRequestHeaderFilterType: return &filters.MapRequestHeaderFilter{}
     case filters.PreserveHostHeaderFilterType: return &filters.PreserveHostHeaderFilter{}
     case filters.RequestSizeFilterType: return &filters.RequestSizeFilter{}
+    case filters.AclFilterType: return &filters.AclFilter{}
+    case filters.ConcurrencyFilterType: return &filters.ConcurrencyFilter{}
+    case filters.CacheFilterType: return &filters.CacheFilter{}
+    case filters.CompressionFilterType: return &filters.CompressionFilter{}
+    case filters.JsonTransformFilterType: return &filters.JsonTransformFilter{}
+    case filters.SchemaValidationFilterType: return &filters.SchemaValidationFilter{}
+    case filters.MockFilterType: return &filters.MockFilter{}
+    case filters.FaultInjectionFilterType: return &filters.FaultInjectionFilter{}
+    case filters.IpFilterType: return &filters.IpFilter{}
+    case filters.RewriteResponseHeadersFilterType: return &filters.RewriteResponseHeadersFilter{}
+    case filters.SecurityHeadersFilterType: return &filters.SecurityHeadersFilter{}
+    case filters.WafFilterType: return &filters.WafFilter{}
+    case filters.HmacSignatureFilterType: return &filters.HmacSignatureFilter{}
+    case filters.InternalJwtFilterType: return &filters.InternalJwtFilter{}
 
     default:
         log.Printf("Unknown filter: %s", name)
@@ -1,9 +1,11 @@
 package router
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"time"
+	"zentro/internal/clientip"
 	"zentro/internal/config"
 	"zentro/internal/filters"
 	"zentro/internal/global"
@@ -31,13 +33,16 @@ func NewRouter(routes []config.Route) http.Handler {
 		})
 	})
 
+	r.Use(clientAddress)
+	r.Use(globalSettings)
+
 	r.Handle("/*", http.HandlerFunc(e.handle))
 
 	return r
 }
 
 func (e *Engine) handle(w http.ResponseWriter, r *http.Request) {
-	route := MatchRoute(r, global.GetConfig().Routes)
+	route := matchedRoute(r)
 	w.Header().Set("X-Zentro-Proxy", "true")
 	if route == nil {
 		http.Error(w, "no route matched", http.StatusNotFound)
@@ -53,16 +58,35 @@ func (e *Engine) handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var handler http.Handler = rp
+	scope := RouteScope(route)
 
 	for i := len(route.Filters) - 1; i >= 0; i-- {
 		var genericFilter = route.Filters[i]
+		if settings := config.GetGlobalConfig(); settings != nil && filters.FilterTypeFromName(genericFilter.Name) == filters.SecurityHeadersFilterType {
+			genericFilter = mergeSecurityHeaders(settings.SecurityHeaders, genericFilter)
+		}
 		var filter filters.Filter = MatchFilter(genericFilter.Name)
 		filter.Convert(genericFilter)
+		if scoped, ok := filter.(filters.Scoped); ok {
+			scoped.SetScope(fmt.Sprintf("%s#%d", scope, i))
+		}
 		handler = filter.Apply(handler)
 	}
 
+	if route.Acl.IsSet() {
+		aclFilter := filters.AclFilter{
+			Name: "acl",
+			Settings: filters.AclFilterSettings{
+				AllowGroups:    route.Acl.AllowGroups,
+				DenyGroups:     route.Acl.DenyGroups,
+				AllowConsumers: route.Acl.AllowConsumers,
+			},
+		}
+		handler = aclFilter.Apply(handler)
+	}
+
 	if route.Auth.Enabled {
-		var authFilter = filters.AuthFilter{}
+		var authFilter = filters.AuthFilter{Lookup: lookupConsumer}
 		authFilter.Convert(filters.GenericFilter{
 			Name: "auth",
 			Settings: map[string]interface{}{
@@ -79,10 +103,11 @@ func (e *Engine) handle(w http.ResponseWriter, r *http.Request) {
 	wrappedWriter := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 	startTime := time.Now()
 
-	handler.ServeHTTP(wrappedWriter, r)
+	ctx := filters.WithWafMatches(filters.WithPathChanges(filters.WithRouteName(filters.WithUpstream(r.Context(), upstream), route.Name)))
+	handler.ServeHTTP(wrappedWriter, r.WithContext(ctx))
 
 	latency := time.Since(startTime)
 	statusCode := wrappedWriter.Status()
 
-	global.GlobalMetrics.RecordRequest(r.Method, r.URL.Path, statusCode, latency,r.RemoteAddr)
+	global.GlobalMetrics.RecordRequest(r.Method, r.URL.Path, statusCode, latency,clientip.String(r), filters.WafMatchesFromContext(ctx).RuleIDs)
 }
@@ -0,0 +1,21 @@
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"zentro/internal/config"
+)
+
+// RouteScope names a route's shared filter state. Generated route IDs change
+// on every load, so the scope is derived from what the route matches instead;
+// it then survives reloads and agrees across gateway replicas.
+func RouteScope(route *config.Route) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		route.Name,
+		route.Host,
+		route.PathPrefix,
+		strings.Join(route.Methods, ","),
+	}, "|")))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,214 @@
+// Package signing holds the keys the gateway signs its own tokens with and
+// publishes their public halves as a JSON Web Key Set.
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sync/atomic"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a private signing key with its key id and JWT algorithm.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	Signer crypto.Signer
+}
+
+// keys holds the loaded keys; the first one signs.
+var keys atomic.Pointer[[]Key]
+
+// Load reads the PEM encoded private keys at path. The first key signs new
+// tokens and the others are only published, so that tokens they signed stay
+// verifiable while keys are rotated. A missing file is created with a new
+// P-256 key.
+func Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = generate()
+		if err != nil {
+			return err
+		}
+		if werr := os.WriteFile(path, data, 0600); werr != nil {
+			log.Printf("Could not save the generated signing key, it will change on restart: %v", werr)
+		}
+	} else if err != nil {
+		return err
+	}
+	loaded, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	keys.Store(&loaded)
+	return nil
+}
+
+// Parse decodes PEM encoded private keys in PKCS #8, SEC 1 or PKCS #1 form.
+func Parse(data []byte) ([]Key, error) {
+	var loaded []Key
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		signer, err := parsePrivateKey(block)
+		if err != nil {
+			return nil, err
+		}
+		key, err := newKey(signer)
+		if err != nil {
+			return nil, err
+		}
+		loaded = append(loaded, key)
+	}
+	if len(loaded) == 0 {
+		return nil, errors.New("signing key file holds no PEM encoded private key")
+	}
+	return loaded, nil
+}
+
+// Use makes keys the loaded set.
+func Use(loaded []Key) {
+	keys.Store(&loaded)
+}
+
+// Current returns the key new tokens are signed with.
+func Current() (Key, bool) {
+	loaded := keys.Load()
+	if loaded == nil || len(*loaded) == 0 {
+		return Key{}, false
+	}
+	return (*loaded)[0], true
+}
+
+func generate() ([]byte, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing key type %T", key)
+	}
+	return signer, nil
+}
+
+func newKey(signer crypto.Signer) (Key, error) {
+	key := Key{Signer: signer}
+	switch k := signer.(type) {
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			key.Method = jwt.SigningMethodES256
+		case elliptic.P384():
+			key.Method = jwt.SigningMethodES384
+		case elliptic.P521():
+			key.Method = jwt.SigningMethodES512
+		}
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		key.Method = jwt.SigningMethodEdDSA
+	}
+	if key.Method == nil {
+		return Key{}, fmt.Errorf("unsupported signing key type %T", signer)
+	}
+	jwk := key.JWK()
+	key.ID = thumbprint(jwk)
+	return key, nil
+}
+
+// JWK returns the public key as a JSON Web Key.
+func (k Key) JWK() map[string]string {
+	jwk := map[string]string{"use": "sig", "alg": k.Method.Alg()}
+	if k.ID != "" {
+		jwk["kid"] = k.ID
+	}
+	switch public := k.Signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		size := (public.Curve.Params().BitSize + 7) / 8
+		jwk["kty"] = "EC"
+		jwk["crv"] = public.Curve.Params().Name
+		jwk["x"] = b64(public.X.FillBytes(make([]byte, size)))
+		jwk["y"] = b64(public.Y.FillBytes(make([]byte, size)))
+	case *rsa.PublicKey:
+		jwk["kty"] = "RSA"
+		jwk["n"] = b64(public.N.Bytes())
+		jwk["e"] = b64(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk["kty"] = "OKP"
+		jwk["crv"] = "Ed25519"
+		jwk["x"] = b64(public)
+	}
+	return jwk
+}
+
+// thumbprint is the RFC 7638 thumbprint of a JWK, used as its key id.
+func thumbprint(jwk map[string]string) string {
+	members := map[string][]string{
+		"EC":  {"crv", "kty", "x", "y"},
+		"RSA": {"e", "kty", "n"},
+		"OKP": {"crv", "kty", "x"},
+	}[jwk["kty"]]
+	// Marshalling a map sorts its keys, as the thumbprint requires.
+	required := map[string]string{}
+	for _, name := range members {
+		required[name] = jwk[name]
+	}
+	data, _ := json.Marshal(required)
+	sum := sha256.Sum256(data)
+	return b64(sum[:])
+}
+
+// JWKS returns the JSON Web Key Set of every loaded key.
+func JWKS() map[string][]map[string]string {
+	set := map[string][]map[string]string{"keys": {}}
+	if loaded := keys.Load(); loaded != nil {
+		for _, key := range *loaded {
+			set["keys"] = append(set["keys"], key.JWK())
+		}
+	}
+	return set
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,43 @@
+package signing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad_GeneratesAndKeepsKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing_key.pem")
+	if err := Load(path); err != nil {
+		t.Fatalf("Expected a key to be generated, got %v", err)
+	}
+	first, _ := Current()
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected the key to be saved with mode 0600, got %v %v", info, err)
+	}
+	if first.Method.Alg() != "ES256" {
+		t.Errorf("Expected ES256, got %s", first.Method.Alg())
+	}
+
+	if err := Load(path); err != nil {
+		t.Fatal(err)
+	}
+	second, _ := Current()
+	if second.ID != first.ID {
+		t.Errorf("Expected the saved key to be reused, got kid %s then %s", first.ID, second.ID)
+	}
+
+	set := JWKS()["keys"]
+	if len(set) != 1 || set[0]["kid"] != first.ID || set[0]["kty"] != "EC" || set[0]["crv"] != "P-256" {
+		t.Errorf("Unexpected JWKS %v", set)
+	}
+	if _, ok := set[0]["d"]; ok {
+		t.Errorf("Expected the JWKS to hold no private key material")
+	}
+}
+
+func TestParse_RejectsEmptyFile(t *testing.T) {
+	if _, err := Parse([]byte("not a key")); err == nil {
+		t.Errorf("Expected an error for a file without keys")
+	}
+}
@@ -0,0 +1,100 @@
+package tlsserver
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Headers describing a verified client certificate to upstreams.
+const (
+	HeaderClientCert            = "X-Client-Cert"
+	HeaderClientCertSubject     = "X-Client-Cert-Subject"
+	HeaderClientCertIssuer      = "X-Client-Cert-Issuer"
+	HeaderClientCertSerial      = "X-Client-Cert-Serial"
+	HeaderClientCertFingerprint = "X-Client-Cert-Fingerprint"
+	HeaderClientCertSan         = "X-Client-Cert-San"
+	HeaderClientCertNotAfter    = "X-Client-Cert-Not-After"
+)
+
+var clientCertHeaders = []string{
+	HeaderClientCert, HeaderClientCertSubject, HeaderClientCertIssuer, HeaderClientCertSerial,
+	HeaderClientCertFingerprint, HeaderClientCertSan, HeaderClientCertNotAfter,
+}
+
+// ClientCertificate returns the verified client certificate of a request.
+func ClientCertificate(r *http.Request) (*x509.Certificate, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return r.TLS.VerifiedChains[0][0], true
+}
+
+// Identities returns the names a client certificate can be registered under
+// as a consumer credential: its SAN URIs (such as SPIFFE ids), DNS names and
+// email addresses, then its subject common name.
+func Identities(cert *x509.Certificate) []string {
+	var ids []string
+	for _, uri := range cert.URIs {
+		ids = append(ids, uri.String())
+	}
+	ids = append(ids, cert.DNSNames...)
+	ids = append(ids, cert.EmailAddresses...)
+	if cert.Subject.CommonName != "" {
+		ids = append(ids, cert.Subject.CommonName)
+	}
+	return ids
+}
+
+// ForwardClientCert passes details of the verified client certificate
+// upstream in the X-Client-Cert-* headers, and the escaped PEM in
+// X-Client-Cert when mode is "pem". Headers of those names sent by the
+// client are always dropped. Mode "none" leaves requests alone.
+func ForwardClientCert(mode string, next http.Handler) http.Handler {
+	if mode == "none" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range clientCertHeaders {
+			r.Header.Del(name)
+		}
+		if cert, ok := ClientCertificate(r); ok {
+			sum := sha256.Sum256(cert.Raw)
+			r.Header.Set(HeaderClientCertSubject, cert.Subject.String())
+			r.Header.Set(HeaderClientCertIssuer, cert.Issuer.String())
+			r.Header.Set(HeaderClientCertSerial, cert.SerialNumber.Text(16))
+			r.Header.Set(HeaderClientCertFingerprint, hex.EncodeToString(sum[:]))
+			r.Header.Set(HeaderClientCertNotAfter, cert.NotAfter.UTC().Format(time.RFC3339))
+			if san := subjectAltNames(cert); san != "" {
+				r.Header.Set(HeaderClientCertSan, san)
+			}
+			if mode == "pem" {
+				block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+				r.Header.Set(HeaderClientCert, url.QueryEscape(string(block)))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func subjectAltNames(cert *x509.Certificate) string {
+	var names []string
+	for _, name := range cert.DNSNames {
+		names = append(names, "DNS:"+name)
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, "URI:"+uri.String())
+	}
+	for _, email := range cert.EmailAddresses {
+		names = append(names, "email:"+email)
+	}
+	for _, ip := range cert.IPAddresses {
+		names = append(names, "IP:"+ip.String())
+	}
+	return strings.Join(names, ",")
+}
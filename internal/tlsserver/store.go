@@ -0,0 +1,189 @@
+// Package tlsserver serves the gateway over TLS: certificates picked by SNI,
+// client certificates verified against CA bundles, and both reloaded when
+// their files change.
+package tlsserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type CertificateFiles struct {
+	CertFile string
+	KeyFile  string
+	// ServerNames replace the names taken from the certificate.
+	ServerNames []string
+}
+
+type Options struct {
+	Certificates  []CertificateFiles
+	ClientAuth    tls.ClientAuthType
+	ClientCAFiles []string
+	MinVersion    uint16
+}
+
+// Store holds the loaded certificates. Handshakes always see a complete
+// set: a reload that fails keeps the previous one.
+type Store struct {
+	opts    Options
+	current atomic.Pointer[bundle]
+}
+
+type bundle struct {
+	byName    map[string]*tls.Certificate
+	fallback  *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// NewStore loads every file in opts, failing on the first that is missing
+// or invalid.
+func NewStore(opts Options) (*Store, error) {
+	if len(opts.Certificates) == 0 {
+		return nil, errors.New("tls needs at least one certificate")
+	}
+	if opts.ClientAuth != tls.NoClientCert && len(opts.ClientCAFiles) == 0 {
+		return nil, errors.New("client certificates need at least one CA file")
+	}
+	s := &Store{opts: opts}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the files again and swaps them in for new handshakes.
+func (s *Store) Reload() error {
+	b := &bundle{byName: map[string]*tls.Certificate{}}
+	for _, files := range s.opts.Certificates {
+		cert, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+		if err != nil {
+			return fmt.Errorf("certificate %s: %w", files.CertFile, err)
+		}
+		names := files.ServerNames
+		if len(names) == 0 {
+			names = cert.Leaf.DNSNames
+			if len(names) == 0 && cert.Leaf.Subject.CommonName != "" {
+				names = []string{cert.Leaf.Subject.CommonName}
+			}
+		}
+		for _, name := range names {
+			name = strings.ToLower(name)
+			// The first certificate listed for a name wins.
+			if _, ok := b.byName[name]; !ok {
+				b.byName[name] = &cert
+			}
+		}
+		if b.fallback == nil {
+			b.fallback = &cert
+		}
+	}
+	if len(s.opts.ClientCAFiles) > 0 {
+		b.clientCAs = x509.NewCertPool()
+		for _, path := range s.opts.ClientCAFiles {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("client CA %s: %w", path, err)
+			}
+			if !b.clientCAs.AppendCertsFromPEM(data) {
+				return fmt.Errorf("client CA %s holds no PEM certificate", path)
+			}
+		}
+	}
+	s.current.Store(b)
+	return nil
+}
+
+// certificate picks the certificate for an SNI name: an exact match, then a
+// wildcard one level up, then the first certificate.
+func (b *bundle) certificate(serverName string) *tls.Certificate {
+	name := strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if cert, ok := b.byName[name]; ok {
+		return cert
+	}
+	if _, parent, ok := strings.Cut(name, "."); ok {
+		if cert, ok := b.byName["*."+parent]; ok {
+			return cert
+		}
+	}
+	return b.fallback
+}
+
+// TLSConfig returns the server configuration. Certificates and client CAs
+// are looked up per handshake, so reloads apply to new connections only.
+func (s *Store) TLSConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: s.opts.MinVersion,
+		ClientAuth: s.opts.ClientAuth,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	base.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		b := s.current.Load()
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.Certificates = []tls.Certificate{*b.certificate(hello.ServerName)}
+		cfg.ClientCAs = b.clientCAs
+		return cfg, nil
+	}
+	return base
+}
+
+// Watch reloads the store whenever one of its files changes. Directories
+// are watched rather than files, so certificates replaced by a rename, as
+// cert-manager and Kubernetes secret mounts do, are picked up too.
+func (s *Store) Watch() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Println("tls watcher error:", err)
+		return
+	}
+
+	dirs := map[string]bool{}
+	for _, files := range s.opts.Certificates {
+		dirs[filepath.Dir(files.CertFile)] = true
+		dirs[filepath.Dir(files.KeyFile)] = true
+	}
+	for _, path := range s.opts.ClientCAFiles {
+		dirs[filepath.Dir(path)] = true
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			log.Printf("Cannot watch %s for certificate changes: %v", dir, err)
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		debounce := time.NewTimer(time.Hour)
+		debounce.Stop()
+
+		for {
+			select {
+			case event := <-watcher.Events:
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+					debounce.Reset(500 * time.Millisecond)
+				}
+
+			case <-debounce.C:
+				if err := s.Reload(); err != nil {
+					log.Println("TLS reload failed, keeping the previous certificates:", err)
+				} else {
+					log.Println("TLS certificates reloaded")
+				}
+
+			case err := <-watcher.Errors:
+				log.Println("tls watch error:", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,177 @@
+package tlsserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issue creates a certificate signed by parent, or a self-signed CA when
+// parent is nil.
+func issue(t *testing.T, serial int64, template x509.Certificate, parent *testCert) testCert {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template.SerialNumber = big.NewInt(serial)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := &template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return testCert{cert: cert, key: key}
+}
+
+func (c testCert) write(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	der, _ := x509.MarshalECPrivateKey(c.key)
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+	return certFile, keyFile
+}
+
+func serve(t *testing.T, store *Store, handler http.Handler) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", store.TLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return ln.Addr().String()
+}
+
+func TestStore_SelectsBySNIAndReloads(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, 1, x509.Certificate{Subject: pkix.Name{CommonName: "Test CA"}}, nil)
+	aCert, aKey := issue(t, 2, x509.Certificate{DNSNames: []string{"a.example.com"}}, &ca).write(t, dir, "a")
+	bCert, bKey := issue(t, 3, x509.Certificate{DNSNames: []string{"*.b.example.com"}}, &ca).write(t, dir, "b")
+
+	store, err := NewStore(Options{Certificates: []CertificateFiles{
+		{CertFile: aCert, KeyFile: aKey},
+		{CertFile: bCert, KeyFile: bKey},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := serve(t, store, http.NotFoundHandler())
+
+	served := func(serverName string) int64 {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	if serial := served("x.b.example.com"); serial != 3 {
+		t.Errorf("Expected the wildcard certificate, got serial %d", serial)
+	}
+	if serial := served("unknown.example.org"); serial != 2 {
+		t.Errorf("Expected the first certificate as fallback, got serial %d", serial)
+	}
+
+	issue(t, 4, x509.Certificate{DNSNames: []string{"a.example.com"}}, &ca).write(t, dir, "a")
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if serial := served("a.example.com"); serial != 4 {
+		t.Errorf("Expected the reloaded certificate, got serial %d", serial)
+	}
+
+	os.WriteFile(aKey, []byte("broken"), 0600)
+	if err := store.Reload(); err == nil {
+		t.Errorf("Expected an invalid key to fail the reload")
+	}
+	if serial := served("a.example.com"); serial != 4 {
+		t.Errorf("Expected a failed reload to keep the previous certificate, got serial %d", serial)
+	}
+}
+
+func TestMutualTLS_ForwardsVerifiedIdentity(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, 1, x509.Certificate{Subject: pkix.Name{CommonName: "Test CA"}}, nil)
+	serverCert, serverKey := issue(t, 2, x509.Certificate{DNSNames: []string{"api.example.com"}}, &ca).write(t, dir, "server")
+	caFile, _ := ca.write(t, dir, "ca")
+	spiffe, _ := url.Parse("spiffe://example.com/billing")
+	client := issue(t, 3, x509.Certificate{
+		Subject:     pkix.Name{CommonName: "billing"},
+		URIs:        []*url.URL{spiffe},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &ca)
+
+	store, err := NewStore(Options{
+		Certificates:  []CertificateFiles{{CertFile: serverCert, KeyFile: serverKey}},
+		ClientAuth:    tls.RequireAndVerifyClientCert,
+		ClientCAFiles: []string{caFile},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var headers http.Header
+	var ids []string
+	addr := serve(t, store, ForwardClientCert("details", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		cert, _ := ClientCertificate(r)
+		ids = Identities(cert)
+	})))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	transport := &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		ServerName:   "api.example.com",
+		Certificates: []tls.Certificate{{Certificate: [][]byte{client.cert.Raw}, PrivateKey: client.key}},
+	}}
+	req, _ := http.NewRequest("GET", "https://"+addr+"/", nil)
+	req.Header.Set(HeaderClientCertSubject, "CN=forged")
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := headers.Get(HeaderClientCertSubject); got != "CN=billing" {
+		t.Errorf("Expected the verified subject, got %q", got)
+	}
+	if got := headers.Get(HeaderClientCertSan); got != "URI:spiffe://example.com/billing" {
+		t.Errorf("Expected the SAN header, got %q", got)
+	}
+	if len(ids) != 2 || ids[0] != "spiffe://example.com/billing" || ids[1] != "billing" {
+		t.Errorf("Unexpected identities %v", ids)
+	}
+
+	anonymous := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "api.example.com"}}
+	if resp, err := (&http.Client{Transport: anonymous}).Get("https://" + addr + "/"); err == nil {
+		resp.Body.Close()
+		t.Errorf("Expected a client without a certificate to be refused")
+	}
+}
@@ -2,7 +2,8 @@ import React, { useState } from 'react';
 import { Terminal, Play, X, Loader } from 'lucide-react';
 import { usePlaygroundRoutes } from '../hooks/usePlaygroundRoutes';
 import { onTest } from '../services/playground';
-import { useMutation } from '@tanstack/react-query';
+import { setRouteMock } from '../services/routes';
+import { useMutation, useQueryClient } from '@tanstack/react-query';
 
 const Card = ({ children, className = "" }) => (
   <div className={`bg-white rounded-2xl border border-slate-200/60 shadow-sm hover:shadow-md transition-shadow duration-300 ${className}`}>
@@ -29,6 +30,8 @@ const Button = ({ children, variant = "primary", className, ...props }) => {
 
 const PlaygroundView = () => {
   const { data: routes, isLoading: routesLoading, error: routesError } = usePlaygroundRoutes();
+  const queryClient = useQueryClient();
+  const [routeId, setRouteId] = useState('');
   const [method, setMethod] = useState('GET');
   const [url, setUrl] = useState('');
   const [activeTab, setActiveTab] = useState('params');
@@ -48,6 +51,15 @@ const PlaygroundView = () => {
   const addRow = (setter, list) => setter([...list, { key: "", value: "" }]);
   const removeRow = (setter, list, index) => setter(list.filter((_, i) => i !== index));
 
+  const selectedRoute = routes?.find(r => r.id === routeId);
+  const mockMutation = useMutation({
+    mutationFn: (enabled: boolean) => setRouteMock(routeId, enabled),
+    // The gateway reloads the routes file asynchronously, so apply the
+    // returned state instead of refetching.
+    onSuccess: (updated) => queryClient.setQueryData(['playgroundRoutes'], (old: any[] = []) =>
+      old.map(r => r.id === updated.id ? { ...r, mock: updated.mock } : r)),
+  });
+
   const handleSend = async () => {
     setLoading(true);
     
@@ -124,7 +136,10 @@ const PlaygroundView = () => {
                 </h3>
                 <select 
                   className="bg-white border border-slate-200 text-xs rounded-md px-2 py-1 outline-none focus:border-indigo-500"
-                  onChange={(e) => setUrl(e.target.value)}
+                  onChange={(e) => {
+                    setRouteId(e.target.value);
+                    setUrl(routes?.find(r => r.id === e.target.value)?.path_prefix || '');
+                  }}
                   disabled={routesLoading}
                 >
                   <option value="">
@@ -134,9 +149,21 @@ const PlaygroundView = () => {
                       ? "Error loading routes"
                       : "Load Route..."}
                   </option>
-                  {routes && routes.map(r => <option key={r.id} value={r.path_prefix}>{r.name}</option>)}
+                  {routes && routes.map(r => <option key={r.id} value={r.id}>{r.name}</option>)}
                 </select>
              </div>
+             {selectedRoute?.mockable && (
+               <label className="flex items-center justify-between text-xs text-slate-500">
+                 <span>Mock mode {mockMutation.isPending && <Loader size={12} className="inline animate-spin ml-1" />}</span>
+                 <input
+                   type="checkbox"
+                   checked={!!selectedRoute.mock}
+                   disabled={mockMutation.isPending}
+                   onChange={e => mockMutation.mutate(e.target.checked)}
+                   className="accent-indigo-600"
+                 />
+               </label>
+             )}
              <div className="flex space-x-2">
                 <select 
                   value={method} 
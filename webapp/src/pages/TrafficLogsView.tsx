@@ -27,7 +27,7 @@ export default function TrafficLogsView(){
                  <div className="p-6 border-b border-slate-100"><h2 className="font-bold text-slate-900 flex items-center"><ScrollText size={18} className="mr-2 text-slate-400" /> Recent Traffic Logs</h2></div>
                  <table className="w-full text-sm text-left text-slate-500">
                     <thead className="bg-slate-50/50 text-[10px] uppercase text-slate-400 font-bold tracking-wider">
-                       <tr><th className="px-6 py-4">Time</th><th className="px-6 py-4">Method</th><th className="px-6 py-4">Path</th><th className="px-6 py-4">Status</th><th className="px-6 py-4">Latency</th></tr>
+                       <tr><th className="px-6 py-4">Time</th><th className="px-6 py-4">Method</th><th className="px-6 py-4">Path</th><th className="px-6 py-4">Status</th><th className="px-6 py-4">Latency</th><th className="px-6 py-4">WAF Rules</th></tr>
                     </thead>
                     <tbody className="divide-y divide-slate-50">
                        {data && data.reverse().map((log,i) => (
@@ -37,6 +37,7 @@ export default function TrafficLogsView(){
                             <td className="px-6 py-4 text-slate-700 font-medium">{log.path}</td>
                             <td className="px-6 py-4"><span className={`px-2 py-1 rounded text-[10px] font-bold ${log.statusCode === 200 ? 'bg-emerald-50 text-emerald-600' : 'bg-rose-50 text-rose-600'}`}>{log.statusCode}</span></td>
                             <td className="px-6 py-4 font-mono text-xs">{log.latency}ms</td>
+                            <td className="px-6 py-4 font-mono text-xs">{log.wafRules?.join(", ")}</td>
                          </tr>
                        ))}
                     </tbody>
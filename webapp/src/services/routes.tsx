@@ -14,6 +14,8 @@ export interface Route {
   auth?: any;
   filters?: any[]; // if you want, I can model GenericFilter properly
   lb?:LB
+  mockable?: boolean;
+  mock?: boolean;
 }
 
 
@@ -52,6 +54,18 @@ export const fetchPlaygroundRoutes = async (): Promise<Route[]> => {
 
 
 
+export const setRouteMock = async (routeId: string, enabled: boolean): Promise<Route> => {
+    const token=useAuthStore.getState().token
+  return await createApi(`/api/playground/routes/${routeId}/mock`, {
+    method: 'PUT',
+    headers: {
+      'Content-Type': 'application/json',
+      'Authorization':`Bearer ${token}`
+    },
+    body: JSON.stringify({ enabled }),
+  });
+};
+
 export const createRoute = async (route: Route): Promise<void> => {
     const token=useAuthStore.getState().token
   await createApi('/api/routes', {
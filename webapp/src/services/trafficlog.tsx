@@ -7,6 +7,7 @@ export interface LogEntry {
   path: string;
   statusCode: number;
   time: string;         // ISO timestamp
+  wafRules?: number[];  // IDs of matched WAF rules
 }
 
 export default async function fetchTrafficLogs(): Promise<LogEntry[]> {